@@ -0,0 +1,56 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// uniqueKey 需要唯一索引的資料表與欄位
+type uniqueKey struct {
+	Table   string
+	Index   string
+	Columns []string
+}
+
+// uniqueKeys 後來才加上唯一索引的資料表；舊資料可能已有重複，AutoMigrate 建立索引前需要先清除
+var uniqueKeys = []uniqueKey{
+	{Table: "match_participants", Index: "unique_match_user", Columns: []string{"match_id", "user_id"}},
+	{Table: "reviews", Index: "unique_reviewer_reviewee_match", Columns: []string{"reviewer_id", "reviewee_id", "match_id"}},
+	{Table: "review_likes", Index: "unique_user_review", Columns: []string{"user_id", "review_id"}},
+}
+
+// DedupeUniqueKeys 在 AutoMigrate 建立唯一索引之前刪除重複的資料，每組只保留 id 最小的一筆，
+// 回傳刪除的筆數；資料表尚未建立或索引已存在時略過
+func DedupeUniqueKeys(db *gorm.DB) (int64, error) {
+	var deleted int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, key := range uniqueKeys {
+			if !tx.Migrator().HasTable(key.Table) || tx.Migrator().HasIndex(key.Table, key.Index) {
+				continue
+			}
+			// 以衍生資料表包住子查詢，MySQL 才允許刪除同一個資料表
+			columns := strings.Join(key.Columns, ", ")
+			result := tx.Exec(fmt.Sprintf(
+				"DELETE FROM %s WHERE id NOT IN (SELECT id FROM (SELECT MIN(id) AS id FROM %s GROUP BY %s) AS keep_rows)",
+				key.Table, key.Table, columns,
+			))
+			if result.Error != nil {
+				return fmt.Errorf("%s: %w", key.Table, result.Error)
+			}
+			deleted += result.RowsAffected
+
+			// 被刪除的重複評分上的按讚一併清除，避免留下指向不存在評分的資料
+			if key.Table == "reviews" && result.RowsAffected > 0 && tx.Migrator().HasTable("review_likes") {
+				result := tx.Exec("DELETE FROM review_likes WHERE review_id NOT IN (SELECT id FROM reviews)")
+				if result.Error != nil {
+					return fmt.Errorf("review_likes: %w", result.Error)
+				}
+				deleted += result.RowsAffected
+			}
+		}
+		return nil
+	})
+	return deleted, err
+}
@@ -0,0 +1,50 @@
+package database
+
+import (
+	"testing"
+
+	"free2free/models"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestDedupeUniqueKeys(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to connect to test database: %v", err)
+	}
+
+	// 加上唯一索引前的舊資料表
+	for _, stmt := range []string{
+		"CREATE TABLE match_participants (id INTEGER PRIMARY KEY, match_id INTEGER, user_id INTEGER, status TEXT)",
+		"CREATE TABLE reviews (id INTEGER PRIMARY KEY, match_id INTEGER, reviewer_id INTEGER, reviewee_id INTEGER, score INTEGER)",
+		"CREATE TABLE review_likes (id INTEGER PRIMARY KEY, review_id INTEGER, user_id INTEGER, is_like BOOLEAN)",
+		"INSERT INTO match_participants (id, match_id, user_id, status) VALUES (1, 1, 2, 'approved'), (2, 1, 2, 'pending'), (3, 1, 3, 'pending'), (4, 2, 2, 'pending')",
+		"INSERT INTO reviews (id, match_id, reviewer_id, reviewee_id, score) VALUES (1, 1, 2, 3, 5), (2, 1, 2, 3, 4), (3, 1, 3, 2, 5)",
+		"INSERT INTO review_likes (id, review_id, user_id, is_like) VALUES (1, 1, 4, 1), (2, 1, 4, 0), (3, 2, 5, 1), (4, 3, 4, 1)",
+	} {
+		assert.NoError(t, db.Exec(stmt).Error)
+	}
+
+	deleted, err := DedupeUniqueKeys(db)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), deleted)
+
+	ids := func(table string) []int64 {
+		var result []int64
+		assert.NoError(t, db.Table(table).Order("id").Pluck("id", &result).Error)
+		return result
+	}
+	// 每組只保留 id 最小的一筆，重複評分上的按讚一併清除
+	assert.Equal(t, []int64{1, 3, 4}, ids("match_participants"))
+	assert.Equal(t, []int64{1, 3}, ids("reviews"))
+	assert.Equal(t, []int64{1, 4}, ids("review_likes"))
+
+	// 清除後可以建立唯一索引，再次執行不會刪除任何資料
+	assert.NoError(t, db.AutoMigrate(&models.MatchParticipant{}, &models.Review{}, &models.ReviewLike{}))
+	deleted, err = DedupeUniqueKeys(db)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), deleted)
+}
@@ -10,6 +10,8 @@ import (
 type AppError struct {
 	Code    int    `json:"code"`
 	Message string `json:"error"`
+	// ErrorCode 供前端判斷錯誤種類的機器可讀代碼，可為空
+	ErrorCode string `json:"error_code,omitempty"`
 }
 
 func (e *AppError) Error() string {
@@ -27,6 +29,15 @@ func NewAppError(code int, message string) *AppError {
 	}
 }
 
+// NewCodedError 建立帶有機器可讀錯誤代碼的錯誤
+func NewCodedError(code int, errorCode, message string) *AppError {
+	return &AppError{
+		Code:      code,
+		Message:   message,
+		ErrorCode: errorCode,
+	}
+}
+
 func MapGORMError(err error) *AppError {
 	if errors.Is(err, gorm.ErrRecordNotFound) {
 		return NewAppError(http.StatusNotFound, "Record not found")
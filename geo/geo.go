@@ -0,0 +1,199 @@
+package geo
+
+import (
+	"math"
+	"strings"
+)
+
+// EarthRadiusKm 地球平均半徑 (公里)
+const EarthRadiusKm = 6371.0
+
+// MaxPrecision 儲存在資料庫中的 geohash 長度
+const MaxPrecision = 12
+
+const base32 = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// Haversine 計算兩個座標之間的大圓距離 (公里)
+func Haversine(lat1, lng1, lat2, lng2 float64) float64 {
+	dLat := toRadians(lat2 - lat1)
+	dLng := toRadians(lng2 - lng1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRadians(lat1))*math.Cos(toRadians(lat2))*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * EarthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// Box 經緯度範圍
+// 範圍跨越 180 度經線時 MinLng 會大於 MaxLng
+type Box struct {
+	MinLat, MaxLat float64
+	MinLng, MaxLng float64
+}
+
+// CrossesAntimeridian 範圍是否跨越 180 度經線
+func (b Box) CrossesAntimeridian() bool {
+	return b.MinLng > b.MaxLng
+}
+
+// BoundingBox 回傳包含以 (lat, lng) 為中心、半徑 radiusKm 圓形的經緯度範圍
+func BoundingBox(lat, lng, radiusKm float64) Box {
+	dLat := radiusKm / EarthRadiusKm * 180 / math.Pi
+	box := Box{
+		MinLat: math.Max(-90, lat-dLat),
+		MaxLat: math.Min(90, lat+dLat),
+		MinLng: -180,
+		MaxLng: 180,
+	}
+
+	// 範圍包含極點時經度不受限制
+	if box.MinLat <= -90 || box.MaxLat >= 90 {
+		return box
+	}
+
+	dLng := math.Asin(math.Min(1, math.Sin(radiusKm/EarthRadiusKm)/math.Cos(toRadians(lat)))) * 180 / math.Pi
+	box.MinLng = normalizeLng(lng - dLng)
+	box.MaxLng = normalizeLng(lng + dLng)
+	return box
+}
+
+// Encode 將座標編碼為指定長度的 geohash
+func Encode(lat, lng float64, precision int) string {
+	minLat, maxLat := -90.0, 90.0
+	minLng, maxLng := -180.0, 180.0
+
+	var sb strings.Builder
+	bit, ch := 0, 0
+	even := true
+	for sb.Len() < precision {
+		if even {
+			mid := (minLng + maxLng) / 2
+			if lng >= mid {
+				ch |= 1 << (4 - bit)
+				minLng = mid
+			} else {
+				maxLng = mid
+			}
+		} else {
+			mid := (minLat + maxLat) / 2
+			if lat >= mid {
+				ch |= 1 << (4 - bit)
+				minLat = mid
+			} else {
+				maxLat = mid
+			}
+		}
+		even = !even
+
+		if bit < 4 {
+			bit++
+		} else {
+			sb.WriteByte(base32[ch])
+			bit, ch = 0, 0
+		}
+	}
+	return sb.String()
+}
+
+// Decode 回傳 geohash 格子的中心點與緯度、經度方向的半寬
+func Decode(hash string) (lat, lng, latErr, lngErr float64) {
+	minLat, maxLat := -90.0, 90.0
+	minLng, maxLng := -180.0, 180.0
+
+	even := true
+	for _, r := range hash {
+		idx := strings.IndexRune(base32, r)
+		if idx < 0 {
+			break
+		}
+		for bit := 4; bit >= 0; bit-- {
+			set := idx&(1<<bit) != 0
+			if even {
+				mid := (minLng + maxLng) / 2
+				if set {
+					minLng = mid
+				} else {
+					maxLng = mid
+				}
+			} else {
+				mid := (minLat + maxLat) / 2
+				if set {
+					minLat = mid
+				} else {
+					maxLat = mid
+				}
+			}
+			even = !even
+		}
+	}
+	return (minLat + maxLat) / 2, (minLng + maxLng) / 2, (maxLat - minLat) / 2, (maxLng - minLng) / 2
+}
+
+// PrecisionForRadius 回傳在緯度 lat 附近格子高度與東西向寬度都不小於 radiusKm 的最長 geohash 長度，
+// 以此長度取中心格與周圍 8 格即可完整涵蓋搜尋範圍；連最短的長度都不夠時回傳 0
+func PrecisionForRadius(lat, radiusKm float64) int {
+	// 格子離赤道越遠越窄，以搜尋範圍內最靠近極點的緯度計算寬度
+	edgeLat := math.Min(90, math.Abs(lat)+radiusKm/kmPerDegree)
+	precision := 0
+	for p := 1; p <= MaxPrecision; p++ {
+		heightKm, widthKm := cellSizeKm(p, edgeLat)
+		if heightKm < radiusKm || widthKm < radiusKm {
+			break
+		}
+		precision = p
+	}
+	return precision
+}
+
+// kmPerDegree 緯度一度 (以及赤道上經度一度) 的距離 (公里)
+const kmPerDegree = EarthRadiusKm * math.Pi / 180
+
+// cellSizeKm 回傳 geohash 長度 precision 的格子在緯度 lat 的高度與東西向寬度 (公里)；
+// 經度一度的距離隨 cos(lat) 縮小
+func cellSizeKm(precision int, lat float64) (heightKm, widthKm float64) {
+	bits := 5 * precision
+	lngBits, latBits := (bits+1)/2, bits/2
+	heightKm = 180 / math.Exp2(float64(latBits)) * kmPerDegree
+	widthKm = 360 / math.Exp2(float64(lngBits)) * kmPerDegree * math.Cos(toRadians(lat))
+	return heightKm, widthKm
+}
+
+// CoverCells 回傳涵蓋以 (lat, lng) 為中心、半徑 radiusKm 圓形的 geohash 前綴 (中心格與周圍 8 格)
+// 範圍大到連最短的 geohash 都無法以 9 格涵蓋時 (例如靠近極點)，回傳所有長度為 1 的格子
+func CoverCells(lat, lng, radiusKm float64) []string {
+	precision := PrecisionForRadius(lat, radiusKm)
+	if precision == 0 {
+		return strings.Split(base32, "")
+	}
+	center := Encode(lat, lng, precision)
+	cLat, cLng, latErr, lngErr := Decode(center)
+
+	seen := map[string]bool{}
+	var cells []string
+	for _, dLat := range []float64{-2, 0, 2} {
+		for _, dLng := range []float64{-2, 0, 2} {
+			nLat := cLat + dLat*latErr
+			if nLat > 90 || nLat < -90 {
+				continue
+			}
+			cell := Encode(nLat, normalizeLng(cLng+dLng*lngErr), precision)
+			if !seen[cell] {
+				seen[cell] = true
+				cells = append(cells, cell)
+			}
+		}
+	}
+	return cells
+}
+
+func toRadians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func normalizeLng(lng float64) float64 {
+	for lng > 180 {
+		lng -= 360
+	}
+	for lng < -180 {
+		lng += 360
+	}
+	return lng
+}
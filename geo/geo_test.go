@@ -0,0 +1,102 @@
+package geo
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHaversine(t *testing.T) {
+	// 台北 101 到台北車站約 5.3 公里
+	d := Haversine(25.033964, 121.564468, 25.047924, 121.517081)
+	assert.InDelta(t, 5.0, d, 0.5)
+
+	assert.Equal(t, 0.0, Haversine(25, 121, 25, 121))
+}
+
+func TestEncodeDecode(t *testing.T) {
+	assert.Equal(t, "wsqqq", Encode(25.033964, 121.564468, 5))
+
+	lat, lng, latErr, lngErr := Decode(Encode(25.033964, 121.564468, MaxPrecision))
+	assert.InDelta(t, 25.033964, lat, latErr)
+	assert.InDelta(t, 121.564468, lng, lngErr)
+}
+
+func TestCoverCellsContainPointsWithinRadius(t *testing.T) {
+	centerLat, centerLng := 25.033964, 121.564468
+	radius := 3.0
+	cells := CoverCells(centerLat, centerLng, radius)
+
+	// 搜尋範圍內的點都必須落在其中一個格子內
+	points := [][2]float64{
+		{25.047924, 121.517081 + 0.02},
+		{25.060, 121.564468},
+		{25.010, 121.540},
+		{25.033964, 121.594},
+	}
+	for _, p := range points {
+		if Haversine(centerLat, centerLng, p[0], p[1]) > radius {
+			continue
+		}
+		hash := Encode(p[0], p[1], MaxPrecision)
+		covered := false
+		for _, cell := range cells {
+			if strings.HasPrefix(hash, cell) {
+				covered = true
+			}
+		}
+		assert.True(t, covered, "point %v not covered by %v", p, cells)
+	}
+}
+
+func TestCoverCellsAwayFromEquator(t *testing.T) {
+	// 格子東西向的寬度隨緯度縮小，半徑接近格子大小時最容易漏掉範圍內的點
+	rng := rand.New(rand.NewSource(1))
+	for _, centerLat := range []float64{0, 25, 45, 60} {
+		for _, radius := range []float64{0.6, 4.8, 19} {
+			centerLng := 121.5
+			cells := CoverCells(centerLat, centerLng, radius)
+			box := BoundingBox(centerLat, centerLng, radius)
+
+			missed := 0
+			for i := 0; i < 2000; i++ {
+				lat := box.MinLat + rng.Float64()*(box.MaxLat-box.MinLat)
+				lng := box.MinLng + rng.Float64()*(box.MaxLng-box.MinLng)
+				if Haversine(centerLat, centerLng, lat, lng) > radius {
+					continue
+				}
+				hash := Encode(lat, lng, MaxPrecision)
+				covered := false
+				for _, cell := range cells {
+					if strings.HasPrefix(hash, cell) {
+						covered = true
+					}
+				}
+				if !covered {
+					missed++
+				}
+			}
+			assert.Zero(t, missed, "lat %v radius %v cells %v", centerLat, radius, cells)
+		}
+	}
+}
+
+func TestPrecisionForRadius(t *testing.T) {
+	assert.Equal(t, 5, PrecisionForRadius(0, 4.8))
+	// 緯度 60 度時長度 5 的格子東西向只有約 2.4 公里，需改用較短的長度
+	assert.Equal(t, 4, PrecisionForRadius(60, 4.8))
+	assert.Equal(t, 0, PrecisionForRadius(89.9, 50))
+	assert.Len(t, CoverCells(89.9, 0, 50), 32)
+}
+
+func TestBoundingBoxAntimeridian(t *testing.T) {
+	box := BoundingBox(0, 179.99, 10)
+	assert.True(t, box.CrossesAntimeridian())
+
+	box = BoundingBox(25, 121, 10)
+	assert.False(t, box.CrossesAntimeridian())
+	assert.Less(t, box.MinLat, 25.0)
+	assert.Greater(t, box.MaxLng, 121.0)
+}
@@ -0,0 +1,134 @@
+package ical
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// 事件狀態 (RFC 5545 3.8.1.11)
+const (
+	StatusConfirmed = "CONFIRMED"
+	StatusCancelled = "CANCELLED"
+)
+
+// maxLineOctets 每行最多 75 個位元組，超過時需要折行 (RFC 5545 3.1)
+const maxLineOctets = 75
+
+const timeLayout = "20060102T150405Z"
+
+// Event 行事曆中的一個事件
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	Location    string
+	Latitude    float64
+	Longitude   float64
+	HasGeo      bool
+	Start       time.Time
+	End         time.Time
+	Status      string
+}
+
+// Calendar 一份 VCALENDAR 文件
+type Calendar struct {
+	ProdID string
+	Name   string
+	Events []Event
+}
+
+// Encode 將行事曆以 text/calendar 格式寫出，stamp 為 DTSTAMP 使用的時間
+func (cal *Calendar) Encode(w io.Writer, stamp time.Time) error {
+	lw := &lineWriter{w: w}
+	lw.line("BEGIN:VCALENDAR")
+	lw.line("VERSION:2.0")
+	lw.line("PRODID:" + cal.ProdID)
+	lw.line("CALSCALE:GREGORIAN")
+	lw.line("METHOD:PUBLISH")
+	if cal.Name != "" {
+		lw.line("X-WR-CALNAME:" + Escape(cal.Name))
+	}
+
+	for _, event := range cal.Events {
+		lw.line("BEGIN:VEVENT")
+		lw.line("UID:" + event.UID)
+		lw.line("DTSTAMP:" + formatTime(stamp))
+		lw.line("DTSTART:" + formatTime(event.Start))
+		if !event.End.IsZero() {
+			lw.line("DTEND:" + formatTime(event.End))
+		}
+		lw.line("SUMMARY:" + Escape(event.Summary))
+		if event.Description != "" {
+			lw.line("DESCRIPTION:" + Escape(event.Description))
+		}
+		if event.Location != "" {
+			lw.line("LOCATION:" + Escape(event.Location))
+		}
+		if event.HasGeo {
+			lw.line(fmt.Sprintf("GEO:%.6f;%.6f", event.Latitude, event.Longitude))
+		}
+		if event.Status != "" {
+			lw.line("STATUS:" + event.Status)
+		}
+		lw.line("END:VEVENT")
+	}
+
+	lw.line("END:VCALENDAR")
+	return lw.err
+}
+
+// Escape 跳脫 TEXT 型別的特殊字元 (RFC 5545 3.3.11)
+func Escape(text string) string {
+	replacer := strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+		"\r", `\n`,
+	)
+	return replacer.Replace(text)
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(timeLayout)
+}
+
+// lineWriter 寫出以 CRLF 結尾的內容行，並在超過長度時折行
+type lineWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (lw *lineWriter) line(content string) {
+	if lw.err != nil {
+		return
+	}
+	_, lw.err = io.WriteString(lw.w, fold(content)+"\r\n")
+}
+
+// fold 將超過 75 位元組的內容行折成多行，續行以一個空白開頭，且不會切斷 UTF-8 字元
+func fold(content string) string {
+	if len(content) <= maxLineOctets {
+		return content
+	}
+
+	var b strings.Builder
+	limit := maxLineOctets
+	lineLen := 0
+	for _, r := range content {
+		size := utf8.RuneLen(r)
+		if lineLen+size > limit {
+			b.WriteString("\r\n ")
+			// 續行開頭的空白也計入長度
+			limit = maxLineOctets - 1
+			lineLen = 0
+		}
+		b.WriteRune(r)
+		lineLen += size
+	}
+	return b.String()
+}
@@ -0,0 +1,57 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEscape(t *testing.T) {
+	assert.Equal(t, `台北市\, 信義區\; 1樓\n靠窗 \\ 座位`, Escape("台北市, 信義區; 1樓\n靠窗 \\ 座位"))
+}
+
+func TestFoldKeepsLinesShortAndRunesIntact(t *testing.T) {
+	content := "SUMMARY:" + strings.Repeat("咖啡買一送一", 10)
+	folded := fold(content)
+
+	for _, line := range strings.Split(folded, "\r\n") {
+		assert.LessOrEqual(t, len(line), maxLineOctets)
+	}
+	// 移除折行後內容不變
+	assert.Equal(t, content, strings.ReplaceAll(folded, "\r\n ", ""))
+}
+
+func TestCalendarEncode(t *testing.T) {
+	start := time.Date(2026, 10, 20, 14, 0, 0, 0, time.FixedZone("CST", 8*3600))
+	cal := Calendar{
+		ProdID: "-//free2free//match calendar//ZH",
+		Name:   "我的配對",
+		Events: []Event{{
+			UID:       "match-1@free2free",
+			Summary:   "咖啡買一送一",
+			Location:  "全家便利商店, 台北市信義區",
+			Latitude:  25.033964,
+			Longitude: 121.564468,
+			HasGeo:    true,
+			Start:     start,
+			End:       start.Add(2 * time.Hour),
+			Status:    StatusCancelled,
+		}},
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, cal.Encode(&buf, time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)))
+	out := buf.String()
+
+	assert.True(t, strings.HasPrefix(out, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n"))
+	assert.True(t, strings.HasSuffix(out, "END:VCALENDAR\r\n"))
+	assert.Contains(t, out, "DTSTART:20261020T060000Z\r\n")
+	assert.Contains(t, out, "DTEND:20261020T080000Z\r\n")
+	assert.Contains(t, out, "DTSTAMP:20261018T000000Z\r\n")
+	assert.Contains(t, out, `LOCATION:全家便利商店\, 台北市信義區`+"\r\n")
+	assert.Contains(t, out, "GEO:25.033964;121.564468\r\n")
+	assert.Contains(t, out, "STATUS:CANCELLED\r\n")
+}
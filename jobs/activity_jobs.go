@@ -0,0 +1,92 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"free2free/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ArchiveActivities 封存優惠已結束的活動，取消它們尚未開始的配對局並通知開局者與參與者
+func ArchiveActivities(ctx context.Context, db *gorm.DB) (int64, error) {
+	db = db.WithContext(ctx)
+	now := time.Now()
+
+	var activities []models.Activity
+	if err := db.Where("archived_at IS NULL AND ends_at <= ?", now).Find(&activities).Error; err != nil {
+		return 0, err
+	}
+
+	var archived int64
+	for _, activity := range activities {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			result := tx.Model(&models.Activity{}).
+				Where("id = ? AND archived_at IS NULL", activity.ID).
+				Update("archived_at", now)
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+
+			var matches []models.Match
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("activity_id = ? AND status = ? AND match_time > ?", activity.ID, "open", now).
+				Find(&matches).Error
+			if err != nil {
+				return err
+			}
+			reason := fmt.Sprintf("活動「%s」的優惠已結束", activity.Title)
+			for _, match := range matches {
+				if err := CancelMatch(tx, &match, reason, 0, now); err != nil {
+					return err
+				}
+			}
+			archived++
+			return nil
+		})
+		if err != nil {
+			return archived, err
+		}
+	}
+	return archived, nil
+}
+
+// CancelMatch 取消配對局並記錄原因，通知開局者與仍在配對局中的參與者；
+// actorID 為執行取消的使用者 (背景工作為 0)，不會通知自己
+func CancelMatch(tx *gorm.DB, match *models.Match, reason string, actorID int64, now time.Time) error {
+	// Updates 會一併改寫 match 的欄位，先記下原本的狀態
+	from := match.Status
+	err := tx.Model(match).Updates(map[string]interface{}{"status": "cancelled", "cancel_reason": reason}).Error
+	if err != nil {
+		return err
+	}
+	event := models.NewMatchEvent(match.ID, from, "cancelled", actorID, reason)
+	if err := tx.Create(&event).Error; err != nil {
+		return err
+	}
+
+	var userIDs []int64
+	err = tx.Model(&models.MatchParticipant{}).
+		Where("match_id = ? AND status IN ?", match.ID, []string{"pending", "approved", "reconfirm"}).
+		Pluck("user_id", &userIDs).Error
+	if err != nil {
+		return err
+	}
+	userIDs = append(userIDs, match.OrganizerID)
+
+	message := fmt.Sprintf("配對局已取消：%s", reason)
+	var notifications []models.Notification
+	for _, userID := range userIDs {
+		if userID == actorID {
+			continue
+		}
+		notifications = append(notifications, models.Notification{UserID: userID, Type: models.NotificationMatchCancelled, MatchID: &match.ID, Message: message, CreatedAt: now})
+	}
+	if len(notifications) == 0 {
+		return nil
+	}
+	return tx.Create(&notifications).Error
+}
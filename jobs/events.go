@@ -0,0 +1,68 @@
+package jobs
+
+import (
+	"free2free/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// transitionMatches 將符合條件且狀態為 from 的配對局改為 to，並在同一個交易中記錄狀態變更，回傳更新的筆數
+func transitionMatches(db *gorm.DB, scope func(*gorm.DB) *gorm.DB, from, to, reason string) (int64, error) {
+	var updated int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var ids []int64
+		err := tx.Model(&models.Match{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+			Scopes(scope).Where("status = ?", from).
+			Pluck("id", &ids).Error
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+
+		result := tx.Model(&models.Match{}).Where("id IN ?", ids).Update("status", to)
+		if result.Error != nil {
+			return result.Error
+		}
+		events := make([]models.MatchEvent, len(ids))
+		for i, id := range ids {
+			events[i] = models.NewMatchEvent(id, from, to, 0, reason)
+		}
+		if err := tx.Create(&events).Error; err != nil {
+			return err
+		}
+		updated = result.RowsAffected
+		return nil
+	})
+	return updated, err
+}
+
+// transitionParticipants 將符合條件且狀態為 from 的參與者改為 to，並在同一個交易中記錄狀態變更，回傳更新的筆數
+func transitionParticipants(db *gorm.DB, scope func(*gorm.DB) *gorm.DB, from, to, reason string) (int64, error) {
+	var updated int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var participants []models.MatchParticipant
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Scopes(scope).Where("status = ?", from).
+			Find(&participants).Error
+		if err != nil || len(participants) == 0 {
+			return err
+		}
+
+		ids := make([]int64, len(participants))
+		events := make([]models.MatchEvent, len(participants))
+		for i := range participants {
+			ids[i] = participants[i].ID
+			events[i] = models.NewParticipantEvent(&participants[i], from, to, 0, reason)
+		}
+		result := tx.Model(&models.MatchParticipant{}).Where("id IN ?", ids).Update("status", to)
+		if result.Error != nil {
+			return result.Error
+		}
+		if err := tx.Create(&events).Error; err != nil {
+			return err
+		}
+		updated = result.RowsAffected
+		return nil
+	})
+	return updated, err
+}
@@ -0,0 +1,17 @@
+package jobs
+
+import "time"
+
+// DefaultJobs 回傳應用程式預設執行的背景工作
+func DefaultJobs() []Job {
+	return []Job{
+		{Name: "complete_matches", Interval: 5 * time.Minute, Run: CompleteMatches},
+		{Name: "expire_matches", Interval: 5 * time.Minute, Run: ExpireMatches},
+		{Name: "record_attendance", Interval: time.Hour, Run: RecordAttendance},
+		{Name: "release_unconfirmed", Interval: 5 * time.Minute, Run: ReleaseUnconfirmed},
+		{Name: "purge_refresh_tokens", Interval: time.Hour, Run: PurgeExpiredRefreshTokens},
+		{Name: "generate_series_matches", Interval: time.Hour, Run: GenerateSeriesMatches},
+		{Name: "pair_intents", Interval: time.Minute, Run: PairIntents},
+		{Name: "archive_activities", Interval: time.Hour, Run: ArchiveActivities},
+	}
+}
@@ -0,0 +1,474 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"free2free/models"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupTestDatabase(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to connect to test database: %v", err)
+	}
+
+	err = db.AutoMigrate(
+		&models.User{},
+		&models.Location{},
+		&models.Activity{},
+		&models.Match{},
+		&models.MatchParticipant{},
+		&models.RefreshToken{},
+		&models.JobLock{},
+		&models.JobRun{},
+		&models.MatchSeries{},
+		&models.MatchSeriesPartner{},
+		&models.Notification{},
+		&models.MatchEvent{},
+		&models.ActivityAvailability{},
+		&models.UserQuotaOverride{},
+		&models.OrganizerBlock{},
+	)
+	assert.NoError(t, err)
+	return db
+}
+
+func TestAcquireLock(t *testing.T) {
+	db := setupTestDatabase(t)
+
+	acquired, err := AcquireLock(db, "test_job", "replica-a", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, acquired)
+
+	// 其他副本在鎖過期前無法取得
+	acquired, err = AcquireLock(db, "test_job", "replica-b", time.Minute)
+	assert.NoError(t, err)
+	assert.False(t, acquired)
+
+	// 原持有者可以續約
+	acquired, err = AcquireLock(db, "test_job", "replica-a", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, acquired)
+
+	// 釋放後其他副本可以取得
+	assert.NoError(t, ReleaseLock(db, "test_job", "replica-a"))
+	acquired, err = AcquireLock(db, "test_job", "replica-b", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, acquired)
+}
+
+func TestMatchLifecycleJobs(t *testing.T) {
+	db := setupTestDatabase(t)
+	ctx := context.Background()
+
+	past := time.Now().Add(-3 * time.Hour)
+	filled := models.Match{ActivityID: 1, OrganizerID: 1, MatchTime: past, EndTime: past.Add(2 * time.Hour), Status: "open"}
+	unfilled := models.Match{ActivityID: 1, OrganizerID: 1, MatchTime: past, EndTime: past.Add(2 * time.Hour), Status: "open"}
+	// 已開始但尚未結束的配對局不應被標記為完成
+	ongoing := models.Match{ActivityID: 1, OrganizerID: 1, MatchTime: past, EndTime: time.Now().Add(time.Hour), Status: "open"}
+	upcoming := models.Match{ActivityID: 1, OrganizerID: 1, MatchTime: time.Now().Add(time.Hour), EndTime: time.Now().Add(3 * time.Hour), Status: "open"}
+	// 參與者都在等待重新確認時仍保留名額，不應被標記為未成局
+	reconfirming := models.Match{ActivityID: 1, OrganizerID: 1, MatchTime: past, EndTime: time.Now().Add(time.Hour), Status: "open"}
+	assert.NoError(t, db.Create(&filled).Error)
+	assert.NoError(t, db.Create(&reconfirming).Error)
+	assert.NoError(t, db.Create(&unfilled).Error)
+	assert.NoError(t, db.Create(&ongoing).Error)
+	assert.NoError(t, db.Create(&upcoming).Error)
+
+	assert.NoError(t, db.Create(&models.MatchParticipant{MatchID: filled.ID, UserID: 2, Status: "approved", JoinedAt: past}).Error)
+	assert.NoError(t, db.Create(&models.MatchParticipant{MatchID: unfilled.ID, UserID: 2, Status: "pending", JoinedAt: past}).Error)
+	assert.NoError(t, db.Create(&models.MatchParticipant{MatchID: ongoing.ID, UserID: 2, Status: "approved", JoinedAt: past}).Error)
+	assert.NoError(t, db.Create(&models.MatchParticipant{MatchID: reconfirming.ID, UserID: 2, Status: "reconfirm", JoinedAt: past}).Error)
+
+	completed, err := CompleteMatches(ctx, db)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), completed)
+
+	expired, err := ExpireMatches(ctx, db)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), expired)
+
+	for id, status := range map[int64]string{filled.ID: "completed", unfilled.ID: "expired", ongoing.ID: "open", upcoming.ID: "open", reconfirming.ID: "open"} {
+		var match models.Match
+		db.First(&match, id)
+		assert.Equal(t, status, match.Status)
+	}
+
+	// 系統自動變更也留下狀態變更紀錄
+	var events []models.MatchEvent
+	assert.NoError(t, db.Order("id").Find(&events).Error)
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, filled.ID, events[0].MatchID)
+		assert.Equal(t, "completed", events[0].ToStatus)
+		assert.Equal(t, unfilled.ID, events[1].MatchID)
+		assert.Equal(t, "expired", events[1].ToStatus)
+		assert.Nil(t, events[1].ActorID)
+	}
+}
+
+func TestSchedulerRunOnceRecordsRun(t *testing.T) {
+	db := setupTestDatabase(t)
+	ctx := context.Background()
+
+	assert.NoError(t, db.Create(&models.RefreshToken{UserID: 1, Token: "expired", ExpiresAt: time.Now().Add(-time.Hour)}).Error)
+	assert.NoError(t, db.Create(&models.RefreshToken{UserID: 1, Token: "valid", ExpiresAt: time.Now().Add(time.Hour)}).Error)
+
+	job := Job{Name: "purge_refresh_tokens", Interval: time.Minute, Run: PurgeExpiredRefreshTokens}
+
+	first := NewScheduler(db)
+	ran, err := first.RunOnce(ctx, job)
+	assert.NoError(t, err)
+	assert.True(t, ran)
+
+	// 另一個副本在同一個間隔內不會重複執行
+	second := NewScheduler(db)
+	second.holder = "other-replica"
+	ran, err = second.RunOnce(ctx, job)
+	assert.NoError(t, err)
+	assert.False(t, ran)
+
+	var runs []models.JobRun
+	db.Find(&runs)
+	if assert.Len(t, runs, 1) {
+		assert.Equal(t, "success", runs[0].Status)
+		assert.Equal(t, int64(1), runs[0].Affected)
+	}
+
+	var count int64
+	db.Model(&models.RefreshToken{}).Count(&count)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestGenerateAndRescheduleSeries(t *testing.T) {
+	db := setupTestDatabase(t)
+	now := time.Now()
+
+	activity := models.Activity{Title: "晨跑", TargetCount: 2, LocationID: 1, CreatedBy: 1, DurationMinutes: 60}
+	assert.NoError(t, db.Create(&activity).Error)
+	for _, name := range []string{"organizer", "partner"} {
+		assert.NoError(t, db.Create(&models.User{SocialID: name, SocialProvider: "facebook", Name: name, Email: name + "@example.com"}).Error)
+	}
+
+	series := models.MatchSeries{
+		ActivityID:  activity.ID,
+		OrganizerID: 1,
+		Rule:        "FREQ=DAILY",
+		StartTime:   now.Add(time.Hour),
+		Until:       now.AddDate(0, 1, 0),
+		Timezone:    "Asia/Taipei",
+		DaysAhead:   3,
+		Status:      "active",
+	}
+	assert.NoError(t, db.Create(&series).Error)
+	assert.NoError(t, db.Create(&models.MatchSeriesPartner{SeriesID: series.ID, UserID: 2}).Error)
+	assert.NoError(t, db.Preload("Activity").Preload("Partners").First(&series, series.ID).Error)
+
+	created, err := GenerateSeries(db, &series, now)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), created)
+
+	var matches []models.Match
+	db.Where("series_id = ?", series.ID).Order("match_time").Find(&matches)
+	if assert.Len(t, matches, 3) {
+		assert.Equal(t, time.Hour, matches[0].EndTime.Sub(matches[0].MatchTime))
+	}
+
+	// 自動核准名單中的使用者直接成為已核准的參與者
+	var approved int64
+	db.Model(&models.MatchParticipant{}).Where("user_id = ? AND status = ?", 2, "approved").Count(&approved)
+	assert.Equal(t, int64(3), approved)
+
+	// 單獨取消的場次不會被重新產生
+	assert.NoError(t, db.Model(&matches[1]).Update("status", "cancelled").Error)
+	created, err = GenerateSeries(db, &series, now)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), created)
+
+	// 改為每兩天一次後，不再符合規則的場次被取消，之後的場次依新規則產生
+	series.Rule = "FREQ=DAILY;INTERVAL=2"
+	series.DurationMinutes = 90
+	assert.NoError(t, db.Save(&series).Error)
+	cancelled, err := RescheduleSeries(db, &series, now)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), cancelled, "第二場已被單獨取消，第一、三場仍符合新規則")
+
+	var third models.Match
+	db.First(&third, matches[2].ID)
+	assert.Equal(t, "open", third.Status)
+	assert.Equal(t, 90*time.Minute, third.EndTime.Sub(third.MatchTime))
+
+	series.Rule = "FREQ=WEEKLY"
+	cancelled, err = RescheduleSeries(db, &series, now)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), cancelled)
+
+	var detached models.Match
+	db.First(&detached, matches[2].ID)
+	assert.Equal(t, "cancelled", detached.Status)
+	assert.Nil(t, detached.SeriesSlot)
+}
+
+func TestGenerateSeriesRespectsQuotas(t *testing.T) {
+	db := setupTestDatabase(t)
+	now := time.Now()
+
+	activity := models.Activity{Title: "晨跑", TargetCount: 2, LocationID: 1, CreatedBy: 1, DurationMinutes: 60}
+	assert.NoError(t, db.Create(&activity).Error)
+	organizer := models.User{SocialID: "organizer", SocialProvider: "facebook", Name: "organizer", Email: "organizer@example.com"}
+	partner := models.User{SocialID: "partner", SocialProvider: "facebook", Name: "partner", Email: "partner@example.com"}
+	assert.NoError(t, db.Create(&organizer).Error)
+	assert.NoError(t, db.Create(&partner).Error)
+
+	// 開局者最多同時開 2 場，夥伴 24 小時內只能參與 1 場
+	two, one := 2, 1
+	assert.NoError(t, db.Create(&models.UserQuotaOverride{UserID: organizer.ID, OpenMatches: &two, Note: "測試", UpdatedAt: now}).Error)
+	assert.NoError(t, db.Create(&models.UserQuotaOverride{UserID: partner.ID, DailyJoins: &one, Note: "測試", UpdatedAt: now}).Error)
+
+	series := models.MatchSeries{
+		ActivityID:  activity.ID,
+		OrganizerID: organizer.ID,
+		Rule:        "FREQ=DAILY",
+		StartTime:   now.Add(time.Hour),
+		Until:       now.AddDate(0, 1, 0),
+		Timezone:    "Asia/Taipei",
+		DaysAhead:   5,
+		Status:      "active",
+	}
+	assert.NoError(t, db.Create(&series).Error)
+	assert.NoError(t, db.Create(&models.MatchSeriesPartner{SeriesID: series.ID, UserID: partner.ID}).Error)
+	assert.NoError(t, db.Preload("Activity").Preload("Partners").First(&series, series.ID).Error)
+
+	created, err := GenerateSeries(db, &series, now)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), created, "超過開局配額後停止產生")
+
+	var joined int64
+	db.Model(&models.MatchParticipant{}).Where("user_id = ?", partner.ID).Count(&joined)
+	assert.Equal(t, int64(1), joined, "超過參與配額的夥伴不加入")
+
+	// 有名額後下一次執行會補上未產生的場次
+	var first models.Match
+	assert.NoError(t, db.Where("series_id = ?", series.ID).Order("match_time").First(&first).Error)
+	assert.NoError(t, db.Model(&first).Update("status", "cancelled").Error)
+	created, err = GenerateSeries(db, &series, now)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), created)
+}
+
+func TestGenerateSeriesPartnersFollowJoinRules(t *testing.T) {
+	db := setupTestDatabase(t)
+	now := time.Now()
+
+	activity := models.Activity{Title: "晨跑", TargetCount: 1, LocationID: 1, CreatedBy: 1, DurationMinutes: 60}
+	assert.NoError(t, db.Create(&activity).Error)
+	for _, name := range []string{"organizer", "partner", "blocked", "late"} {
+		assert.NoError(t, db.Create(&models.User{SocialID: name, SocialProvider: "facebook", Name: name, Email: name + "@example.com"}).Error)
+	}
+	assert.NoError(t, db.Create(&models.OrganizerBlock{OrganizerID: 1, UserID: 3, CreatedAt: now}).Error)
+
+	series := models.MatchSeries{
+		ActivityID:  activity.ID,
+		OrganizerID: 1,
+		Rule:        "FREQ=DAILY",
+		StartTime:   now.Add(time.Hour),
+		Until:       now.AddDate(0, 1, 0),
+		Timezone:    "Asia/Taipei",
+		DaysAhead:   1,
+		Status:      "active",
+	}
+	assert.NoError(t, db.Create(&series).Error)
+	for _, userID := range []int64{2, 3, 4} {
+		assert.NoError(t, db.Create(&models.MatchSeriesPartner{SeriesID: series.ID, UserID: userID}).Error)
+	}
+	assert.NoError(t, db.Preload("Activity").Preload("Partners").First(&series, series.ID).Error)
+
+	created, err := GenerateSeries(db, &series, now)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), created)
+
+	var participants []models.MatchParticipant
+	db.Order("user_id").Find(&participants)
+	statuses := map[int64]string{}
+	for _, p := range participants {
+		statuses[p.UserID] = p.Status
+	}
+	// 被封鎖的夥伴不加入，名額已滿時留給開局者審核
+	assert.Equal(t, map[int64]string{2: "approved", 4: "pending"}, statuses)
+}
+
+func TestRescheduleSeriesNotifiesCancelledOccurrences(t *testing.T) {
+	db := setupTestDatabase(t)
+	now := time.Now()
+
+	activity := models.Activity{Title: "晨跑", TargetCount: 2, LocationID: 1, CreatedBy: 1, DurationMinutes: 60}
+	assert.NoError(t, db.Create(&activity).Error)
+	for _, name := range []string{"organizer", "partner"} {
+		assert.NoError(t, db.Create(&models.User{SocialID: name, SocialProvider: "facebook", Name: name, Email: name + "@example.com"}).Error)
+	}
+	series := models.MatchSeries{
+		ActivityID:  activity.ID,
+		OrganizerID: 1,
+		Rule:        "FREQ=DAILY",
+		StartTime:   now.Add(time.Hour),
+		Until:       now.AddDate(0, 1, 0),
+		Timezone:    "Asia/Taipei",
+		DaysAhead:   2,
+		Status:      "active",
+	}
+	assert.NoError(t, db.Create(&series).Error)
+	assert.NoError(t, db.Create(&models.MatchSeriesPartner{SeriesID: series.ID, UserID: 2}).Error)
+	assert.NoError(t, db.Preload("Activity").Preload("Partners").First(&series, series.ID).Error)
+	_, err := GenerateSeries(db, &series, now)
+	assert.NoError(t, err)
+
+	series.Rule = "FREQ=DAILY;INTERVAL=2"
+	cancelled, err := RescheduleSeries(db, &series, now)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), cancelled)
+
+	// 被取消的場次記錄原因，開局者與固定夥伴都收到通知
+	var match models.Match
+	assert.NoError(t, db.Where("series_id = ? AND status = ?", series.ID, "cancelled").First(&match).Error)
+	assert.Equal(t, "場次已不在系列的重複規則中", match.CancelReason)
+	assert.Nil(t, match.SeriesSlot)
+	var notified []int64
+	db.Model(&models.Notification{}).Where("match_id = ? AND type = ?", match.ID, models.NotificationMatchCancelled).Order("user_id").Pluck("user_id", &notified)
+	assert.Equal(t, []int64{1, 2}, notified)
+}
+
+func TestRecordAttendance(t *testing.T) {
+	db := setupTestDatabase(t)
+	ctx := context.Background()
+
+	ended := time.Now().Add(-models.AttendanceReportWindow - time.Hour)
+	checkedIn := ended.Add(-2 * time.Hour)
+	usedCheckIn := models.Match{ActivityID: 1, OrganizerID: 1, MatchTime: ended.Add(-2 * time.Hour), EndTime: ended, Status: "completed"}
+	noCheckIn := models.Match{ActivityID: 1, OrganizerID: 1, MatchTime: ended.Add(-2 * time.Hour), EndTime: ended, Status: "completed"}
+	// 仍在開局者回報期限內的配對局不處理
+	recent := models.Match{ActivityID: 1, OrganizerID: 1, MatchTime: time.Now().Add(-3 * time.Hour), EndTime: time.Now().Add(-time.Hour), Status: "completed"}
+	for _, match := range []*models.Match{&usedCheckIn, &noCheckIn, &recent} {
+		assert.NoError(t, db.Create(match).Error)
+	}
+
+	participants := []*models.MatchParticipant{
+		{MatchID: usedCheckIn.ID, UserID: 2, Status: "approved", JoinedAt: ended, CheckedInAt: &checkedIn},
+		{MatchID: usedCheckIn.ID, UserID: 3, Status: "approved", JoinedAt: ended},
+		{MatchID: usedCheckIn.ID, UserID: 4, Status: "late_cancel", JoinedAt: ended},
+		{MatchID: noCheckIn.ID, UserID: 2, Status: "approved", JoinedAt: ended},
+		{MatchID: recent.ID, UserID: 2, Status: "approved", JoinedAt: ended, CheckedInAt: &checkedIn},
+	}
+	for _, p := range participants {
+		assert.NoError(t, db.Create(p).Error)
+	}
+
+	updated, err := RecordAttendance(ctx, db)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), updated)
+
+	expected := []string{"attended", "no_show", "late_cancel", "approved", "approved"}
+	for i, p := range participants {
+		var got models.MatchParticipant
+		assert.NoError(t, db.First(&got, p.ID).Error)
+		assert.Equal(t, expected[i], got.Status, "participant %d", i)
+	}
+}
+
+func TestReleaseUnconfirmed(t *testing.T) {
+	db := setupTestDatabase(t)
+	ctx := context.Background()
+
+	match := models.Match{ActivityID: 1, OrganizerID: 1, MatchTime: time.Now().Add(2 * time.Hour), EndTime: time.Now().Add(4 * time.Hour), Status: "open"}
+	assert.NoError(t, db.Create(&match).Error)
+
+	passed := time.Now().Add(-time.Minute)
+	pending := time.Now().Add(time.Hour)
+	participants := []*models.MatchParticipant{
+		{MatchID: match.ID, UserID: 2, Status: "reconfirm", JoinedAt: time.Now(), ReconfirmDeadline: &passed},
+		{MatchID: match.ID, UserID: 3, Status: "reconfirm", JoinedAt: time.Now(), ReconfirmDeadline: &pending},
+		{MatchID: match.ID, UserID: 4, Status: "approved", JoinedAt: time.Now()},
+	}
+	for _, p := range participants {
+		assert.NoError(t, db.Create(p).Error)
+	}
+
+	released, err := ReleaseUnconfirmed(ctx, db)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), released)
+
+	expected := []string{"released", "reconfirm", "approved"}
+	for i, p := range participants {
+		var got models.MatchParticipant
+		assert.NoError(t, db.First(&got, p.ID).Error)
+		assert.Equal(t, expected[i], got.Status, "participant %d", i)
+	}
+
+	var notifications []models.Notification
+	assert.NoError(t, db.Find(&notifications).Error)
+	if assert.Len(t, notifications, 1) {
+		assert.Equal(t, int64(2), notifications[0].UserID)
+		assert.Equal(t, models.NotificationReleased, notifications[0].Type)
+	}
+}
+
+func TestArchiveActivities(t *testing.T) {
+	db := setupTestDatabase(t)
+	ctx := context.Background()
+
+	ended := time.Now().Add(-time.Hour)
+	expired := models.Activity{Title: "夏季買一送一", TargetCount: 2, LocationID: 1, CreatedBy: 1, EndsAt: &ended}
+	ongoing := models.Activity{Title: "常態優惠", TargetCount: 2, LocationID: 1, CreatedBy: 1}
+	assert.NoError(t, db.Create(&expired).Error)
+	assert.NoError(t, db.Create(&ongoing).Error)
+
+	future := time.Now().Add(24 * time.Hour)
+	past := time.Now().Add(-3 * time.Hour)
+	upcoming := models.Match{ActivityID: expired.ID, OrganizerID: 1, MatchTime: future, EndTime: future.Add(2 * time.Hour), Status: "open"}
+	// 已開始的配對局交給完成或過期的工作處理
+	started := models.Match{ActivityID: expired.ID, OrganizerID: 1, MatchTime: past, EndTime: past.Add(2 * time.Hour), Status: "open"}
+	other := models.Match{ActivityID: ongoing.ID, OrganizerID: 1, MatchTime: future, EndTime: future.Add(2 * time.Hour), Status: "open"}
+	assert.NoError(t, db.Create(&upcoming).Error)
+	assert.NoError(t, db.Create(&started).Error)
+	assert.NoError(t, db.Create(&other).Error)
+	assert.NoError(t, db.Create(&models.MatchParticipant{MatchID: upcoming.ID, UserID: 2, Status: "approved", JoinedAt: past}).Error)
+	assert.NoError(t, db.Create(&models.MatchParticipant{MatchID: upcoming.ID, UserID: 3, Status: "rejected", JoinedAt: past}).Error)
+
+	archived, err := ArchiveActivities(ctx, db)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), archived)
+
+	var archivedActivity, ongoingActivity models.Activity
+	assert.NoError(t, db.First(&archivedActivity, expired.ID).Error)
+	assert.NotNil(t, archivedActivity.ArchivedAt)
+	assert.NoError(t, db.First(&ongoingActivity, ongoing.ID).Error)
+	assert.Nil(t, ongoingActivity.ArchivedAt)
+
+	for id, status := range map[int64]string{upcoming.ID: "cancelled", started.ID: "open", other.ID: "open"} {
+		var match models.Match
+		assert.NoError(t, db.First(&match, id).Error)
+		assert.Equal(t, status, match.Status)
+	}
+	var cancelled models.Match
+	assert.NoError(t, db.First(&cancelled, upcoming.ID).Error)
+	assert.Contains(t, cancelled.CancelReason, "夏季買一送一")
+
+	// 開局者與已審核通過的參與者收到通知，被拒絕的申請者不通知
+	var notified []int64
+	assert.NoError(t, db.Model(&models.Notification{}).Where("type = ?", models.NotificationMatchCancelled).Order("user_id").Pluck("user_id", &notified).Error)
+	assert.Equal(t, []int64{1, 2}, notified)
+
+	var event models.MatchEvent
+	assert.NoError(t, db.Where("match_id = ?", upcoming.ID).First(&event).Error)
+	assert.Equal(t, "cancelled", event.ToStatus)
+	assert.Equal(t, cancelled.CancelReason, event.Reason)
+
+	// 已封存的活動不會重複處理
+	archived, err = ArchiveActivities(ctx, db)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), archived)
+}
@@ -0,0 +1,43 @@
+package jobs
+
+import (
+	"time"
+
+	"free2free/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AcquireLock 嘗試取得指定名稱的資料庫鎖
+// 鎖已過期或本來就由同一個 holder 持有時會成功，並把期限延長到 ttl 之後
+func AcquireLock(db *gorm.DB, name, holder string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	until := now.Add(ttl)
+
+	// 先嘗試接手已過期或自己持有的鎖
+	result := db.Model(&models.JobLock{}).
+		Where("name = ? AND (locked_until < ? OR holder = ?)", name, now, holder).
+		Updates(map[string]interface{}{"holder": holder, "locked_until": until})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected > 0 {
+		return true, nil
+	}
+
+	// 鎖不存在時建立；若其他副本搶先建立則視為取得失敗
+	lock := models.JobLock{Name: name, Holder: holder, LockedUntil: until}
+	result = db.Clauses(clause.OnConflict{DoNothing: true}).Create(&lock)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// ReleaseLock 釋放由 holder 持有的鎖
+func ReleaseLock(db *gorm.DB, name, holder string) error {
+	return db.Model(&models.JobLock{}).
+		Where("name = ? AND holder = ?", name, holder).
+		Update("locked_until", time.Now()).Error
+}
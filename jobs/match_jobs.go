@@ -0,0 +1,116 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"free2free/models"
+
+	"gorm.io/gorm"
+)
+
+// approvedParticipantExists 判斷配對局是否有佔用名額的參與者
+const approvedParticipantExists = "EXISTS (SELECT 1 FROM match_participants mp WHERE mp.match_id = matches.id AND mp.status IN ?)"
+
+// seatedStatuses 佔用名額的參與狀態，等待重新確認的參與者仍保留名額
+var seatedStatuses = []string{"approved", "reconfirm"}
+
+// CompleteMatches 將已結束且有已審核通過參與者的配對局標記為 completed
+func CompleteMatches(ctx context.Context, db *gorm.DB) (int64, error) {
+	now := time.Now()
+	return transitionMatches(db.WithContext(ctx), func(q *gorm.DB) *gorm.DB {
+		return q.Where("end_time <= ?", now).Where(approvedParticipantExists, seatedStatuses)
+	}, "open", "completed", "配對局已結束")
+}
+
+// ExpireMatches 將已開始但沒有任何已審核通過 (或等待重新確認) 參與者的配對局標記為 expired
+func ExpireMatches(ctx context.Context, db *gorm.DB) (int64, error) {
+	now := time.Now()
+	return transitionMatches(db.WithContext(ctx), func(q *gorm.DB) *gorm.DB {
+		return q.Where("match_time <= ?", now).Where("NOT "+approvedParticipantExists, seatedStatuses)
+	}, "open", "expired", "配對局開始時沒有已審核通過的參與者")
+}
+
+// RecordAttendance 在開局者回報出席狀況的期限過後，依報到紀錄記錄仍為 approved 的參與者：
+// 有報到的標記為 attended；配對局有人報到 (表示有使用報到) 時，未報到的標記為 no_show
+func RecordAttendance(ctx context.Context, db *gorm.DB) (int64, error) {
+	db = db.WithContext(ctx)
+	finished := db.Model(&models.Match{}).
+		Select("id").
+		Where("status = ? AND end_time <= ?", "completed", time.Now().Add(-models.AttendanceReportWindow))
+
+	attended, err := transitionParticipants(db, func(q *gorm.DB) *gorm.DB {
+		return q.Where("match_id IN (?) AND checked_in_at IS NOT NULL", finished)
+	}, "approved", "attended", "依報到紀錄自動記錄")
+	if err != nil {
+		return 0, err
+	}
+
+	// MySQL 不允許在更新 match_participants 時以子查詢讀取同一張表，先取出仍有未報到參與者且有人報到的配對局
+	var remaining []int64
+	err = db.Model(&models.MatchParticipant{}).
+		Distinct("match_id").
+		Where("match_id IN (?) AND status = ?", finished, "approved").
+		Pluck("match_id", &remaining).Error
+	if err != nil || len(remaining) == 0 {
+		return attended, err
+	}
+
+	var usedCheckIn []int64
+	err = db.Model(&models.MatchParticipant{}).
+		Distinct("match_id").
+		Where("match_id IN ? AND checked_in_at IS NOT NULL", remaining).
+		Pluck("match_id", &usedCheckIn).Error
+	if err != nil || len(usedCheckIn) == 0 {
+		return attended, err
+	}
+
+	noShow, err := transitionParticipants(db, func(q *gorm.DB) *gorm.DB {
+		return q.Where("match_id IN ?", usedCheckIn)
+	}, "approved", "no_show", "依報到紀錄自動記錄")
+	return attended + noShow, err
+}
+
+// ReleaseUnconfirmed 將超過重新確認期限仍未回覆的參與者標記為 released，釋出名額並通知他們
+func ReleaseUnconfirmed(ctx context.Context, db *gorm.DB) (int64, error) {
+	db = db.WithContext(ctx)
+	now := time.Now()
+
+	var participants []models.MatchParticipant
+	if err := db.Where("status = ? AND reconfirm_deadline <= ?", "reconfirm", now).Find(&participants).Error; err != nil {
+		return 0, err
+	}
+
+	var released int64
+	for _, p := range participants {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			// 只更新仍在等待確認的紀錄，參與者可能剛好回覆
+			result := tx.Model(&models.MatchParticipant{}).
+				Where("id = ? AND status = ?", p.ID, "reconfirm").
+				Updates(map[string]interface{}{"status": "released", "reconfirm_deadline": nil})
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+			event := models.NewParticipantEvent(&p, "reconfirm", "released", 0, "超過重新確認期限")
+			if err := tx.Create(&event).Error; err != nil {
+				return err
+			}
+			notification := models.Notification{
+				UserID:    p.UserID,
+				Type:      models.NotificationReleased,
+				MatchID:   &p.MatchID,
+				Message:   "您未在期限內確認變更後的配對局，名額已被釋出",
+				CreatedAt: now,
+			}
+			if err := tx.Create(&notification).Error; err != nil {
+				return err
+			}
+			released++
+			return nil
+		})
+		if err != nil {
+			return released, err
+		}
+	}
+	return released, nil
+}
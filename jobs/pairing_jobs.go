@@ -0,0 +1,277 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"free2free/models"
+	"free2free/pairing"
+	"free2free/reliability"
+
+	"gorm.io/gorm"
+)
+
+// PairingLeadTime 自動配對產生的配對局最早在多久之後開始，讓使用者有時間前往
+const PairingLeadTime = 15 * time.Minute
+
+// PairIntents 將過期的意向標記為 expired，並把相容的意向配成配對局
+func PairIntents(ctx context.Context, db *gorm.DB) (int64, error) {
+	db = db.WithContext(ctx)
+	now := time.Now()
+
+	err := db.Model(&models.PairingIntent{}).
+		Where("status = ? AND window_end <= ?", "open", now).
+		Update("status", "expired").Error
+	if err != nil {
+		return 0, err
+	}
+
+	var intents []models.PairingIntent
+	if err := db.Where("status = ?", "open").Order("created_at, id").Find(&intents).Error; err != nil {
+		return 0, err
+	}
+	if len(intents) < 2 {
+		return 0, nil
+	}
+
+	candidates, venues, err := pairingCandidates(db, intents)
+	if err != nil {
+		return 0, err
+	}
+
+	var created int64
+	for _, pair := range pairing.Match(candidates, venues, now, PairingLeadTime) {
+		ok, err := createPairedMatch(db, pair)
+		if err != nil {
+			return created, err
+		}
+		if ok {
+			created++
+		}
+	}
+	return created, nil
+}
+
+// PairIntent 只為剛建立的意向從時段重疊的其他意向中尋找對象，供建立意向時立即配對；
+// 其他意向彼此之間的配對與過期由背景工作 pair_intents 處理。意向已不是 open 或沒有相容的對象時回傳 false
+func PairIntent(ctx context.Context, db *gorm.DB, intentID int64) (bool, error) {
+	db = db.WithContext(ctx)
+	now := time.Now()
+
+	var intent models.PairingIntent
+	if err := db.Where("id = ? AND status = ?", intentID, "open").First(&intent).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	var others []models.PairingIntent
+	err := db.Where("status = ? AND user_id <> ? AND window_end > ?", "open", intent.UserID, now).
+		Where("window_start < ? AND window_end > ?", intent.WindowEnd, intent.WindowStart).
+		Order("created_at, id").
+		Find(&others).Error
+	if err != nil || len(others) == 0 {
+		return false, err
+	}
+
+	candidates, venues, err := pairingCandidates(db, append([]models.PairingIntent{intent}, others...))
+	if err != nil {
+		return false, err
+	}
+	pair, ok := pairing.MatchOne(candidates[0], candidates[1:], venues, now, PairingLeadTime)
+	if !ok {
+		return false, nil
+	}
+	return createPairedMatch(db, pair)
+}
+
+// pairingCandidates 將意向轉換為配對用的資料，並載入相關的地點、出席可信度與封鎖關係
+func pairingCandidates(db *gorm.DB, intents []models.PairingIntent) ([]pairing.Intent, []pairing.Venue, error) {
+	venues, err := intentVenues(db, intents)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	userIDs := make([]int64, len(intents))
+	for i, intent := range intents {
+		userIDs[i] = intent.UserID
+	}
+	records, err := reliability.Load(db, userIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	blocked, err := blockedPairs(db, userIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	candidates := make([]pairing.Intent, len(intents))
+	for i, intent := range intents {
+		candidates[i] = pairing.Intent{
+			ID:            intent.ID,
+			UserID:        intent.UserID,
+			Chain:         intent.Chain,
+			Latitude:      intent.Latitude,
+			Longitude:     intent.Longitude,
+			MaxDistanceKm: intent.MaxDistanceKm,
+			WindowStart:   intent.WindowStart,
+			WindowEnd:     intent.WindowEnd,
+			Reliability:   records[intent.UserID].Score,
+			Blocked:       blocked[intent.UserID],
+			CreatedAt:     intent.CreatedAt,
+		}
+		if intent.ActivityID != nil {
+			candidates[i].ActivityID = *intent.ActivityID
+		}
+	}
+	return candidates, venues, nil
+}
+
+// intentVenues 取得意向指定的活動，以及地點名稱符合意向連鎖店的所有活動
+func intentVenues(db *gorm.DB, intents []models.PairingIntent) ([]pairing.Venue, error) {
+	activityIDs := []int64{}
+	chains := []string{}
+	for _, intent := range intents {
+		if intent.ActivityID != nil {
+			activityIDs = append(activityIDs, *intent.ActivityID)
+		} else if intent.Chain != "" {
+			chains = append(chains, intent.Chain)
+		}
+	}
+
+	var activities []models.Activity
+	err := db.Preload("Location").Preload("Availability").
+		Where("id IN ? OR location_id IN (?)", activityIDs, db.Model(&models.Location{}).Select("id").Where("name IN ?", chains)).
+		Where("archived_at IS NULL").
+		Find(&activities).Error
+	if err != nil {
+		return nil, err
+	}
+
+	venues := make([]pairing.Venue, len(activities))
+	for i := range activities {
+		activity := &activities[i]
+		venues[i] = pairing.Venue{
+			ActivityID: activity.ID,
+			Chain:      activity.Location.Name,
+			Latitude:   activity.Location.Latitude,
+			Longitude:  activity.Location.Longitude,
+			Duration:   activity.MatchDuration(),
+			// 只配到活動優惠期間與每週可用時段內的時間，避免同一組不符合的配對一再被選中
+			Available: func(start, end time.Time) bool {
+				return activity.CheckSchedule(start, end) == nil
+			},
+		}
+	}
+	return venues, nil
+}
+
+// blockedPairs 回傳使用者之間的封鎖關係；開局者封鎖參與者後，雙方都不會再被配在一起
+func blockedPairs(db *gorm.DB, userIDs []int64) (map[int64]map[int64]bool, error) {
+	var blocks []models.OrganizerBlock
+	if err := db.Where("organizer_id IN ? AND user_id IN ?", userIDs, userIDs).Find(&blocks).Error; err != nil {
+		return nil, err
+	}
+	blocked := make(map[int64]map[int64]bool)
+	mark := func(a, b int64) {
+		if blocked[a] == nil {
+			blocked[a] = make(map[int64]bool)
+		}
+		blocked[a][b] = true
+	}
+	for _, block := range blocks {
+		mark(block.OrganizerID, block.UserID)
+		mark(block.UserID, block.OrganizerID)
+	}
+	return blocked, nil
+}
+
+// createPairedMatch 在交易中建立配對局，兩位使用者都以 approved 加入並收到通知；
+// 意向只在仍為 open 時更新，已被其他執行配對或取消時回傳 false
+func createPairedMatch(db *gorm.DB, pair pairing.Pair) (bool, error) {
+	created := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		match := models.Match{
+			ActivityID:  pair.Venue.ActivityID,
+			OrganizerID: pair.A.UserID,
+			MatchTime:   pair.Start,
+			EndTime:     pair.End,
+			Status:      "open",
+		}
+		if err := tx.Create(&match).Error; err != nil {
+			return err
+		}
+		event := models.NewMatchEvent(match.ID, "", "open", 0, "依配對意向自動建立")
+		if err := tx.Create(&event).Error; err != nil {
+			return err
+		}
+
+		result := tx.Model(&models.PairingIntent{}).
+			Where("id IN ? AND status = ?", []int64{pair.A.ID, pair.B.ID}, "open").
+			Updates(map[string]interface{}{"status": "matched", "match_id": match.ID})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 2 {
+			return errPairingConflict
+		}
+
+		// 配對時已排除互相封鎖的使用者，這裡防止兩者之間新增的封鎖
+		var blocks int64
+		err := tx.Model(&models.OrganizerBlock{}).
+			Where("(organizer_id = ? AND user_id = ?) OR (organizer_id = ? AND user_id = ?)", pair.A.UserID, pair.B.UserID, pair.B.UserID, pair.A.UserID).
+			Count(&blocks).Error
+		if err != nil {
+			return err
+		}
+		if blocks > 0 {
+			return errPairingConflict
+		}
+
+		var activity models.Activity
+		if err := tx.Preload("Availability").First(&activity, match.ActivityID).Error; err != nil {
+			return err
+		}
+		// 配對時已依可用時段篩選，這裡防止活動設定在兩者之間被修改
+		if activity.CheckSchedule(match.MatchTime, match.EndTime) != nil {
+			return errPairingConflict
+		}
+		message := fmt.Sprintf("已為您配對到「%s」，時間 %s", activity.Title, pair.Start.In(pairingLocation()).Format("2006-01-02 15:04"))
+
+		for _, userID := range []int64{pair.A.UserID, pair.B.UserID} {
+			participant := models.MatchParticipant{MatchID: match.ID, UserID: userID, Status: "approved", JoinedAt: time.Now()}
+			if err := tx.Create(&participant).Error; err != nil {
+				return err
+			}
+			event := models.NewParticipantEvent(&participant, "", "approved", 0, "依配對意向自動加入")
+			if err := tx.Create(&event).Error; err != nil {
+				return err
+			}
+			notification := models.Notification{UserID: userID, Type: models.NotificationPairingMatched, MatchID: &match.ID, Message: message, CreatedAt: time.Now()}
+			if err := tx.Create(&notification).Error; err != nil {
+				return err
+			}
+		}
+		created = true
+		return nil
+	})
+	if errors.Is(err, errPairingConflict) {
+		return false, nil
+	}
+	return created, err
+}
+
+// errPairingConflict 意向已不是 open、時間不符合活動的可用時段或雙方之間有封鎖，回滾這次配對
+var errPairingConflict = errors.New("pairing intent is no longer open")
+
+// pairingLocation 通知中顯示時間使用的時區
+func pairingLocation() *time.Location {
+	loc, err := time.LoadLocation(DefaultSeriesTimezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
@@ -0,0 +1,116 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"free2free/models"
+
+	"gorm.io/gorm"
+)
+
+// Job 定義一個週期性執行的背景工作
+// Run 回傳受影響的資料筆數，會記錄在 JobRun 中
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context, db *gorm.DB) (int64, error)
+}
+
+// Scheduler 負責依照間隔執行已註冊的背景工作
+type Scheduler struct {
+	db     *gorm.DB
+	holder string
+	jobs   []Job
+	wg     sync.WaitGroup
+}
+
+// NewScheduler 建立新的排程器，holder 用來識別目前的副本
+func NewScheduler(db *gorm.DB) *Scheduler {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return &Scheduler{
+		db:     db,
+		holder: fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+	}
+}
+
+// Register 註冊背景工作
+func (s *Scheduler) Register(jobs ...Job) {
+	s.jobs = append(s.jobs, jobs...)
+}
+
+// Start 為每個工作啟動 goroutine，直到 ctx 被取消
+func (s *Scheduler) Start(ctx context.Context) {
+	for _, job := range s.jobs {
+		s.wg.Add(1)
+		go func(job Job) {
+			defer s.wg.Done()
+			s.loop(ctx, job)
+		}(job)
+	}
+}
+
+// Wait 等待所有工作 goroutine 結束
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
+
+func (s *Scheduler) loop(ctx context.Context, job Job) {
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.RunOnce(ctx, job); err != nil {
+			log.Printf("背景工作 %s 執行失敗: %v", job.Name, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce 嘗試取得鎖並執行一次工作
+// 鎖的期限等於工作間隔，所以同一個間隔內所有副本中最多只會執行一次；
+// 未取得鎖時回傳 false 且不記錄執行紀錄
+func (s *Scheduler) RunOnce(ctx context.Context, job Job) (bool, error) {
+	db := s.db.WithContext(ctx)
+
+	acquired, err := AcquireLock(db, job.Name, s.holder, job.Interval)
+	if err != nil {
+		return false, fmt.Errorf("取得鎖失敗: %w", err)
+	}
+	if !acquired {
+		return false, nil
+	}
+
+	run := models.JobRun{
+		JobName:   job.Name,
+		Holder:    s.holder,
+		StartedAt: time.Now(),
+	}
+
+	affected, runErr := job.Run(ctx, db)
+	run.FinishedAt = time.Now()
+	run.Affected = affected
+	run.Status = "success"
+	if runErr != nil {
+		run.Status = "failed"
+		run.Error = runErr.Error()
+	}
+
+	if err := db.Create(&run).Error; err != nil {
+		log.Printf("無法記錄背景工作 %s 的執行結果: %v", job.Name, err)
+	}
+
+	return true, runErr
+}
@@ -0,0 +1,258 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"free2free/models"
+	"free2free/quota"
+	"free2free/recurrence"
+	"free2free/reliability"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultSeriesTimezone 系列未設定時區時展開規則使用的時區
+const DefaultSeriesTimezone = "Asia/Taipei"
+
+// GenerateSeriesMatches 為所有進行中的週期系列產生未來 DaysAhead 天內的配對局
+func GenerateSeriesMatches(ctx context.Context, db *gorm.DB) (int64, error) {
+	var series []models.MatchSeries
+	err := db.WithContext(ctx).
+		Preload("Activity.Availability").
+		Preload("Partners").
+		Where("status = ? AND until > ?", "active", time.Now()).
+		Find(&series).Error
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for i := range series {
+		created, err := GenerateSeries(db.WithContext(ctx), &series[i], time.Now())
+		if err != nil {
+			return total, err
+		}
+		total += created
+	}
+	return total, nil
+}
+
+// GenerateSeries 產生單一系列從 now 起 DaysAhead 天內尚未存在的配對局
+// 已存在的 slot (包含被單獨取消的場次) 不會重新產生；
+// 自動核准名單中的使用者依一般參與流程加入新產生的配對局並自動通過 (見 addSeriesPartner)；
+// 開局者超過開局配額時停止產生；
+// 不在活動優惠期間或每週可用時段內的 slot 不產生
+// 呼叫前需預加載 Activity (包含 Availability) 與 Partners
+func GenerateSeries(db *gorm.DB, series *models.MatchSeries, now time.Time) (int64, error) {
+	// 活動已封存時不再產生新的場次
+	if series.Activity.ArchivedAt != nil {
+		return 0, nil
+	}
+	horizon := seriesHorizon(series, now)
+	slots, err := seriesSlots(series, now, horizon)
+	if err != nil {
+		return 0, err
+	}
+
+	var created int64
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for _, slot := range slots {
+			match := newSeriesMatch(series, slot)
+			if series.Activity.CheckSchedule(match.MatchTime, match.EndTime) != nil {
+				continue
+			}
+			// 開局者超過開局配額時暫停產生，之後有名額時下一次執行會補上
+			if err := quota.CheckCreateMatch(tx, series.OrganizerID); err != nil {
+				if errors.As(err, new(*quota.ExceededError)) {
+					break
+				}
+				return err
+			}
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&match)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				continue
+			}
+			created++
+			event := models.NewMatchEvent(match.ID, "", "open", 0, "依週期系列自動產生")
+			if err := tx.Create(&event).Error; err != nil {
+				return err
+			}
+
+			for _, partner := range series.Partners {
+				if partner.UserID == series.OrganizerID {
+					continue
+				}
+				if err := addSeriesPartner(tx, series, &match, partner.UserID, now); err != nil {
+					return err
+				}
+			}
+		}
+		return tx.Model(&models.MatchSeries{}).
+			Where("id = ?", series.ID).
+			Update("generated_until", horizon).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	series.GeneratedUntil = horizon
+	return created, nil
+}
+
+// addSeriesPartner 依一般參與流程讓自動核准名單中的夥伴加入新產生的配對局：
+// 被開局者封鎖、出席可信度未達要求或超過參與配額的夥伴這一場不加入；
+// 名額已滿時與一般參與相同，保留在 pending 交由開局者審核
+func addSeriesPartner(tx *gorm.DB, series *models.MatchSeries, match *models.Match, userID int64, now time.Time) error {
+	var blocked int64
+	if err := tx.Model(&models.OrganizerBlock{}).Where("organizer_id = ? AND user_id = ?", match.OrganizerID, userID).Count(&blocked).Error; err != nil {
+		return err
+	}
+	if blocked > 0 {
+		return nil
+	}
+	if match.MinReliability > 0 {
+		records, err := reliability.Load(tx, []int64{userID})
+		if err != nil {
+			return err
+		}
+		if score := records[userID].Score; score != nil && *score < match.MinReliability {
+			return nil
+		}
+	}
+	if err := quota.CheckJoin(tx, userID); err != nil {
+		if errors.As(err, new(*quota.ExceededError)) {
+			return nil
+		}
+		return err
+	}
+
+	participant := models.MatchParticipant{
+		MatchID:  match.ID,
+		UserID:   userID,
+		Status:   "pending",
+		JoinedAt: now,
+	}
+	if err := tx.Create(&participant).Error; err != nil {
+		return err
+	}
+
+	capacity := match.Capacity
+	if capacity <= 0 {
+		capacity = series.Activity.TargetCount
+	}
+	var seated int64
+	if err := tx.Model(&models.MatchParticipant{}).Where("match_id = ? AND status IN ?", match.ID, seatedStatuses).Count(&seated).Error; err != nil {
+		return err
+	}
+	if seated >= int64(capacity) {
+		event := models.NewParticipantEvent(&participant, "", participant.Status, 0, "系列自動核准名單，名額已滿")
+		return tx.Create(&event).Error
+	}
+	if err := tx.Model(&participant).Update("status", "approved").Error; err != nil {
+		return err
+	}
+	participant.Status = "approved"
+	event := models.NewParticipantEvent(&participant, "", participant.Status, 0, "系列自動核准名單")
+	return tx.Create(&event).Error
+}
+
+// RescheduleSeries 在整個系列的規則變更後調整尚未開始的場次
+// 仍符合新規則的場次更新結束時間 (單獨修改過的場次保留原設定)；
+// 不再符合的場次會被取消 (通知開局者與參與者) 並與 slot 脫鉤，讓之後相同時間的場次可以重新產生
+func RescheduleSeries(db *gorm.DB, series *models.MatchSeries, now time.Time) (int64, error) {
+	// 已產生的場次可能超過新的 DaysAhead，因此展開到兩者中較晚的時間
+	horizon := seriesHorizon(series, now)
+	if series.GeneratedUntil.After(horizon) {
+		horizon = series.GeneratedUntil
+	}
+	slots, err := seriesSlots(series, now, horizon.Add(time.Second))
+	if err != nil {
+		return 0, err
+	}
+	valid := make(map[int64]bool, len(slots))
+	for _, slot := range slots {
+		valid[slot.Unix()] = true
+	}
+
+	var cancelled int64
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var upcoming []models.Match
+		err := tx.Where("series_id = ? AND status = ? AND match_time > ?", series.ID, "open", now).
+			Find(&upcoming).Error
+		if err != nil {
+			return err
+		}
+
+		for _, match := range upcoming {
+			if match.SeriesSlot != nil && valid[match.SeriesSlot.Unix()] {
+				if match.SeriesOverride {
+					continue
+				}
+				end := match.MatchTime.Add(series.MatchDuration())
+				if err := tx.Model(&match).Update("end_time", end).Error; err != nil {
+					return err
+				}
+				continue
+			}
+			if err := tx.Model(&match).Update("series_slot", nil).Error; err != nil {
+				return err
+			}
+			if err := CancelMatch(tx, &match, "場次已不在系列的重複規則中", 0, now); err != nil {
+				return err
+			}
+			cancelled++
+		}
+		return nil
+	})
+	return cancelled, err
+}
+
+// seriesHorizon 回傳系列從 now 起應預先產生到的時間
+func seriesHorizon(series *models.MatchSeries, now time.Time) time.Time {
+	daysAhead := series.DaysAhead
+	if daysAhead <= 0 {
+		daysAhead = models.DefaultSeriesDaysAhead
+	}
+	return now.AddDate(0, 0, daysAhead)
+}
+
+// seriesSlots 以系列的時區展開規則，回傳落在 [from, to) 之間的 slot (UTC)
+func seriesSlots(series *models.MatchSeries, from, to time.Time) ([]time.Time, error) {
+	rule, err := recurrence.Parse(series.Rule)
+	if err != nil {
+		return nil, err
+	}
+
+	timezone := series.Timezone
+	if timezone == "" {
+		timezone = DefaultSeriesTimezone
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	slots := rule.Between(series.StartTime.In(loc), series.Until, from, to)
+	for i := range slots {
+		slots[i] = slots[i].UTC()
+	}
+	return slots, nil
+}
+
+func newSeriesMatch(series *models.MatchSeries, slot time.Time) models.Match {
+	seriesID := series.ID
+	return models.Match{
+		ActivityID:  series.ActivityID,
+		OrganizerID: series.OrganizerID,
+		MatchTime:   slot,
+		EndTime:     slot.Add(series.MatchDuration()),
+		Status:      "open",
+		SeriesID:    &seriesID,
+		SeriesSlot:  &slot,
+	}
+}
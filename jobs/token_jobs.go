@@ -0,0 +1,16 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"free2free/models"
+
+	"gorm.io/gorm"
+)
+
+// PurgeExpiredRefreshTokens 刪除已過期的 refresh token
+func PurgeExpiredRefreshTokens(ctx context.Context, db *gorm.DB) (int64, error) {
+	result := db.Where("expires_at < ?", time.Now()).Delete(&models.RefreshToken{})
+	return result.RowsAffected, result.Error
+}
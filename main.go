@@ -24,9 +24,12 @@ import (
 	"gorm.io/gorm"
 
 	"free2free/database"
+	"free2free/geo"
 	"free2free/handlers"
+	"free2free/jobs"
 	"free2free/models"
 	"free2free/routes"
+	"free2free/search"
 
 	apperrors "free2free/errors"
 	middlewarepkg "free2free/middleware"
@@ -59,7 +62,8 @@ func init() {
 		os.Getenv("DB_PASSWORD"),
 		os.Getenv("DB_HOST"),
 		os.Getenv("DB_NAME"))
-	gormDB, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	// TranslateError 讓重複鍵等錯誤轉為 gorm.ErrDuplicatedKey，方便統一回傳 409
+	gormDB, err := gorm.Open(mysql.Open(dsn), &gorm.Config{TranslateError: true})
 	if err != nil {
 		log.Fatal("資料庫連線失敗:", err)
 	}
@@ -67,11 +71,24 @@ func init() {
 	// 設定全局 DB instance
 	database.GlobalDB = &database.ActualGormDB{Conn: gormDB}
 
+	// 使用 MySQL FULLTEXT 作為搜尋索引
+	searchIndex := search.NewMySQLIndex(gormDB)
+	search.SetIndex(searchIndex)
+
 	var migrateOn, _ = strconv.ParseBool(os.Getenv("AUTO_MIGRATE"))
 	if migrateOn {
 		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 		defer cancel()
 
+		// 建立唯一索引前先清除舊資料中的重複紀錄，否則 AutoMigrate 無法建立索引
+		deleted, err := database.DedupeUniqueKeys(database.GlobalDB.Conn.WithContext(ctx))
+		if err != nil {
+			log.Fatal("重複資料清除失敗:", err)
+		}
+		if deleted > 0 {
+			log.Printf("已清除 %d 筆重複資料", deleted)
+		}
+
 		// 自動遷移所有資料表
 		if err := database.GlobalDB.Conn.WithContext(ctx).AutoMigrate(
 			&models.User{},
@@ -80,12 +97,58 @@ func init() {
 			&models.Activity{},
 			&models.Match{},
 			&models.MatchParticipant{},
+			&models.MatchInvite{},
+			&models.MatchMessage{},
+			&models.MatchMessageRead{},
+			&models.MessageReport{},
 			&models.Review{},
 			&models.ReviewLike{},
 			&models.RefreshToken{},
+			&models.CalendarToken{},
+			&models.PairingIntent{},
+			&models.Notification{},
+			&models.MatchChange{},
+			&models.OrganizerBlock{},
+			&models.MatchCoOrganizer{},
+			&models.MatchTransfer{},
+			&models.MatchPrivateDetail{},
+			&models.MatchPrivateAccess{},
+			&models.UserContact{},
+			&models.MatchEvent{},
+			&models.UserQuotaOverride{},
+			&models.ActivityAvailability{},
+			&models.JobLock{},
+			&models.JobRun{},
+			&models.MatchSeries{},
+			&models.MatchSeriesPartner{},
 		); err != nil {
 			log.Fatal("資料表遷移失敗:", err)
 		}
+
+		// 補上舊資料缺少的配對局結束時間
+		if err := database.GlobalDB.Conn.WithContext(ctx).Exec(
+			"UPDATE matches SET end_time = DATE_ADD(match_time, INTERVAL ? MINUTE) WHERE end_time IS NULL",
+			models.DefaultMatchDurationMinutes,
+		).Error; err != nil {
+			log.Fatal("配對局結束時間補值失敗:", err)
+		}
+
+		// 補上舊資料缺少的地點 geohash
+		var locations []models.Location
+		if err := database.GlobalDB.Conn.WithContext(ctx).Where("geohash = ? OR geohash IS NULL", "").Find(&locations).Error; err != nil {
+			log.Fatal("無法取得待補 geohash 的地點:", err)
+		}
+		for _, location := range locations {
+			hash := geo.Encode(location.Latitude, location.Longitude, geo.MaxPrecision)
+			if err := database.GlobalDB.Conn.WithContext(ctx).Model(&location).Update("geohash", hash).Error; err != nil {
+				log.Fatal("地點 geohash 補值失敗:", err)
+			}
+		}
+
+		// 建立搜尋用的 FULLTEXT 索引
+		if err := searchIndex.EnsureIndexes(ctx); err != nil {
+			log.Fatal("搜尋索引建立失敗:", err)
+		}
 	}
 
 	// 設定 OAuth 提供者
@@ -183,7 +246,11 @@ func main() {
 	}
 
 	r := gin.Default()
-	r.Use(cors.Default())
+	// 允許前端讀取分頁相關的 headers
+	corsConfig := cors.DefaultConfig()
+	corsConfig.AllowAllOrigins = true
+	corsConfig.ExposeHeaders = []string{"X-Total-Count", "X-Next-Cursor"}
+	r.Use(cors.New(corsConfig))
 	// 生產環境請鎖域：
 	// config := cors.Config{
 	// 	AllowOrigins: []string{"https://yourdomain.com"},
@@ -223,6 +290,18 @@ func main() {
 	// 設定使用者路由
 	routes.SetupUserRoutes(r)
 
+	// 設定週期系列路由
+	routes.SetupSeriesRoutes(r)
+
+	// 設定配對局對話串路由
+	routes.SetupChatRoutes(r)
+
+	// 設定行事曆路由
+	routes.SetupCalendarRoutes(r)
+
+	// 設定自動配對與通知路由
+	routes.SetupPairingRoutes(r)
+
 	// 設定開局者路由
 	routes.SetupOrganizerRoutes(r)
 
@@ -232,6 +311,16 @@ func main() {
 	// 設定評論點讚/倒讚路由
 	routes.SetupReviewLikeRoutes(r)
 
+	// 設定搜尋路由
+	routes.SetupSearchRoutes(r)
+
+	// 啟動背景工作排程 (可透過 JOBS_DISABLED=true 關閉)
+	if jobsDisabled, _ := strconv.ParseBool(os.Getenv("JOBS_DISABLED")); !jobsDisabled {
+		scheduler := jobs.NewScheduler(database.GlobalDB.Conn)
+		scheduler.Register(jobs.DefaultJobs()...)
+		scheduler.Start(context.Background())
+	}
+
 	// 啟動伺服器
 	r.Run(":8080")
 }
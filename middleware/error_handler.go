@@ -12,8 +12,9 @@ import (
 )
 
 type ErrorResponse struct {
-	Error string `json:"error"`
-	Code  int    `json:"code"`
+	Error     string `json:"error"`
+	Code      int    `json:"code"`
+	ErrorCode string `json:"error_code,omitempty"`
 }
 
 // formatValidationErrors formats validator errors into a readable string
@@ -67,10 +68,12 @@ func ErrorHandler() gin.HandlerFunc {
 			lastErr := c.Errors.Last()
 			var status int
 			var message string
+			var errorCode string
 
 			if appErr, ok := lastErr.Err.(*errors.AppError); ok {
 				status = appErr.Status()
 				message = appErr.Message
+				errorCode = appErr.ErrorCode
 			} else if lastErr.Type == gin.ErrorTypeBind {
 				// Handle binding errors, including validator errors
 				if ve, ok := lastErr.Err.(validator.ValidationErrors); ok {
@@ -89,8 +92,9 @@ func ErrorHandler() gin.HandlerFunc {
 			}
 
 			resp := ErrorResponse{
-				Error: message,
-				Code:  status,
+				Error:     message,
+				Code:      status,
+				ErrorCode: errorCode,
 			}
 
 			// 確保響應是 JSON
@@ -1,6 +1,11 @@
 package models
 
-import "time"
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
 
 type User struct {
 	ID             int64  `gorm:"primaryKey;autoIncrement" json:"id" validate:"-"` // ID auto-generated, no validation
@@ -21,21 +26,159 @@ type Admin struct {
 }
 
 type Activity struct {
-	ID          int64    `gorm:"primaryKey;autoIncrement" json:"id" validate:"-"`
-	Title       string   `json:"title" validate:"required,min=1,max=200"`
-	TargetCount int      `json:"target_count" validate:"required,min=1,max=100"`
-	LocationID  int64    `json:"location_id" validate:"required,min=1"`
-	Description string   `json:"description" validate:"omitempty,max=1000"`
-	CreatedBy   int64    `json:"created_by" validate:"required,min=1"`
-	Location    Location `gorm:"foreignKey:LocationID" json:"location" validate:"-"`
+	ID                  int64    `gorm:"primaryKey;autoIncrement" json:"id" validate:"-"`
+	Title               string   `json:"title" validate:"required,min=1,max=200"`
+	TargetCount         int      `json:"target_count" validate:"required,min=1,max=100"`
+	LocationID          int64    `json:"location_id" validate:"required,min=1"`
+	Description         string   `json:"description" validate:"omitempty,max=1000"`
+	CreatedBy           int64    `json:"created_by" validate:"required,min=1"`
+	DurationMinutes     int      `gorm:"default:120" json:"duration_minutes" validate:"omitempty,min=15,max=1440"`
+	ReviewWindowHours   int      `gorm:"default:4" json:"review_window_hours" validate:"omitempty,min=1,max=168"`
+	CheckInRadiusMeters int      `json:"check_in_radius_meters" validate:"omitempty,min=10,max=5000"` // 0 表示報到時不檢查位置
+	Location            Location `gorm:"foreignKey:LocationID" json:"location" validate:"-"`
+	// 優惠期間，null 表示不限制；配對局必須在期間內開始並結束
+	StartsAt *time.Time `json:"starts_at,omitempty" validate:"-"`
+	EndsAt   *time.Time `gorm:"index" json:"ends_at,omitempty" validate:"-"`
+	// 每週可用時段，沒有設定時不限制時段；時段以 Timezone 的當地時間表示
+	Timezone     string                 `gorm:"size:64;default:Asia/Taipei" json:"timezone" validate:"omitempty,max=64"`
+	Availability []ActivityAvailability `gorm:"foreignKey:ActivityID" json:"availability,omitempty" validate:"omitempty,max=50,dive"`
+	// 優惠結束後由背景工作封存，封存的活動不能再開局
+	ArchivedAt *time.Time `gorm:"index" json:"archived_at,omitempty" validate:"-"`
+}
+
+// ActivityAvailability 活動每週可用的時段，時間為 HH:MM (00:00-24:00)，不支援跨日
+type ActivityAvailability struct {
+	ID         int64  `gorm:"primaryKey;autoIncrement" json:"-" validate:"-"`
+	ActivityID int64  `gorm:"index" json:"-" validate:"-"`
+	Weekday    int    `json:"weekday" validate:"min=0,max=6"` // 0 為星期日
+	StartTime  string `gorm:"size:5" json:"start_time" validate:"required,len=5"`
+	EndTime    string `gorm:"size:5" json:"end_time" validate:"required,len=5"`
+}
+
+// DefaultActivityTimezone 活動未設定時區時判斷可用時段使用的時區
+const DefaultActivityTimezone = "Asia/Taipei"
+
+// 活動未設定時使用的配對局長度 (分鐘) 與結束後可評分的時間 (小時)
+const (
+	DefaultMatchDurationMinutes = 120
+	DefaultReviewWindowHours    = 4
+)
+
+// MatchDuration 回傳此活動配對局的預設長度
+func (a *Activity) MatchDuration() time.Duration {
+	if a.DurationMinutes <= 0 {
+		return DefaultMatchDurationMinutes * time.Minute
+	}
+	return time.Duration(a.DurationMinutes) * time.Minute
+}
+
+// ReviewWindow 回傳配對局結束後可評分的時間長度
+func (a *Activity) ReviewWindow() time.Duration {
+	if a.ReviewWindowHours <= 0 {
+		return DefaultReviewWindowHours * time.Hour
+	}
+	return time.Duration(a.ReviewWindowHours) * time.Hour
+}
+
+// ValidateSchedule 檢查優惠期間、時區與每週可用時段的設定
+func (a *Activity) ValidateSchedule() error {
+	if a.StartsAt != nil && a.EndsAt != nil && !a.EndsAt.After(*a.StartsAt) {
+		return errors.New("優惠結束時間必須晚於開始時間")
+	}
+	if _, err := a.timezone(); err != nil {
+		return fmt.Errorf("無效的時區: %s", a.Timezone)
+	}
+	for _, rule := range a.Availability {
+		start, err := clockOffset(rule.StartTime)
+		if err != nil {
+			return err
+		}
+		end, err := clockOffset(rule.EndTime)
+		if err != nil {
+			return err
+		}
+		if end <= start {
+			return fmt.Errorf("可用時段的結束時間必須晚於開始時間: %s-%s", rule.StartTime, rule.EndTime)
+		}
+	}
+	return nil
+}
+
+// CheckSchedule 確認配對局的時間在活動的優惠期間內，且完整落在某個每週可用時段中
+// 呼叫前需預加載 Availability
+func (a *Activity) CheckSchedule(start, end time.Time) error {
+	loc, err := a.timezone()
+	if err != nil {
+		return err
+	}
+	if a.StartsAt != nil && start.Before(*a.StartsAt) {
+		return fmt.Errorf("活動優惠 %s 才開始", a.StartsAt.In(loc).Format("2006-01-02 15:04"))
+	}
+	if a.EndsAt != nil && end.After(*a.EndsAt) {
+		return fmt.Errorf("活動優惠於 %s 結束", a.EndsAt.In(loc).Format("2006-01-02 15:04"))
+	}
+	if len(a.Availability) == 0 {
+		return nil
+	}
+
+	local := start.In(loc)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	from := local.Sub(midnight)
+	to := from + end.Sub(start)
+	for _, rule := range a.Availability {
+		if rule.Weekday != int(local.Weekday()) {
+			continue
+		}
+		ruleStart, err := clockOffset(rule.StartTime)
+		if err != nil {
+			continue
+		}
+		ruleEnd, err := clockOffset(rule.EndTime)
+		if err != nil {
+			continue
+		}
+		if ruleStart <= from && to <= ruleEnd {
+			return nil
+		}
+	}
+	return errors.New("配對時間不在活動的每週可用時段內")
+}
+
+// timezone 回傳活動的時區，未設定時使用 DefaultActivityTimezone
+func (a *Activity) timezone() (*time.Location, error) {
+	if a.Timezone == "" {
+		return time.LoadLocation(DefaultActivityTimezone)
+	}
+	return time.LoadLocation(a.Timezone)
+}
+
+// clockOffset 將 HH:MM 轉換為距離當天 00:00 的時間，允許 24:00 表示一天結束
+func clockOffset(clock string) (time.Duration, error) {
+	invalid := fmt.Errorf("無效的時間: %s", clock)
+	if len(clock) != 5 || clock[2] != ':' {
+		return 0, invalid
+	}
+	hour, err := strconv.Atoi(clock[:2])
+	if err != nil {
+		return 0, invalid
+	}
+	minute, err := strconv.Atoi(clock[3:])
+	if err != nil {
+		return 0, invalid
+	}
+	if hour < 0 || minute < 0 || minute > 59 || hour > 24 || (hour == 24 && minute != 0) {
+		return 0, invalid
+	}
+	return time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute, nil
 }
 
 type Location struct {
 	ID        int64   `gorm:"primaryKey;autoIncrement" json:"id" validate:"-"`
 	Name      string  `json:"name" validate:"required,min=1,max=100"`
 	Address   string  `json:"address" validate:"required,min=1,max=200"`
-	Latitude  float64 `json:"latitude" validate:"required"`
-	Longitude float64 `json:"longitude" validate:"required"`
+	Latitude  float64 `gorm:"index:idx_lat_lng" json:"latitude" validate:"required"`
+	Longitude float64 `gorm:"index:idx_lat_lng" json:"longitude" validate:"required"`
+	Geohash   string  `gorm:"size:12;index" json:"geohash" validate:"-"`
 }
 
 type Match struct {
@@ -43,26 +186,152 @@ type Match struct {
 	ActivityID  int64     `json:"activity_id" validate:"required,min=1"`
 	OrganizerID int64     `json:"organizer_id" validate:"required,min=1"`
 	MatchTime   time.Time `json:"match_time" validate:"required"`
-	Status      string    `json:"status" validate:"required,oneof=open completed cancelled"`
-	Activity    Activity  `gorm:"foreignKey:ActivityID" json:"activity" validate:"-"`
-	Organizer   User      `gorm:"foreignKey:OrganizerID" json:"organizer" validate:"-"`
+	EndTime     time.Time `gorm:"index" json:"end_time" validate:"omitempty,gtfield=MatchTime"`
+	Status      string    `json:"status" validate:"required,oneof=open completed cancelled expired"`
+	Notes       string    `gorm:"size:1000" json:"notes,omitempty" validate:"omitempty,max=1000"`
+	// 名額，0 表示使用活動的 target_count
+	Capacity int `json:"capacity,omitempty" validate:"omitempty,min=1,max=100"`
+	// 參與者需要的最低出席可信度 (0-100)，0 表示不限制；沒有出席紀錄的使用者不受限制
+	MinReliability int `json:"min_reliability" validate:"omitempty,min=0,max=100"`
+	// 審核方式：manual 由開局者審核；auto 在名額內自動通過；gated 只自動通過達到門檻的使用者
+	ApprovalMode            string  `gorm:"size:16;default:manual" json:"approval_mode" validate:"omitempty,oneof=manual auto gated"`
+	GateMinReviewAverage    float64 `json:"gate_min_review_average,omitempty" validate:"omitempty,min=1,max=5"`
+	GateMinCompletedMatches int     `json:"gate_min_completed_matches,omitempty" validate:"omitempty,min=1,max=1000"`
+	// 由週期系列產生的配對局會記錄系列與原定的發生時間 (slot)，避免重複產生
+	SeriesID       *int64     `gorm:"uniqueIndex:unique_series_slot,priority:1" json:"series_id,omitempty" validate:"-"`
+	SeriesSlot     *time.Time `gorm:"uniqueIndex:unique_series_slot,priority:2" json:"series_slot,omitempty" validate:"-"`
+	SeriesOverride bool       `json:"series_override,omitempty" validate:"-"`
+	Activity       Activity   `gorm:"foreignKey:ActivityID" json:"activity" validate:"-"`
+	Organizer      User       `gorm:"foreignKey:OrganizerID" json:"organizer" validate:"-"`
+	// 系統取消配對局時記錄的原因
+	CancelReason string `gorm:"size:500" json:"cancel_reason,omitempty" validate:"-"`
+}
+
+// 配對局的審核方式
+const (
+	ApprovalManual = "manual"
+	ApprovalAuto   = "auto"
+	ApprovalGated  = "gated"
+)
+
+type MatchSeries struct {
+	ID              int64                `gorm:"primaryKey;autoIncrement" json:"id" validate:"-"`
+	ActivityID      int64                `gorm:"index" json:"activity_id" validate:"required,min=1"`
+	OrganizerID     int64                `gorm:"index" json:"organizer_id" validate:"required,min=1"`
+	Rule            string               `gorm:"size:255" json:"rule" validate:"required,max=255"`
+	StartTime       time.Time            `json:"start_time" validate:"required"`
+	Until           time.Time            `gorm:"index" json:"until" validate:"required,gtfield=StartTime"`
+	Timezone        string               `gorm:"size:64;default:Asia/Taipei" json:"timezone" validate:"omitempty,max=64"`
+	DurationMinutes int                  `json:"duration_minutes" validate:"omitempty,min=15,max=1440"`
+	DaysAhead       int                  `gorm:"default:14" json:"days_ahead" validate:"omitempty,min=1,max=60"`
+	Status          string               `gorm:"index" json:"status" validate:"required,oneof=active cancelled"`
+	GeneratedUntil  time.Time            `json:"generated_until" validate:"-"`
+	CreatedAt       time.Time            `json:"created_at" validate:"-"`
+	Activity        Activity             `gorm:"foreignKey:ActivityID" json:"activity" validate:"-"`
+	Organizer       User                 `gorm:"foreignKey:OrganizerID" json:"organizer" validate:"-"`
+	Partners        []MatchSeriesPartner `gorm:"foreignKey:SeriesID" json:"partners" validate:"-"`
+}
+
+// 系列未設定時預先產生配對局的天數
+const DefaultSeriesDaysAhead = 14
+
+// MatchDuration 回傳系列中每場配對局的長度，未設定時沿用活動的預設值
+func (s *MatchSeries) MatchDuration() time.Duration {
+	if s.DurationMinutes <= 0 {
+		return s.Activity.MatchDuration()
+	}
+	return time.Duration(s.DurationMinutes) * time.Minute
+}
+
+type MatchSeriesPartner struct {
+	ID       int64 `gorm:"primaryKey;autoIncrement" json:"id" validate:"-"`
+	SeriesID int64 `gorm:"uniqueIndex:unique_series_partner,priority:1" json:"series_id" validate:"required,min=1"`
+	UserID   int64 `gorm:"uniqueIndex:unique_series_partner,priority:2" json:"user_id" validate:"required,min=1"`
+	User     User  `gorm:"foreignKey:UserID" json:"user" validate:"-"`
 }
 
 type MatchParticipant struct {
 	ID       int64     `gorm:"primaryKey;autoIncrement" json:"id" validate:"-"`
-	MatchID  int64     `json:"match_id" validate:"required,min=1"`
-	UserID   int64     `json:"user_id" validate:"required,min=1"`
-	Status   string    `json:"status" validate:"required,oneof=pending approved rejected"`
+	MatchID  int64     `gorm:"uniqueIndex:unique_match_user,priority:1" json:"match_id" validate:"required,min=1"`
+	UserID   int64     `gorm:"uniqueIndex:unique_match_user,priority:2;index" json:"user_id" validate:"required,min=1"`
+	Status   string    `json:"status" validate:"required,oneof=pending approved rejected attended no_show late_cancel reconfirm released removed"`
 	JoinedAt time.Time `json:"joined_at" validate:"required"`
-	Match    Match     `gorm:"foreignKey:MatchID" json:"match" validate:"-"`
-	User     User      `gorm:"foreignKey:UserID" json:"user" validate:"-"`
+	// 配對局有重大變更時，已審核通過的參與者需在期限前重新確認，否則狀態變為 released
+	ReconfirmDeadline *time.Time `json:"reconfirm_deadline,omitempty" validate:"-"`
+	CheckedInAt       *time.Time `json:"checked_in_at,omitempty" validate:"-"`
+	CheckInAttempts   int        `json:"-" validate:"-"`
+	// 開局者拒絕時選擇的理由代碼，以及拒絕或移除時顯示給參與者的理由
+	RejectionCode   string `gorm:"size:32" json:"rejection_code,omitempty" validate:"-"`
+	RejectionReason string `gorm:"size:500" json:"rejection_reason,omitempty" validate:"-"`
+	// 審核通過後要公開給其他參與者的聯絡方式欄位，以逗號分隔，例如 "email,line_id"
+	SharedContacts string `gorm:"size:100" json:"shared_contacts,omitempty" validate:"-"`
+	Match          Match  `gorm:"foreignKey:MatchID" json:"match" validate:"-"`
+	User           User   `gorm:"foreignKey:UserID" json:"user" validate:"-"`
+}
+
+// RejectionReasons 拒絕參與時可選的理由代碼與預設說明，選擇 other 時需要自行填寫理由
+var RejectionReasons = map[string]string{
+	"full":     "名額已滿",
+	"schedule": "時間無法配合",
+	"profile":  "資料不符合此配對局的需求",
+	"other":    "其他",
+}
+
+// ApprovedParticipantStatuses 視為已審核通過的參與狀態；配對結束後確認出席的參與者狀態為 attended
+var ApprovedParticipantStatuses = []string{"approved", "attended"}
+
+// AttendanceStatuses 配對結束後記錄的出席狀態，用於計算出席可信度
+var AttendanceStatuses = []string{"attended", "no_show", "late_cancel"}
+
+// AttendanceReportWindow 配對結束後開局者可以回報出席狀況的時間，期限過後依報到紀錄自動記錄
+const AttendanceReportWindow = 48 * time.Hour
+
+type MatchInvite struct {
+	ID        int64      `gorm:"primaryKey;autoIncrement" json:"id" validate:"-"`
+	MatchID   int64      `gorm:"index" json:"match_id" validate:"required,min=1"`
+	CreatedBy int64      `json:"created_by" validate:"required,min=1"`
+	Code      string     `gorm:"size:16;uniqueIndex" json:"-" validate:"required"`
+	MaxUses   int        `json:"max_uses" validate:"omitempty,min=1,max=100"` // 0 表示不限次數
+	UseCount  int        `json:"use_count" validate:"-"`
+	ExpiresAt time.Time  `json:"expires_at" validate:"required"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty" validate:"-"`
+	CreatedAt time.Time  `json:"created_at" validate:"-"`
+}
+
+type MatchMessage struct {
+	ID        int64      `gorm:"primaryKey;autoIncrement" json:"id" validate:"-"`
+	MatchID   int64      `gorm:"index" json:"match_id" validate:"required,min=1"`
+	SenderID  int64      `gorm:"index" json:"sender_id" validate:"required,min=1"`
+	Body      string     `gorm:"type:text" json:"body" validate:"required,min=1,max=1000"`
+	EditedAt  *time.Time `json:"edited_at,omitempty" validate:"-"`
+	DeletedAt *time.Time `json:"-" validate:"-"`
+	CreatedAt time.Time  `json:"created_at" validate:"-"`
+	Sender    User       `gorm:"foreignKey:SenderID" json:"sender" validate:"-"`
+}
+
+type MatchMessageRead struct {
+	ID                int64     `gorm:"primaryKey;autoIncrement" json:"id" validate:"-"`
+	MatchID           int64     `gorm:"uniqueIndex:unique_match_reader,priority:1" json:"match_id" validate:"required,min=1"`
+	UserID            int64     `gorm:"uniqueIndex:unique_match_reader,priority:2" json:"user_id" validate:"required,min=1"`
+	LastReadMessageID int64     `json:"last_read_message_id" validate:"-"`
+	UpdatedAt         time.Time `json:"updated_at" validate:"-"`
+}
+
+type MessageReport struct {
+	ID         int64        `gorm:"primaryKey;autoIncrement" json:"id" validate:"-"`
+	MessageID  int64        `gorm:"uniqueIndex:unique_message_reporter,priority:1" json:"message_id" validate:"required,min=1"`
+	ReporterID int64        `gorm:"uniqueIndex:unique_message_reporter,priority:2" json:"reporter_id" validate:"required,min=1"`
+	Reason     string       `json:"reason" validate:"required,min=1,max=500"`
+	CreatedAt  time.Time    `json:"created_at" validate:"-"`
+	Message    MatchMessage `gorm:"foreignKey:MessageID" json:"message" validate:"-"`
+	Reporter   User         `gorm:"foreignKey:ReporterID" json:"reporter" validate:"-"`
 }
 
 type Review struct {
 	ID         int64     `gorm:"primaryKey;autoIncrement" json:"id" validate:"-"`
-	MatchID    int64     `json:"match_id" validate:"required,min=1"`
-	ReviewerID int64     `json:"reviewer_id" validate:"required,min=1"`
-	RevieweeID int64     `json:"reviewee_id" validate:"required,min=1"`
+	MatchID    int64     `gorm:"uniqueIndex:unique_reviewer_reviewee_match,priority:3;index" json:"match_id" validate:"required,min=1"`
+	ReviewerID int64     `gorm:"uniqueIndex:unique_reviewer_reviewee_match,priority:1" json:"reviewer_id" validate:"required,min=1"`
+	RevieweeID int64     `gorm:"uniqueIndex:unique_reviewer_reviewee_match,priority:2" json:"reviewee_id" validate:"required,min=1"`
 	Score      int       `json:"score" validate:"required,min=3,max=5"`
 	Comment    string    `json:"comment" validate:"omitempty,max=500"`
 	CreatedAt  time.Time `json:"created_at" validate:"-"`
@@ -73,8 +342,8 @@ type Review struct {
 
 type ReviewLike struct {
 	ID       int64  `gorm:"primaryKey;autoIncrement" json:"id" validate:"-"`
-	ReviewID int64  `json:"review_id" validate:"required,min=1"`
-	UserID   int64  `json:"user_id" validate:"required,min=1"`
+	ReviewID int64  `gorm:"uniqueIndex:unique_user_review,priority:2;index" json:"review_id" validate:"required,min=1"`
+	UserID   int64  `gorm:"uniqueIndex:unique_user_review,priority:1" json:"user_id" validate:"required,min=1"`
 	IsLike   bool   `json:"is_like" validate:"required"`
 	Review   Review `gorm:"foreignKey:ReviewID" json:"review" validate:"-"`
 	User     User   `gorm:"foreignKey:UserID" json:"user" validate:"-"`
@@ -88,3 +357,221 @@ type RefreshToken struct {
 	CreatedAt time.Time `json:"created_at" validate:"-"`
 	User      User      `gorm:"foreignKey:UserID" json:"user" validate:"-"`
 }
+
+type CalendarToken struct {
+	ID        int64     `gorm:"primaryKey;autoIncrement" json:"id" validate:"-"`
+	UserID    int64     `gorm:"uniqueIndex" json:"user_id" validate:"required,min=1"`
+	TokenHash string    `gorm:"size:64;uniqueIndex" json:"-" validate:"required"` // SHA-256 of token
+	CreatedAt time.Time `json:"created_at" validate:"-"`
+}
+
+// PairingIntent 使用者「找人一起買」的意向，配對引擎會將相容的意向配成一個配對局
+// ActivityID 與 Chain (地點名稱，例如「全家便利商店」) 擇一指定
+type PairingIntent struct {
+	ID            int64     `gorm:"primaryKey;autoIncrement" json:"id" validate:"-"`
+	UserID        int64     `gorm:"index" json:"user_id" validate:"-"`
+	ActivityID    *int64    `json:"activity_id,omitempty" validate:"omitempty,min=1"`
+	Chain         string    `gorm:"size:100" json:"chain,omitempty" validate:"omitempty,max=100"`
+	Latitude      float64   `json:"latitude" validate:"min=-90,max=90"`
+	Longitude     float64   `json:"longitude" validate:"min=-180,max=180"`
+	MaxDistanceKm float64   `json:"max_distance_km" validate:"required,gt=0,max=50"`
+	WindowStart   time.Time `json:"window_start" validate:"required"`
+	WindowEnd     time.Time `gorm:"index" json:"window_end" validate:"required,gtfield=WindowStart"`
+	Status        string    `gorm:"size:16;index" json:"status" validate:"-"` // open matched cancelled expired
+	MatchID       *int64    `json:"match_id,omitempty" validate:"-"`
+	CreatedAt     time.Time `json:"created_at" validate:"-"`
+}
+
+// MatchChange 配對局被開局者修改的紀錄，每個欄位一筆
+type MatchChange struct {
+	ID        int64     `gorm:"primaryKey;autoIncrement" json:"id" validate:"-"`
+	MatchID   int64     `gorm:"index" json:"match_id" validate:"-"`
+	ChangedBy int64     `json:"changed_by" validate:"-"`
+	Field     string    `gorm:"size:50" json:"field" validate:"-"`
+	OldValue  string    `gorm:"size:1000" json:"old_value" validate:"-"`
+	NewValue  string    `gorm:"size:1000" json:"new_value" validate:"-"`
+	Material  bool      `json:"material" validate:"-"` // 是否需要參與者重新確認
+	CreatedAt time.Time `json:"created_at" validate:"-"`
+}
+
+// MatchCoOrganizer 配對局的共同開局者，與開局者有相同的審核權限
+type MatchCoOrganizer struct {
+	ID        int64     `gorm:"primaryKey;autoIncrement" json:"id" validate:"-"`
+	MatchID   int64     `gorm:"uniqueIndex:unique_match_co_organizer,priority:1" json:"match_id" validate:"-"`
+	UserID    int64     `gorm:"uniqueIndex:unique_match_co_organizer,priority:2;index" json:"user_id" validate:"-"`
+	AddedBy   int64     `json:"added_by" validate:"-"`
+	CreatedAt time.Time `json:"created_at" validate:"-"`
+	User      User      `gorm:"foreignKey:UserID" json:"user" validate:"-"`
+}
+
+// MatchTransfer 開局者將配對局轉移給已審核通過的參與者，需要對方接受
+type MatchTransfer struct {
+	ID          int64      `gorm:"primaryKey;autoIncrement" json:"id" validate:"-"`
+	MatchID     int64      `gorm:"index" json:"match_id" validate:"-"`
+	FromUserID  int64      `json:"from_user_id" validate:"-"`
+	ToUserID    int64      `gorm:"index" json:"to_user_id" validate:"-"`
+	Status      string     `gorm:"size:16;index" json:"status" validate:"-"` // pending accepted declined cancelled
+	CreatedAt   time.Time  `json:"created_at" validate:"-"`
+	RespondedAt *time.Time `json:"responded_at,omitempty" validate:"-"`
+}
+
+// MatchPrivateDetail 配對局的私人集合資訊，只提供給開局者、共同開局者與已審核通過的參與者
+type MatchPrivateDetail struct {
+	MatchID       int64     `gorm:"primaryKey;autoIncrement:false" json:"match_id" validate:"-"`
+	Notes         string    `gorm:"size:1000" json:"notes" validate:"omitempty,max=1000"`
+	ContactHandle string    `gorm:"size:100" json:"contact_handle" validate:"omitempty,max=100"`
+	UpdatedBy     int64     `json:"updated_by" validate:"-"`
+	UpdatedAt     time.Time `json:"updated_at" validate:"-"`
+}
+
+// MatchPrivateAccess 查看私人集合資訊的紀錄
+type MatchPrivateAccess struct {
+	ID        int64     `gorm:"primaryKey;autoIncrement" json:"id" validate:"-"`
+	MatchID   int64     `gorm:"index" json:"match_id" validate:"-"`
+	UserID    int64     `gorm:"index" json:"user_id" validate:"-"`
+	CreatedAt time.Time `json:"created_at" validate:"-"`
+	User      User      `gorm:"foreignKey:UserID" json:"user" validate:"-"`
+}
+
+// UserContact 使用者的聯絡方式，只在參與者選擇公開的配對局中顯示給其他成員
+type UserContact struct {
+	UserID    int64     `gorm:"primaryKey;autoIncrement:false" json:"user_id" validate:"-"`
+	Phone     string    `gorm:"size:30" json:"phone" validate:"omitempty,max=30"`
+	LineID    string    `gorm:"size:50" json:"line_id" validate:"omitempty,max=50"`
+	Instagram string    `gorm:"size:50" json:"instagram" validate:"omitempty,max=50"`
+	UpdatedAt time.Time `json:"updated_at" validate:"-"`
+}
+
+// UserQuota 使用者的配額，欄位為 0 表示不限制
+type UserQuota struct {
+	OpenMatches     int `json:"open_matches"`     // 同時開局中 (open) 的配對局
+	PendingRequests int `json:"pending_requests"` // 等待審核的參與申請
+	DailyMatches    int `json:"daily_matches"`    // 24 小時內建立的配對局
+	DailyJoins      int `json:"daily_joins"`      // 24 小時內參與的配對局
+}
+
+// 預設配額；完成的配對局少於 TrustedAfterCompletedMatches 場的新帳號使用較嚴格的 NewAccountQuota
+var (
+	DefaultUserQuota             = UserQuota{OpenMatches: 20, PendingRequests: 30, DailyMatches: 10, DailyJoins: 30}
+	NewAccountQuota              = UserQuota{OpenMatches: 5, PendingRequests: 10, DailyMatches: 3, DailyJoins: 10}
+	TrustedAfterCompletedMatches = 3
+)
+
+// UserQuotaOverride 管理員為個別使用者調整的配額，欄位為 null 時沿用預設配額，0 表示不限制
+type UserQuotaOverride struct {
+	UserID          int64      `gorm:"primaryKey;autoIncrement:false" json:"user_id" validate:"-"`
+	OpenMatches     *int       `json:"open_matches" validate:"omitempty,min=0"`
+	PendingRequests *int       `json:"pending_requests" validate:"omitempty,min=0"`
+	DailyMatches    *int       `json:"daily_matches" validate:"omitempty,min=0"`
+	DailyJoins      *int       `json:"daily_joins" validate:"omitempty,min=0"`
+	Note            string     `gorm:"size:500" json:"note" validate:"omitempty,max=500"`
+	ExpiresAt       *time.Time `json:"expires_at,omitempty" validate:"-"` // 過期後恢復預設配額，null 表示不會過期
+	GrantedBy       int64      `json:"granted_by" validate:"-"`
+	UpdatedAt       time.Time  `json:"updated_at" validate:"-"`
+}
+
+// Apply 以調整值取代對應的預設配額
+func (o *UserQuotaOverride) Apply(quota UserQuota) UserQuota {
+	if o.OpenMatches != nil {
+		quota.OpenMatches = *o.OpenMatches
+	}
+	if o.PendingRequests != nil {
+		quota.PendingRequests = *o.PendingRequests
+	}
+	if o.DailyMatches != nil {
+		quota.DailyMatches = *o.DailyMatches
+	}
+	if o.DailyJoins != nil {
+		quota.DailyJoins = *o.DailyJoins
+	}
+	return quota
+}
+
+// ContactFields 參與者可以選擇公開的聯絡方式欄位
+var ContactFields = []string{"email", "phone", "line_id", "instagram"}
+
+// MatchEvent 配對局與參與者狀態變更的紀錄，只新增不修改
+type MatchEvent struct {
+	ID      int64 `gorm:"primaryKey;autoIncrement" json:"id" validate:"-"`
+	MatchID int64 `gorm:"index" json:"match_id" validate:"-"`
+	// 參與者狀態變更時記錄參與紀錄與參與者；配對局狀態變更時為 null
+	ParticipantID *int64 `gorm:"index" json:"participant_id,omitempty" validate:"-"`
+	UserID        *int64 `gorm:"index" json:"user_id,omitempty" validate:"-"`
+	// 執行變更的使用者，背景工作自動變更時為 null
+	ActorID    *int64    `json:"actor_id,omitempty" validate:"-"`
+	FromStatus string    `gorm:"size:16" json:"from_status" validate:"-"`
+	ToStatus   string    `gorm:"size:16" json:"to_status" validate:"-"`
+	Reason     string    `gorm:"size:500" json:"reason,omitempty" validate:"-"`
+	CreatedAt  time.Time `gorm:"index" json:"created_at" validate:"-"`
+}
+
+// NewMatchEvent 建立配對局狀態變更紀錄，actorID 為 0 表示系統自動變更
+func NewMatchEvent(matchID int64, from, to string, actorID int64, reason string) MatchEvent {
+	return MatchEvent{MatchID: matchID, ActorID: eventActor(actorID), FromStatus: from, ToStatus: to, Reason: reason, CreatedAt: time.Now()}
+}
+
+// NewParticipantEvent 建立參與者狀態變更紀錄，actorID 為 0 表示系統自動變更
+func NewParticipantEvent(p *MatchParticipant, from, to string, actorID int64, reason string) MatchEvent {
+	participantID, userID := p.ID, p.UserID
+	event := NewMatchEvent(p.MatchID, from, to, actorID, reason)
+	event.ParticipantID = &participantID
+	event.UserID = &userID
+	return event
+}
+
+func eventActor(actorID int64) *int64 {
+	if actorID == 0 {
+		return nil
+	}
+	return &actorID
+}
+
+// OrganizerBlock 開局者封鎖的使用者，被封鎖的使用者不能參與該開局者的配對局
+type OrganizerBlock struct {
+	ID          int64     `gorm:"primaryKey;autoIncrement" json:"id" validate:"-"`
+	OrganizerID int64     `gorm:"uniqueIndex:unique_organizer_block,priority:1" json:"organizer_id" validate:"-"`
+	UserID      int64     `gorm:"uniqueIndex:unique_organizer_block,priority:2;index" json:"user_id" validate:"-"`
+	MatchID     *int64    `json:"match_id,omitempty" validate:"-"`
+	Reason      string    `gorm:"size:500" json:"reason" validate:"-"`
+	CreatedAt   time.Time `json:"created_at" validate:"-"`
+	User        User      `gorm:"foreignKey:UserID" json:"user" validate:"-"`
+}
+
+// 通知類型
+const (
+	NotificationPairingMatched = "pairing_matched"
+	NotificationMatchChanged   = "match_changed"
+	NotificationReconfirm      = "match_reconfirm"
+	NotificationReleased       = "participation_released"
+	NotificationRemoved        = "participation_removed"
+	NotificationTransfer       = "ownership_transfer"
+	NotificationTransferReply  = "ownership_transfer_reply"
+	NotificationMatchCancelled = "match_cancelled"
+)
+
+type Notification struct {
+	ID        int64      `gorm:"primaryKey;autoIncrement" json:"id" validate:"-"`
+	UserID    int64      `gorm:"index" json:"user_id" validate:"-"`
+	Type      string     `gorm:"size:50" json:"type" validate:"-"`
+	MatchID   *int64     `json:"match_id,omitempty" validate:"-"`
+	Message   string     `gorm:"size:500" json:"message" validate:"-"`
+	ReadAt    *time.Time `json:"read_at" validate:"-"`
+	CreatedAt time.Time  `gorm:"index" json:"created_at" validate:"-"`
+}
+
+type JobLock struct {
+	Name        string    `gorm:"primaryKey;size:100" json:"name" validate:"-"`
+	Holder      string    `gorm:"size:255" json:"holder" validate:"-"`
+	LockedUntil time.Time `gorm:"index" json:"locked_until" validate:"-"`
+}
+
+type JobRun struct {
+	ID         int64     `gorm:"primaryKey;autoIncrement" json:"id" validate:"-"`
+	JobName    string    `gorm:"size:100;index" json:"job_name" validate:"required"`
+	Holder     string    `gorm:"size:255" json:"holder" validate:"-"`
+	Status     string    `json:"status" validate:"required,oneof=success failed"`
+	Affected   int64     `json:"affected" validate:"-"`
+	Error      string    `gorm:"type:text" json:"error,omitempty" validate:"-"`
+	StartedAt  time.Time `gorm:"index" json:"started_at" validate:"-"`
+	FinishedAt time.Time `json:"finished_at" validate:"-"`
+}
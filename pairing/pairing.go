@@ -0,0 +1,202 @@
+package pairing
+
+import (
+	"sort"
+	"time"
+
+	"free2free/geo"
+)
+
+// Intent 一筆尋找同伴的意向；ActivityID 為 0 時以 Chain (地點名稱) 比對
+type Intent struct {
+	ID            int64
+	UserID        int64
+	ActivityID    int64
+	Chain         string
+	Latitude      float64
+	Longitude     float64
+	MaxDistanceKm float64
+	WindowStart   time.Time
+	WindowEnd     time.Time
+	// Reliability 使用者的出席可信度，沒有紀錄時為 nil
+	Reliability *int
+	// Blocked 不能與此使用者配在一起的使用者 (任一方曾封鎖另一方)
+	Blocked   map[int64]bool
+	CreatedAt time.Time
+}
+
+// Venue 可以成局的活動與其地點
+type Venue struct {
+	ActivityID int64
+	Chain      string
+	Latitude   float64
+	Longitude  float64
+	Duration   time.Duration
+	// Available 判斷配對局能否在 start 到 end 於此地點舉行 (例如活動的優惠期間與每週可用時段)，nil 表示不限制
+	Available func(start, end time.Time) bool
+}
+
+// Pair 配對結果：兩筆意向在 Venue 於 Start 到 End 一起參加
+type Pair struct {
+	A, B  Intent
+	Venue Venue
+	Start time.Time
+	End   time.Time
+	// DistanceKm 兩位使用者到地點的距離總和
+	DistanceKm float64
+}
+
+// Match 以先到先配的方式配對意向：依建立時間處理每筆尚未配對的意向，
+// 從相容且沒有互相封鎖的對象中優先選擇出席可信度高的 (沒有紀錄的排在最後)，其次是到地點距離總和較近的。
+// leadTime 為配對局最早的開始時間與 now 的間隔，讓使用者有時間前往
+func Match(intents []Intent, venues []Venue, now time.Time, leadTime time.Duration) []Pair {
+	ordered := make([]Intent, len(intents))
+	copy(ordered, intents)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if !ordered[i].CreatedAt.Equal(ordered[j].CreatedAt) {
+			return ordered[i].CreatedAt.Before(ordered[j].CreatedAt)
+		}
+		return ordered[i].ID < ordered[j].ID
+	})
+
+	earliest := ceilMinute(now.Add(leadTime))
+	paired := make(map[int64]bool)
+	busyUsers := make(map[int64]bool)
+	var pairs []Pair
+
+	for i, a := range ordered {
+		if paired[a.ID] || busyUsers[a.UserID] {
+			continue
+		}
+
+		var best *Pair
+		for _, b := range ordered[i+1:] {
+			if paired[b.ID] || busyUsers[b.UserID] || !canPair(a, b) {
+				continue
+			}
+			candidate, ok := bestVenue(a, b, venues, earliest)
+			if !ok {
+				continue
+			}
+			if best == nil || better(candidate, *best) {
+				c := candidate
+				best = &c
+			}
+		}
+
+		if best != nil {
+			paired[best.A.ID] = true
+			paired[best.B.ID] = true
+			busyUsers[best.A.UserID] = true
+			busyUsers[best.B.UserID] = true
+			pairs = append(pairs, *best)
+		}
+	}
+	return pairs
+}
+
+// MatchOne 只為 intent 從 candidates 中選出最佳的對象，選擇方式與 Match 相同；
+// 用於新建立的意向，不處理 candidates 彼此之間的配對。較早建立的意向為 Pair.A
+func MatchOne(intent Intent, candidates []Intent, venues []Venue, now time.Time, leadTime time.Duration) (Pair, bool) {
+	earliest := ceilMinute(now.Add(leadTime))
+	var best *Pair
+	for _, b := range candidates {
+		if !canPair(intent, b) {
+			continue
+		}
+		candidate, ok := bestVenue(intent, b, venues, earliest)
+		if !ok {
+			continue
+		}
+		if best == nil || better(candidate, *best) {
+			c := candidate
+			best = &c
+		}
+	}
+	if best == nil {
+		return Pair{}, false
+	}
+
+	pair := *best
+	if pair.B.CreatedAt.Before(pair.A.CreatedAt) || (pair.B.CreatedAt.Equal(pair.A.CreatedAt) && pair.B.ID < pair.A.ID) {
+		pair.A, pair.B = pair.B, pair.A
+	}
+	return pair, true
+}
+
+// canPair 判斷兩筆意向能否配在一起：不是同一位使用者，且雙方之間沒有封鎖
+func canPair(a, b Intent) bool {
+	return a.UserID != b.UserID && !a.Blocked[b.UserID] && !b.Blocked[a.UserID]
+}
+
+// bestVenue 找出兩筆意向都能接受、距離總和最近的地點與共同時段
+func bestVenue(a, b Intent, venues []Venue, earliest time.Time) (Pair, bool) {
+	var best Pair
+	found := false
+	for _, v := range venues {
+		if !a.accepts(v) || !b.accepts(v) {
+			continue
+		}
+		da := geo.Haversine(a.Latitude, a.Longitude, v.Latitude, v.Longitude)
+		db := geo.Haversine(b.Latitude, b.Longitude, v.Latitude, v.Longitude)
+		if da > a.MaxDistanceKm || db > b.MaxDistanceKm {
+			continue
+		}
+
+		start := latest(a.WindowStart, b.WindowStart, earliest)
+		end := start.Add(v.Duration)
+		if end.After(a.WindowEnd) || end.After(b.WindowEnd) {
+			continue
+		}
+		if v.Available != nil && !v.Available(start, end) {
+			continue
+		}
+
+		if !found || da+db < best.DistanceKm {
+			best = Pair{A: a, B: b, Venue: v, Start: start, End: end, DistanceKm: da + db}
+			found = true
+		}
+	}
+	return best, found
+}
+
+// better 判斷 x 是否優於 y：對象的出席可信度較高者優先，其次是距離較近者
+func better(x, y Pair) bool {
+	rx, ry := x.B.Reliability, y.B.Reliability
+	switch {
+	case rx != nil && ry == nil:
+		return true
+	case rx == nil && ry != nil:
+		return false
+	case rx != nil && ry != nil && *rx != *ry:
+		return *rx > *ry
+	}
+	return x.DistanceKm < y.DistanceKm
+}
+
+// accepts 判斷意向是否接受此地點的活動
+func (i Intent) accepts(v Venue) bool {
+	if i.ActivityID != 0 {
+		return i.ActivityID == v.ActivityID
+	}
+	return i.Chain != "" && i.Chain == v.Chain
+}
+
+func latest(times ...time.Time) time.Time {
+	result := times[0]
+	for _, t := range times[1:] {
+		if t.After(result) {
+			result = t
+		}
+	}
+	return result
+}
+
+// ceilMinute 將時間進位到整分鐘
+func ceilMinute(t time.Time) time.Time {
+	truncated := t.Truncate(time.Minute)
+	if truncated.Equal(t) {
+		return t
+	}
+	return truncated.Add(time.Minute)
+}
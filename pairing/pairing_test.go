@@ -0,0 +1,161 @@
+package pairing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func intPtr(v int) *int { return &v }
+
+func TestMatchPairsCompatibleIntents(t *testing.T) {
+	now := time.Date(2026, 10, 18, 10, 0, 30, 0, time.UTC)
+	venues := []Venue{
+		{ActivityID: 1, Chain: "全家便利商店", Latitude: 25.033, Longitude: 121.565, Duration: time.Hour},
+		{ActivityID: 2, Chain: "全家便利商店", Latitude: 25.050, Longitude: 121.565, Duration: time.Hour},
+		{ActivityID: 3, Chain: "7-ELEVEN", Latitude: 25.033, Longitude: 121.565, Duration: time.Hour},
+	}
+	window := func(from, to time.Duration) (time.Time, time.Time) {
+		return now.Add(from), now.Add(to)
+	}
+
+	aStart, aEnd := window(0, 3*time.Hour)
+	bStart, bEnd := window(time.Hour, 4*time.Hour)
+	a := Intent{ID: 1, UserID: 10, Chain: "全家便利商店", Latitude: 25.034, Longitude: 121.565, MaxDistanceKm: 3, WindowStart: aStart, WindowEnd: aEnd, CreatedAt: now}
+	b := Intent{ID: 2, UserID: 20, ActivityID: 1, Latitude: 25.032, Longitude: 121.565, MaxDistanceKm: 3, WindowStart: bStart, WindowEnd: bEnd, CreatedAt: now.Add(time.Second)}
+	// 不同連鎖店，無法配對
+	c := Intent{ID: 3, UserID: 30, Chain: "7-ELEVEN", Latitude: 25.033, Longitude: 121.565, MaxDistanceKm: 3, WindowStart: aStart, WindowEnd: aEnd, CreatedAt: now.Add(2 * time.Second)}
+
+	pairs := Match([]Intent{c, b, a}, venues, now, 15*time.Minute)
+	if assert.Len(t, pairs, 1) {
+		p := pairs[0]
+		assert.Equal(t, int64(1), p.A.ID)
+		assert.Equal(t, int64(2), p.B.ID)
+		assert.Equal(t, int64(1), p.Venue.ActivityID)
+		// 共同時段從較晚的開始時間起算
+		assert.Equal(t, bStart, p.Start)
+		assert.Equal(t, bStart.Add(time.Hour), p.End)
+	}
+}
+
+func TestMatchRespectsWindowsAndDistance(t *testing.T) {
+	now := time.Date(2026, 10, 18, 10, 0, 30, 0, time.UTC)
+	venues := []Venue{{ActivityID: 1, Latitude: 25.033, Longitude: 121.565, Duration: time.Hour}}
+
+	a := Intent{ID: 1, UserID: 10, ActivityID: 1, Latitude: 25.033, Longitude: 121.565, MaxDistanceKm: 1, WindowStart: now, WindowEnd: now.Add(2 * time.Hour)}
+	// 共同時段不足一小時
+	short := Intent{ID: 2, UserID: 20, ActivityID: 1, Latitude: 25.033, Longitude: 121.565, MaxDistanceKm: 1, WindowStart: now.Add(90 * time.Minute), WindowEnd: now.Add(4 * time.Hour)}
+	// 地點超出最大距離
+	far := Intent{ID: 3, UserID: 30, ActivityID: 1, Latitude: 25.100, Longitude: 121.565, MaxDistanceKm: 1, WindowStart: now, WindowEnd: now.Add(2 * time.Hour)}
+	// 同一位使用者的意向不會互相配對
+	same := Intent{ID: 4, UserID: 10, ActivityID: 1, Latitude: 25.033, Longitude: 121.565, MaxDistanceKm: 1, WindowStart: now, WindowEnd: now.Add(2 * time.Hour)}
+
+	assert.Empty(t, Match([]Intent{a, short, far, same}, venues, now, 0))
+
+	// 最早開始時間為 now 加上 leadTime 並進位到整分鐘
+	ok := Intent{ID: 5, UserID: 50, ActivityID: 1, Latitude: 25.033, Longitude: 121.565, MaxDistanceKm: 1, WindowStart: now, WindowEnd: now.Add(2 * time.Hour)}
+	pairs := Match([]Intent{a, ok}, venues, now, 15*time.Minute)
+	if assert.Len(t, pairs, 1) {
+		assert.Equal(t, time.Date(2026, 10, 18, 10, 16, 0, 0, time.UTC), pairs[0].Start)
+	}
+}
+
+func TestMatchSkipsUnavailableVenues(t *testing.T) {
+	now := time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+	// 最近的地點在這段時間不開放，改配到較遠但可用的地點
+	closed := Venue{ActivityID: 1, Latitude: 25.033, Longitude: 121.565, Duration: time.Hour,
+		Available: func(start, end time.Time) bool { return false }}
+	open := Venue{ActivityID: 2, Latitude: 25.040, Longitude: 121.565, Duration: time.Hour}
+	intent := func(id, userID int64) Intent {
+		return Intent{ID: id, UserID: userID, Latitude: 25.033, Longitude: 121.565, MaxDistanceKm: 5,
+			WindowStart: now, WindowEnd: now.Add(2 * time.Hour), CreatedAt: now.Add(time.Duration(id) * time.Second)}
+	}
+	a, b := intent(1, 10), intent(2, 20)
+	a.Chain, b.Chain = "全家便利商店", "全家便利商店"
+	closed.Chain, open.Chain = "全家便利商店", "全家便利商店"
+
+	pairs := Match([]Intent{a, b}, []Venue{closed, open}, now, 0)
+	if assert.Len(t, pairs, 1) {
+		assert.Equal(t, int64(2), pairs[0].Venue.ActivityID)
+	}
+
+	// 沒有可用的地點時不配對，意向保持 open 等待下一次
+	assert.Empty(t, Match([]Intent{a, b}, []Venue{closed}, now, 0))
+}
+
+func TestMatchPrefersReliabilityThenProximity(t *testing.T) {
+	now := time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+	venues := []Venue{{ActivityID: 1, Latitude: 25.033, Longitude: 121.565, Duration: time.Hour}}
+	intent := func(id, userID int64, lat float64, reliability *int) Intent {
+		return Intent{ID: id, UserID: userID, ActivityID: 1, Latitude: lat, Longitude: 121.565, MaxDistanceKm: 5,
+			WindowStart: now, WindowEnd: now.Add(3 * time.Hour), Reliability: reliability, CreatedAt: now.Add(time.Duration(id) * time.Second)}
+	}
+
+	a := intent(1, 10, 25.033, nil)
+	near := intent(2, 20, 25.033, nil)
+	reliableFar := intent(3, 30, 25.060, intPtr(90))
+	lessReliable := intent(4, 40, 25.033, intPtr(60))
+
+	pairs := Match([]Intent{a, near, reliableFar, lessReliable}, venues, now, 0)
+	if assert.Len(t, pairs, 2) {
+		assert.Equal(t, int64(3), pairs[0].B.ID)
+		// 剩下兩筆中有紀錄的排在沒有紀錄的前面
+		assert.Equal(t, int64(2), pairs[1].A.ID)
+		assert.Equal(t, int64(4), pairs[1].B.ID)
+	}
+
+	// 可信度相同時選擇距離較近的對象
+	tiedFar := intent(5, 50, 25.060, intPtr(80))
+	tiedNear := intent(6, 60, 25.034, intPtr(80))
+	pairs = Match([]Intent{a, tiedFar, tiedNear}, venues, now, 0)
+	if assert.Len(t, pairs, 1) {
+		assert.Equal(t, int64(6), pairs[0].B.ID)
+	}
+}
+
+func TestMatchSkipsBlockedUsers(t *testing.T) {
+	now := time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+	venues := []Venue{{ActivityID: 1, Latitude: 25.033, Longitude: 121.565, Duration: time.Hour}}
+	intent := func(id, userID int64, lat float64) Intent {
+		return Intent{ID: id, UserID: userID, ActivityID: 1, Latitude: lat, Longitude: 121.565, MaxDistanceKm: 5,
+			WindowStart: now, WindowEnd: now.Add(2 * time.Hour), CreatedAt: now.Add(time.Duration(id) * time.Second)}
+	}
+	a := intent(1, 10, 25.033)
+	// 最近的對象被 a 封鎖，改配給下一位
+	blocked := intent(2, 20, 25.033)
+	next := intent(3, 30, 25.040)
+	a.Blocked = map[int64]bool{20: true}
+
+	pairs := Match([]Intent{a, blocked, next}, venues, now, 0)
+	if assert.Len(t, pairs, 1) {
+		assert.Equal(t, int64(30), pairs[0].B.UserID)
+	}
+
+	// 只記錄在其中一方時同樣不配對
+	a.Blocked = nil
+	blocked.Blocked = map[int64]bool{10: true}
+	assert.Empty(t, Match([]Intent{a, blocked}, venues, now, 0))
+}
+
+func TestMatchOnlyPairsTheNewIntent(t *testing.T) {
+	now := time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+	venues := []Venue{{ActivityID: 1, Latitude: 25.033, Longitude: 121.565, Duration: time.Hour}}
+	intent := func(id, userID int64, lat float64, reliability *int) Intent {
+		return Intent{ID: id, UserID: userID, ActivityID: 1, Latitude: lat, Longitude: 121.565, MaxDistanceKm: 5, Reliability: reliability,
+			WindowStart: now, WindowEnd: now.Add(2 * time.Hour), CreatedAt: now.Add(time.Duration(id) * time.Second)}
+	}
+	near := intent(1, 10, 25.033, nil)
+	trusted := intent(2, 20, 25.040, intPtr(90))
+	fresh := intent(3, 30, 25.033, nil)
+
+	// 選擇方式與 Match 相同，較早建立的意向為開局者
+	pair, ok := MatchOne(fresh, []Intent{near, trusted}, venues, now, 0)
+	if assert.True(t, ok) {
+		assert.Equal(t, int64(20), pair.A.UserID)
+		assert.Equal(t, int64(30), pair.B.UserID)
+	}
+
+	_, ok = MatchOne(fresh, []Intent{intent(4, 30, 25.033, nil)}, venues, now, 0)
+	assert.False(t, ok, "同一位使用者的意向不會互相配對")
+}
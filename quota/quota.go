@@ -0,0 +1,172 @@
+package quota
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"free2free/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Status 使用者目前適用的配額與使用量
+type Status struct {
+	UserID int64 `json:"user_id"`
+	// 完成的配對局 (參與或開局) 少於門檻的新帳號使用較嚴格的配額
+	NewAccount       bool                      `json:"new_account"`
+	CompletedMatches int64                     `json:"completed_matches"`
+	Limits           models.UserQuota          `json:"limits"`
+	Usage            models.UserQuota          `json:"usage"`
+	Override         *models.UserQuotaOverride `json:"override,omitempty"`
+}
+
+// ExceededError 使用量已達配額上限
+type ExceededError struct {
+	Message string
+}
+
+func (e *ExceededError) Error() string {
+	return e.Message
+}
+
+// CheckCreateMatch 在建立配對局的交易中確認沒有超過開局配額
+func CheckCreateMatch(tx *gorm.DB, userID int64) error {
+	status, err := Lock(tx, userID)
+	if err != nil {
+		return err
+	}
+	if err := exceeded(status, status.Limits.OpenMatches, status.Usage.OpenMatches, "已達同時開局的上限 (%d 場)"); err != nil {
+		return err
+	}
+	return exceeded(status, status.Limits.DailyMatches, status.Usage.DailyMatches, "已達 24 小時內建立配對局的上限 (%d 場)")
+}
+
+// CheckJoin 在參與配對局的交易中確認沒有超過參與配額
+func CheckJoin(tx *gorm.DB, userID int64) error {
+	status, err := Lock(tx, userID)
+	if err != nil {
+		return err
+	}
+	if err := exceeded(status, status.Limits.PendingRequests, status.Usage.PendingRequests, "已達等待審核申請的上限 (%d 個)"); err != nil {
+		return err
+	}
+	return exceeded(status, status.Limits.DailyJoins, status.Usage.DailyJoins, "已達 24 小時內參與配對局的上限 (%d 場)")
+}
+
+// Lock 鎖定使用者後計算配額，同一位使用者同時送出的請求依序檢查，不會一起超過上限
+func Lock(tx *gorm.DB, userID int64) (*Status, error) {
+	var user models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	return Load(tx, userID, time.Now())
+}
+
+// exceeded 使用量已達上限時回傳 ExceededError，上限為 0 表示不限制
+func exceeded(status *Status, limit, used int, format string) error {
+	if limit == 0 || used < limit {
+		return nil
+	}
+	message := fmt.Sprintf(format, limit)
+	if status.NewAccount {
+		message += fmt.Sprintf("，完成 %d 場配對局後會提高上限", TrustedAfterCompletedMatches())
+	}
+	return &ExceededError{Message: message}
+}
+
+// Load 計算使用者適用的配額與目前的使用量；已過期的調整只列出，不套用
+func Load(db *gorm.DB, userID int64, now time.Time) (*Status, error) {
+	status := Status{UserID: userID}
+
+	participated, err := CompletedParticipations(db, userID)
+	if err != nil {
+		return nil, err
+	}
+	var organized int64
+	if err := db.Model(&models.Match{}).Where("organizer_id = ? AND status = ?", userID, "completed").Count(&organized).Error; err != nil {
+		return nil, err
+	}
+	status.CompletedMatches = participated + organized
+	status.NewAccount = status.CompletedMatches < int64(TrustedAfterCompletedMatches())
+	status.Limits = Base(status.NewAccount)
+
+	var override models.UserQuotaOverride
+	err = db.Where("user_id = ?", userID).First(&override).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if err == nil {
+		status.Override = &override
+		if override.ExpiresAt == nil || override.ExpiresAt.After(now) {
+			status.Limits = override.Apply(status.Limits)
+		}
+	}
+
+	since := now.Add(-24 * time.Hour)
+	var openMatches, pending, dailyMatches, dailyJoins int64
+	if err := db.Model(&models.Match{}).Where("organizer_id = ? AND status = ?", userID, "open").Count(&openMatches).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Model(&models.MatchParticipant{}).Where("user_id = ? AND status = ?", userID, "pending").Count(&pending).Error; err != nil {
+		return nil, err
+	}
+	// 配對局沒有建立時間，以建立時的狀態變更紀錄計算；系列與自動配對產生的配對局沒有執行者，不列入
+	err = db.Model(&models.MatchEvent{}).
+		Where("actor_id = ? AND participant_id IS NULL AND from_status = ? AND created_at >= ?", userID, "", since).
+		Count(&dailyMatches).Error
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Model(&models.MatchParticipant{}).Where("user_id = ? AND joined_at >= ?", userID, since).Count(&dailyJoins).Error; err != nil {
+		return nil, err
+	}
+	status.Usage = models.UserQuota{
+		OpenMatches:     int(openMatches),
+		PendingRequests: int(pending),
+		DailyMatches:    int(dailyMatches),
+		DailyJoins:      int(dailyJoins),
+	}
+	return &status, nil
+}
+
+// CompletedParticipations 計算使用者以參與者身份完成的配對局數
+func CompletedParticipations(db *gorm.DB, userID int64) (int64, error) {
+	var completed int64
+	err := db.Model(&models.MatchParticipant{}).
+		Joins("JOIN matches ON matches.id = match_participants.match_id").
+		Where("match_participants.user_id = ? AND match_participants.status IN ? AND matches.status = ?", userID, models.ApprovedParticipantStatuses, "completed").
+		Count(&completed).Error
+	return completed, err
+}
+
+// Base 回傳新帳號或一般帳號的預設配額，可以用環境變數 QUOTA_* 與 NEW_ACCOUNT_QUOTA_* 調整
+func Base(newAccount bool) models.UserQuota {
+	prefix, quota := "QUOTA_", models.DefaultUserQuota
+	if newAccount {
+		prefix, quota = "NEW_ACCOUNT_QUOTA_", models.NewAccountQuota
+	}
+	return models.UserQuota{
+		OpenMatches:     envInt(prefix+"OPEN_MATCHES", quota.OpenMatches),
+		PendingRequests: envInt(prefix+"PENDING_REQUESTS", quota.PendingRequests),
+		DailyMatches:    envInt(prefix+"DAILY_MATCHES", quota.DailyMatches),
+		DailyJoins:      envInt(prefix+"DAILY_JOINS", quota.DailyJoins),
+	}
+}
+
+// TrustedAfterCompletedMatches 回傳解除新帳號配額需要完成的配對局數，可以用環境變數 QUOTA_TRUSTED_AFTER 調整
+func TrustedAfterCompletedMatches() int {
+	return envInt("QUOTA_TRUSTED_AFTER", models.TrustedAfterCompletedMatches)
+}
+
+// envInt 讀取非負整數的環境變數，未設定或無效時使用預設值
+func envInt(name string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || value < 0 {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,34 @@
+package quota
+
+import (
+	"testing"
+
+	"free2free/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBase(t *testing.T) {
+	assert.Equal(t, models.DefaultUserQuota, Base(false))
+	assert.Equal(t, models.NewAccountQuota, Base(true))
+
+	// 環境變數可以調整預設配額，無效的值沿用預設
+	t.Setenv("NEW_ACCOUNT_QUOTA_OPEN_MATCHES", "1")
+	t.Setenv("NEW_ACCOUNT_QUOTA_DAILY_JOINS", "-1")
+	limits := Base(true)
+	assert.Equal(t, 1, limits.OpenMatches)
+	assert.Equal(t, models.NewAccountQuota.DailyJoins, limits.DailyJoins)
+}
+
+func TestExceeded(t *testing.T) {
+	status := &Status{NewAccount: true}
+	assert.NoError(t, exceeded(status, 0, 100, "%d"), "上限為 0 表示不限制")
+	assert.NoError(t, exceeded(status, 3, 2, "%d"))
+
+	err := exceeded(status, 3, 3, "已達上限 (%d)")
+	var exceededErr *ExceededError
+	if assert.ErrorAs(t, err, &exceededErr) {
+		assert.Contains(t, exceededErr.Message, "已達上限 (3)")
+		assert.Contains(t, exceededErr.Message, "完成")
+	}
+}
@@ -0,0 +1,137 @@
+package recurrence
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	// 內嵌時區資料，容器中沒有 zoneinfo 時也能載入 Asia/Taipei 等時區
+	_ "time/tzdata"
+)
+
+// maxIterations 展開規則時的迴圈上限，避免錯誤的規則造成無限迴圈
+const maxIterations = 10000
+
+// Rule RRULE 的子集合，支援 FREQ=DAILY|WEEKLY、INTERVAL 與 BYDAY
+type Rule struct {
+	Freq     string
+	Interval int
+	ByDay    []time.Weekday
+}
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// Parse 解析規則字串，例如 "FREQ=WEEKLY;INTERVAL=1;BYDAY=TU,TH"
+func Parse(rule string) (Rule, error) {
+	r := Rule{Interval: 1}
+	for _, part := range strings.Split(strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:"), ";") {
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return Rule{}, fmt.Errorf("無效的規則片段: %s", part)
+		}
+		key, value := strings.ToUpper(kv[0]), strings.ToUpper(kv[1])
+		switch key {
+		case "FREQ":
+			if value != "DAILY" && value != "WEEKLY" {
+				return Rule{}, fmt.Errorf("FREQ 只支援 DAILY 或 WEEKLY")
+			}
+			r.Freq = value
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 || n > 52 {
+				return Rule{}, fmt.Errorf("INTERVAL 必須介於 1 到 52 之間")
+			}
+			r.Interval = n
+		case "BYDAY":
+			seen := map[time.Weekday]bool{}
+			for _, day := range strings.Split(value, ",") {
+				wd, ok := weekdays[day]
+				if !ok {
+					return Rule{}, fmt.Errorf("無效的 BYDAY: %s", day)
+				}
+				if !seen[wd] {
+					seen[wd] = true
+					r.ByDay = append(r.ByDay, wd)
+				}
+			}
+		default:
+			return Rule{}, fmt.Errorf("不支援的規則欄位: %s", key)
+		}
+	}
+
+	if r.Freq == "" {
+		return Rule{}, fmt.Errorf("缺少 FREQ")
+	}
+	if r.Freq == "DAILY" && len(r.ByDay) > 0 {
+		return Rule{}, fmt.Errorf("BYDAY 只能搭配 FREQ=WEEKLY")
+	}
+	sort.Slice(r.ByDay, func(i, j int) bool {
+		return mondayIndex(r.ByDay[i]) < mondayIndex(r.ByDay[j])
+	})
+	return r, nil
+}
+
+// Between 回傳從 dtstart 起、不晚於 until，且落在 [from, to) 之間的所有發生時間
+// 時間以 dtstart 的時區展開，因此跨越日光節約時間時仍維持相同的當地時刻
+func (r Rule) Between(dtstart, until, from, to time.Time) []time.Time {
+	var result []time.Time
+	loc := dtstart.Location()
+	hour, min, sec := dtstart.Clock()
+	nsec := dtstart.Nanosecond()
+	year, month, day := dtstart.Date()
+
+	include := func(t time.Time) {
+		if !t.Before(dtstart) && !t.After(until) && !t.Before(from) && t.Before(to) {
+			result = append(result, t)
+		}
+	}
+	done := func(t time.Time) bool {
+		return t.After(until) || !t.Before(to)
+	}
+
+	switch r.Freq {
+	case "DAILY":
+		for i := 0; i < maxIterations; i++ {
+			t := time.Date(year, month, day+i*r.Interval, hour, min, sec, nsec, loc)
+			if done(t) {
+				break
+			}
+			include(t)
+		}
+	case "WEEKLY":
+		days := r.ByDay
+		if len(days) == 0 {
+			days = []time.Weekday{dtstart.Weekday()}
+		}
+		// 以 dtstart 所在週的星期一為基準，每 Interval 週展開一次
+		weekStart := day - mondayIndex(dtstart.Weekday())
+		for w := 0; w < maxIterations; w++ {
+			base := weekStart + w*7*r.Interval
+			if done(time.Date(year, month, base, hour, min, sec, nsec, loc)) {
+				break
+			}
+			for _, wd := range days {
+				include(time.Date(year, month, base+mondayIndex(wd), hour, min, sec, nsec, loc))
+			}
+		}
+	}
+	return result
+}
+
+// mondayIndex 以星期一為 0 的星期序號
+func mondayIndex(wd time.Weekday) int {
+	return (int(wd) + 6) % 7
+}
@@ -0,0 +1,69 @@
+package recurrence
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	r, err := Parse("FREQ=WEEKLY;BYDAY=TH,TU")
+	assert.NoError(t, err)
+	assert.Equal(t, "WEEKLY", r.Freq)
+	assert.Equal(t, 1, r.Interval)
+	assert.Equal(t, []time.Weekday{time.Tuesday, time.Thursday}, r.ByDay)
+
+	r, err = Parse("RRULE:FREQ=DAILY;INTERVAL=2")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, r.Interval)
+
+	for _, bad := range []string{"", "FREQ=MONTHLY", "FREQ=DAILY;BYDAY=MO", "FREQ=WEEKLY;BYDAY=XX", "FREQ=WEEKLY;INTERVAL=0", "FREQ=WEEKLY;COUNT=3"} {
+		_, err := Parse(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestWeeklyBetween(t *testing.T) {
+	loc, err := time.LoadLocation("Asia/Taipei")
+	assert.NoError(t, err)
+
+	// 2026-10-20 是星期二
+	dtstart := time.Date(2026, 10, 20, 8, 0, 0, 0, loc)
+	until := time.Date(2026, 11, 30, 0, 0, 0, 0, loc)
+	r, _ := Parse("FREQ=WEEKLY")
+
+	got := r.Between(dtstart, until, dtstart, dtstart.AddDate(0, 0, 21))
+	assert.Equal(t, []time.Time{
+		dtstart,
+		dtstart.AddDate(0, 0, 7),
+		dtstart.AddDate(0, 0, 14),
+	}, got)
+
+	// 每兩週的星期一與星期四，起始日之前的星期一不包含在內
+	r, _ = Parse("FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH")
+	// 11/30 08:00 晚於 until (11/30 00:00)，不應包含
+	got = r.Between(dtstart, until, dtstart, until)
+	assert.Equal(t, []time.Time{
+		time.Date(2026, 10, 22, 8, 0, 0, 0, loc),
+		time.Date(2026, 11, 2, 8, 0, 0, 0, loc),
+		time.Date(2026, 11, 5, 8, 0, 0, 0, loc),
+		time.Date(2026, 11, 16, 8, 0, 0, 0, loc),
+		time.Date(2026, 11, 19, 8, 0, 0, 0, loc),
+	}, got)
+}
+
+func TestDailyBetweenRespectsWindow(t *testing.T) {
+	dtstart := time.Date(2026, 1, 1, 9, 30, 0, 0, time.UTC)
+	until := time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC)
+	r, _ := Parse("FREQ=DAILY;INTERVAL=3")
+
+	from := time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 1, 14, 0, 0, 0, 0, time.UTC)
+	got := r.Between(dtstart, until, from, to)
+	assert.Equal(t, []time.Time{
+		time.Date(2026, 1, 7, 9, 30, 0, 0, time.UTC),
+		time.Date(2026, 1, 10, 9, 30, 0, 0, time.UTC),
+		time.Date(2026, 1, 13, 9, 30, 0, 0, time.UTC),
+	}, got)
+}
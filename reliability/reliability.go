@@ -0,0 +1,68 @@
+package reliability
+
+import (
+	"math"
+
+	"free2free/models"
+
+	"gorm.io/gorm"
+)
+
+// Reliability 使用者的出席紀錄與可信度
+type Reliability struct {
+	// Score 為 0-100，沒有任何出席紀錄時為 null
+	Score      *int  `json:"score"`
+	Attended   int64 `json:"attended"`
+	NoShow     int64 `json:"no_show"`
+	LateCancel int64 `json:"late_cancel"`
+}
+
+// Load 依出席紀錄計算多位使用者的可信度，沒有紀錄的使用者回傳零值
+func Load(db *gorm.DB, userIDs []int64) (map[int64]Reliability, error) {
+	result := make(map[int64]Reliability, len(userIDs))
+	if len(userIDs) == 0 {
+		return result, nil
+	}
+
+	var rows []struct {
+		UserID int64
+		Status string
+		Count  int64
+	}
+	err := db.Model(&models.MatchParticipant{}).
+		Select("user_id, status, COUNT(*) AS count").
+		Where("user_id IN ? AND status IN ?", userIDs, models.AttendanceStatuses).
+		Group("user_id, status").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		r := result[row.UserID]
+		switch row.Status {
+		case "attended":
+			r.Attended = row.Count
+		case "no_show":
+			r.NoShow = row.Count
+		case "late_cancel":
+			r.LateCancel = row.Count
+		}
+		result[row.UserID] = r
+	}
+	for id, r := range result {
+		r.Score = Score(r)
+		result[id] = r
+	}
+	return result, nil
+}
+
+// Score 出席次數佔所有紀錄的百分比，臨時取消以半次出席計算；沒有紀錄時回傳 nil
+func Score(r Reliability) *int {
+	total := r.Attended + r.NoShow + r.LateCancel
+	if total == 0 {
+		return nil
+	}
+	score := int(math.Round(100 * (float64(r.Attended) + 0.5*float64(r.LateCancel)) / float64(total)))
+	return &score
+}
@@ -0,0 +1,22 @@
+package reliability
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScore(t *testing.T) {
+	assert.Nil(t, Score(Reliability{}))
+
+	score := Score(Reliability{Attended: 3, NoShow: 1})
+	if assert.NotNil(t, score) {
+		assert.Equal(t, 75, *score)
+	}
+
+	// 臨時取消以半次出席計算
+	score = Score(Reliability{Attended: 1, NoShow: 1, LateCancel: 2})
+	if assert.NotNil(t, score) {
+		assert.Equal(t, 50, *score)
+	}
+}
@@ -0,0 +1,129 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"free2free/models"
+
+	apperrors "free2free/errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateMatchRespectsActivitySchedule(t *testing.T) {
+	db := setupUserTestDatabase(t)
+
+	organizer := seedUser(t, db, "organizer")
+	seeded := seedMatch(t, db, organizer.ID)
+
+	loc, err := time.LoadLocation("Asia/Taipei")
+	assert.NoError(t, err)
+	local := time.Now().In(loc).AddDate(0, 0, 2)
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	endsAt := day.AddDate(0, 0, 7)
+
+	// 優惠期間到 endsAt，只有 day 當天星期幾的 11:00-14:00 可以開局
+	activity := models.Activity{ID: seeded.ActivityID}
+	assert.NoError(t, db.Model(&activity).Updates(map[string]interface{}{"ends_at": endsAt, "timezone": "Asia/Taipei"}).Error)
+	assert.NoError(t, db.Create(&models.ActivityAvailability{ActivityID: activity.ID, Weekday: int(day.Weekday()), StartTime: "11:00", EndTime: "14:00"}).Error)
+
+	create := func(start time.Time) *apperrors.AppError {
+		body := fmt.Sprintf(`{"activity_id": %d, "organizer_id": 1, "status": "open", "match_time": "%s", "end_time": "%s"}`,
+			activity.ID, start.Format(time.RFC3339), start.Add(time.Hour).Format(time.RFC3339))
+		c, w := newUserContext("POST", "/", []byte(body), organizer.ID)
+		createMatch(c)
+		if len(c.Errors) == 0 {
+			assert.Equal(t, http.StatusCreated, w.Code)
+			return nil
+		}
+		appErr, _ := c.Errors.Last().Err.(*apperrors.AppError)
+		return appErr
+	}
+	outside := func(start time.Time) {
+		if appErr := create(start); assert.NotNil(t, appErr, start.String()) {
+			assert.Equal(t, ErrCodeOutsideActivitySchedule, appErr.ErrorCode)
+		}
+	}
+
+	assert.Nil(t, create(day.Add(12*time.Hour)))
+	outside(day.Add(13*time.Hour + 30*time.Minute))   // 超過時段結束
+	outside(day.AddDate(0, 0, 1).Add(12 * time.Hour)) // 其他星期
+	outside(day.AddDate(0, 0, 7).Add(12 * time.Hour)) // 優惠已結束
+
+	// 封存的活動不能再開局
+	assert.NoError(t, db.Model(&activity).Update("archived_at", time.Now()).Error)
+	outside(day.Add(12 * time.Hour))
+
+	// 時段設定需要是有效的 HH:MM 且結束晚於開始
+	invalid := []models.ActivityAvailability{{Weekday: 1, StartTime: "14:00", EndTime: "11:00"}, {Weekday: 1, StartTime: "11:00", EndTime: "25:00"}}
+	for _, rule := range invalid {
+		schedule := models.Activity{Availability: []models.ActivityAvailability{rule}}
+		assert.Error(t, schedule.ValidateSchedule())
+	}
+	valid := models.Activity{Availability: []models.ActivityAvailability{{Weekday: 6, StartTime: "18:00", EndTime: "24:00"}}}
+	assert.NoError(t, valid.ValidateSchedule())
+}
+
+func TestSeriesRespectsActivitySchedule(t *testing.T) {
+	db := setupUserTestDatabase(t)
+
+	organizer := seedUser(t, db, "organizer")
+	seeded := seedMatch(t, db, organizer.ID)
+
+	loc, err := time.LoadLocation("Asia/Taipei")
+	assert.NoError(t, err)
+	local := time.Now().In(loc).AddDate(0, 0, 2)
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+
+	// 每天 11:00-14:00 可以開局
+	activity := models.Activity{ID: seeded.ActivityID}
+	assert.NoError(t, db.Model(&activity).Updates(map[string]interface{}{"ends_at": day.AddDate(0, 1, 0), "timezone": "Asia/Taipei"}).Error)
+	for weekday := 0; weekday < 7; weekday++ {
+		assert.NoError(t, db.Create(&models.ActivityAvailability{ActivityID: activity.ID, Weekday: weekday, StartTime: "11:00", EndTime: "14:00"}).Error)
+	}
+
+	create := func(start time.Time) (*SeriesDetail, *apperrors.AppError) {
+		body := fmt.Sprintf(`{"activity_id": %d, "rule": "FREQ=DAILY", "start_time": "%s", "until": "%s", "days_ahead": 3, "duration_minutes": 60}`,
+			activity.ID, start.Format(time.RFC3339), start.AddDate(0, 0, 10).Format(time.RFC3339))
+		c, w := newUserContext("POST", "/", []byte(body), organizer.ID)
+		createSeries(c)
+		if len(c.Errors) > 0 {
+			appErr, _ := c.Errors.Last().Err.(*apperrors.AppError)
+			return nil, appErr
+		}
+		var detail SeriesDetail
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &detail))
+		return &detail, nil
+	}
+
+	// 第一個場次不在可用時段內
+	if _, appErr := create(day.Add(18 * time.Hour)); assert.NotNil(t, appErr) {
+		assert.Equal(t, ErrCodeOutsideActivitySchedule, appErr.ErrorCode)
+	}
+
+	detail, appErr := create(day.Add(12 * time.Hour))
+	if !assert.Nil(t, appErr) || !assert.NotEmpty(t, detail.UpcomingMatches) {
+		return
+	}
+
+	// 單獨修改場次時同樣檢查可用時段
+	occurrence := detail.UpcomingMatches[0]
+	body, _ := json.Marshal(map[string]interface{}{"match_time": occurrence.MatchTime.Add(6 * time.Hour)})
+	c, _ := newUserContext("PUT", "/", body, organizer.ID)
+	c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(detail.ID)}, {Key: "match_id", Value: fmt.Sprint(occurrence.ID)}}
+	updateOccurrence(c)
+	if appErr, ok := c.Errors.Last().Err.(*apperrors.AppError); assert.True(t, ok) {
+		assert.Equal(t, ErrCodeOutsideActivitySchedule, appErr.ErrorCode)
+	}
+
+	// 封存的活動不能再建立系列
+	assert.NoError(t, db.Model(&activity).Update("archived_at", time.Now()).Error)
+	if _, appErr := create(day.Add(12 * time.Hour)); assert.NotNil(t, appErr) {
+		assert.Equal(t, ErrCodeOutsideActivitySchedule, appErr.ErrorCode)
+	}
+}
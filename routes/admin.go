@@ -4,9 +4,12 @@ import (
 	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"free2free/models"
 	"free2free/database"
+	"free2free/geo"
+	"free2free/search"
 	"free2free/utils"
 
 	apperrors "free2free/errors"
@@ -14,6 +17,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // AdminAuthMiddleware 管理員認證中介層
@@ -63,32 +67,82 @@ func SetupAdminRoutes(r *gin.Engine) {
 		admin.POST("/locations", createLocation)
 		admin.PUT("/locations/:id", updateLocation)
 		admin.DELETE("/locations/:id", deleteLocation)
+
+		// 背景工作執行紀錄
+		admin.GET("/jobs/runs", listJobRuns)
+
+		// 訊息檢舉
+		admin.GET("/message-reports", listMessageReports)
+
+		// 配對局與參與者狀態變更紀錄
+		admin.GET("/events", listMatchEvents)
+
+		// 使用者配額調整
+		admin.GET("/users/:id/quota", getUserQuota)
+		admin.PUT("/users/:id/quota", setUserQuota)
+		admin.DELETE("/users/:id/quota", deleteUserQuota)
 	}
 }
 
 // listActivities 取得配對活動列表
 // @Summary 取得配對活動列表
-// @Description 取得所有配對活動的列表
+// @Description 取得配對活動的列表，支援游標分頁、篩選與排序
 // @Tags 管理員
 // @Accept json
 // @Produce json
+// @Param location_id query int false "地點ID"
+// @Param archived query bool false "只列出已封存 (true) 或未封存 (false) 的活動"
+// @Param sort query string false "排序欄位 (id、title)，前綴 - 表示遞減，預設 -id"
+// @Param limit query int false "每頁筆數 (1-100)，預設 20"
+// @Param cursor query string false "上一頁回傳的 X-Next-Cursor"
 // @Success 200 {array} Activity
+// @Header 200 {integer} X-Total-Count "符合條件的總筆數"
+// @Header 200 {string} X-Next-Cursor "下一頁游標，沒有下一頁時為空"
+// @Failure 400 {object} map[string]string "無效的查詢參數"
 // @Failure 500 {object} map[string]string "無法取得活動列表"
 // @Router /admin/activities [get]
 // @Security ApiKeyAuth
 func listActivities(c *gin.Context) {
-	var activities []models.Activity
-	if err := database.GlobalDB.Conn.Preload("Location").Order("id DESC").Find(&activities).Error; err != nil {
-		c.Error(apperrors.MapGORMError(err))
+	query := database.GlobalDB.Conn.Model(&models.Activity{})
+	if locationID, ok, err := queryInt64(c, "location_id"); err != nil {
+		c.Error(err)
+		return
+	} else if ok {
+		query = query.Where("location_id = ?", locationID)
+	}
+	if archived, ok, err := queryEnum(c, "archived", "true", "false"); err != nil {
+		c.Error(err)
+		return
+	} else if ok && archived == "true" {
+		query = query.Where("archived_at IS NOT NULL")
+	} else if ok {
+		query = query.Where("archived_at IS NULL")
+	}
+
+	activities, err := Paginate(c, query, activityListSpec)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
 	c.JSON(http.StatusOK, activities)
 }
 
+// activityListSpec 活動列表可用的排序欄位
+var activityListSpec = ListSpec[models.Activity]{
+	IDColumn: "id",
+	ID:       func(a models.Activity) int64 { return a.ID },
+	Sorts: map[string]SortField[models.Activity]{
+		"id":    {Column: "id", Kind: sortInt, Value: func(a models.Activity) interface{} { return a.ID }},
+		"title": {Column: "title", Kind: sortString, Value: func(a models.Activity) interface{} { return a.Title }},
+	},
+	DefaultSort: "-id",
+	Preloads:    []string{"Location", "Availability"},
+}
+
 // createActivity 建立新的配對活動
 // @Summary 建立新的配對活動
-// @Description 建立新的配對活動
+// @Description 建立新的配對活動，可以設定優惠期間 (starts_at、ends_at) 與每週可用時段 (availability)，配對局只能在這些時間內開局
 // @Tags 管理員
 // @Accept json
 // @Produce json
@@ -111,6 +165,10 @@ func createActivity(c *gin.Context) {
 		c.Error(apperrors.NewValidationError(err.Error()))
 		return
 	}
+	if err := activity.ValidateSchedule(); err != nil {
+		c.Error(apperrors.NewValidationError(err.Error()))
+		return
+	}
 
 	// 檢查地點是否存在
 	var location models.Location
@@ -138,12 +196,14 @@ func createActivity(c *gin.Context) {
 		return
 	}
 
+	search.Current().Upsert(search.KindActivity, activity.ID, activity.Title, activity.Description)
+
 	c.JSON(http.StatusCreated, activity)
 }
 
 // updateActivity 更新配對活動
 // @Summary 更新配對活動
-// @Description 更新指定ID的配對活動
+// @Description 更新指定ID的配對活動，每週可用時段整批取代；延長已結束的優惠期間會解除封存
 // @Tags 管理員
 // @Accept json
 // @Produce json
@@ -174,6 +234,10 @@ func updateActivity(c *gin.Context) {
 		c.Error(apperrors.NewValidationError(err.Error()))
 		return
 	}
+	if err := activity.ValidateSchedule(); err != nil {
+		c.Error(apperrors.NewValidationError(err.Error()))
+		return
+	}
 
 	// 檢查地點是否存在
 	var location models.Location
@@ -186,13 +250,37 @@ func updateActivity(c *gin.Context) {
 		return
 	}
 
-	// 更新活動
-	if err := database.GlobalDB.Conn.Model(&models.Activity{}).Where("id = ?", id).Updates(activity).Error; err != nil {
+	// 更新活動；優惠期間可以清除，每週可用時段整批取代，延長已結束的優惠時解除封存
+	err = database.GlobalDB.Conn.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Activity{}).Where("id = ?", id).Omit(clause.Associations).Updates(activity).Error; err != nil {
+			return err
+		}
+		schedule := map[string]interface{}{"starts_at": activity.StartsAt, "ends_at": activity.EndsAt}
+		if activity.EndsAt == nil || activity.EndsAt.After(time.Now()) {
+			schedule["archived_at"] = nil
+		}
+		if err := tx.Model(&models.Activity{}).Where("id = ?", id).Updates(schedule).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("activity_id = ?", id).Delete(&models.ActivityAvailability{}).Error; err != nil {
+			return err
+		}
+		for i := range activity.Availability {
+			activity.Availability[i].ID = 0
+			activity.Availability[i].ActivityID = id
+		}
+		if len(activity.Availability) > 0 {
+			return tx.Create(&activity.Availability).Error
+		}
+		return nil
+	})
+	if err != nil {
 		c.Error(apperrors.MapGORMError(err))
 		return
 	}
 
 	activity.ID = id
+	search.Current().Upsert(search.KindActivity, activity.ID, activity.Title, activity.Description)
 	c.JSON(http.StatusOK, activity)
 }
 
@@ -221,29 +309,48 @@ func deleteActivity(c *gin.Context) {
 		return
 	}
 
+	search.Current().Delete(search.KindActivity, id)
+
 	c.JSON(http.StatusOK, gin.H{"message": "活動已刪除"})
 }
 
 // listLocations 取得地點列表
 // @Summary 取得地點列表
-// @Description 取得所有地點的列表
+// @Description 取得地點的列表，支援游標分頁與排序
 // @Tags 管理員
 // @Accept json
 // @Produce json
+// @Param sort query string false "排序欄位 (id、name)，前綴 - 表示遞減，預設 -id"
+// @Param limit query int false "每頁筆數 (1-100)，預設 20"
+// @Param cursor query string false "上一頁回傳的 X-Next-Cursor"
 // @Success 200 {array} Location
+// @Header 200 {integer} X-Total-Count "符合條件的總筆數"
+// @Header 200 {string} X-Next-Cursor "下一頁游標，沒有下一頁時為空"
+// @Failure 400 {object} map[string]string "無效的查詢參數"
 // @Failure 500 {object} map[string]string "無法取得地點列表"
 // @Router /admin/locations [get]
 // @Security ApiKeyAuth
 func listLocations(c *gin.Context) {
-	var locations []models.Location
-	if err := database.GlobalDB.Conn.Order("id DESC").Find(&locations).Error; err != nil {
-		c.Error(apperrors.MapGORMError(err))
+	locations, err := Paginate(c, database.GlobalDB.Conn.Model(&models.Location{}), locationListSpec)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
 	c.JSON(http.StatusOK, locations)
 }
 
+// locationListSpec 地點列表可用的排序欄位
+var locationListSpec = ListSpec[models.Location]{
+	IDColumn: "id",
+	ID:       func(l models.Location) int64 { return l.ID },
+	Sorts: map[string]SortField[models.Location]{
+		"id":   {Column: "id", Kind: sortInt, Value: func(l models.Location) interface{} { return l.ID }},
+		"name": {Column: "name", Kind: sortString, Value: func(l models.Location) interface{} { return l.Name }},
+	},
+	DefaultSort: "-id",
+}
+
 // createLocation 建立新的地點
 // @Summary 建立新的地點
 // @Description 建立新的地點
@@ -270,11 +377,15 @@ func createLocation(c *gin.Context) {
 		return
 	}
 
+	location.Geohash = geo.Encode(location.Latitude, location.Longitude, geo.MaxPrecision)
+
 	if err := database.GlobalDB.Conn.Create(&location).Error; err != nil {
 		c.Error(apperrors.MapGORMError(err))
 		return
 	}
 
+	search.Current().Upsert(search.KindLocation, location.ID, location.Name, location.Address)
+
 	c.JSON(http.StatusCreated, location)
 }
 
@@ -312,6 +423,8 @@ func updateLocation(c *gin.Context) {
 		return
 	}
 
+	location.Geohash = geo.Encode(location.Latitude, location.Longitude, geo.MaxPrecision)
+
 	// 更新地點
 	if err := database.GlobalDB.Conn.Model(&models.Location{}).Where("id = ?", id).Updates(location).Error; err != nil {
 		c.Error(apperrors.MapGORMError(err))
@@ -319,6 +432,7 @@ func updateLocation(c *gin.Context) {
 	}
 
 	location.ID = id
+	search.Current().Upsert(search.KindLocation, location.ID, location.Name, location.Address)
 	c.JSON(http.StatusOK, location)
 }
 
@@ -347,5 +461,81 @@ func deleteLocation(c *gin.Context) {
 		return
 	}
 
+	search.Current().Delete(search.KindLocation, id)
+
 	c.JSON(http.StatusOK, gin.H{"message": "地點已刪除"})
 }
+
+// listJobRuns 取得背景工作執行紀錄
+// @Summary 取得背景工作執行紀錄
+// @Description 取得最近的背景工作執行紀錄，可依工作名稱篩選
+// @Tags 管理員
+// @Accept json
+// @Produce json
+// @Param job query string false "工作名稱"
+// @Success 200 {array} JobRun
+// @Failure 500 {object} map[string]string "無法取得執行紀錄"
+// @Router /admin/jobs/runs [get]
+// @Security ApiKeyAuth
+func listJobRuns(c *gin.Context) {
+	query := database.GlobalDB.Conn.Order("started_at DESC").Limit(100)
+	if jobName := c.Query("job"); jobName != "" {
+		query = query.Where("job_name = ?", jobName)
+	}
+
+	var runs []models.JobRun
+	if err := query.Find(&runs).Error; err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, runs)
+}
+
+// messageReportListSpec 訊息檢舉列表可用的排序欄位
+var messageReportListSpec = ListSpec[models.MessageReport]{
+	IDColumn: "message_reports.id",
+	ID:       func(r models.MessageReport) int64 { return r.ID },
+	Sorts: map[string]SortField[models.MessageReport]{
+		"id": {Column: "message_reports.id", Kind: sortInt, Value: func(r models.MessageReport) interface{} { return r.ID }},
+	},
+	DefaultSort: "-id",
+	Preloads:    []string{"Message.Sender", "Reporter"},
+}
+
+// listMessageReports 取得訊息檢舉列表
+// @Summary 取得訊息檢舉列表
+// @Description 取得對話串訊息的檢舉，包含已被發送者刪除的訊息內容，支援游標分頁
+// @Tags 管理員
+// @Accept json
+// @Produce json
+// @Param match_id query int false "配對局ID"
+// @Param limit query int false "每頁筆數 (1-100)，預設 20"
+// @Param cursor query string false "上一頁回傳的 X-Next-Cursor"
+// @Success 200 {array} MessageReport
+// @Header 200 {integer} X-Total-Count "符合條件的總筆數"
+// @Header 200 {string} X-Next-Cursor "下一頁游標，沒有下一頁時為空"
+// @Failure 400 {object} map[string]string "無效的查詢參數"
+// @Failure 500 {object} map[string]string "無法取得檢舉列表"
+// @Router /admin/message-reports [get]
+// @Security ApiKeyAuth
+func listMessageReports(c *gin.Context) {
+	query := database.GlobalDB.Conn.Model(&models.MessageReport{})
+
+	matchID, ok, err := queryInt64(c, "match_id")
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if ok {
+		query = query.Where("message_reports.message_id IN (?)",
+			database.GlobalDB.Conn.Model(&models.MatchMessage{}).Select("id").Where("match_id = ?", matchID))
+	}
+
+	reports, err := Paginate(c, query, messageReportListSpec)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, reports)
+}
@@ -0,0 +1,131 @@
+package routes
+
+import (
+	"net/http"
+
+	"free2free/models"
+	"free2free/quota"
+
+	apperrors "free2free/errors"
+
+	"gorm.io/gorm"
+)
+
+// validateApprovalMode 檢查建立配對局時的審核方式與門檻設定
+func validateApprovalMode(match *models.Match) error {
+	if match.ApprovalMode == "" {
+		match.ApprovalMode = models.ApprovalManual
+	}
+	hasGate := match.GateMinReviewAverage > 0 || match.GateMinCompletedMatches > 0
+	if match.ApprovalMode == models.ApprovalGated && !hasGate {
+		return apperrors.NewValidationError("gated 審核方式需要設定 gate_min_review_average 或 gate_min_completed_matches")
+	}
+	if match.ApprovalMode != models.ApprovalGated && hasGate {
+		return apperrors.NewValidationError("只有 gated 審核方式可以設定自動通過的門檻")
+	}
+	return nil
+}
+
+// shouldAutoApprove 依配對局的審核方式判斷新的參與者是否自動通過；名額已滿時交由開局者審核。
+// match 需在同一個交易中以 FOR UPDATE 讀取，避免同時參與的請求超過名額
+func shouldAutoApprove(tx *gorm.DB, match *models.Match, userID int64) (bool, error) {
+	switch match.ApprovalMode {
+	case models.ApprovalAuto:
+	case models.ApprovalGated:
+		passed, err := meetsApprovalGate(tx, match, userID)
+		if err != nil || !passed {
+			return false, err
+		}
+	default:
+		return false, nil
+	}
+
+	capacity, err := matchCapacity(tx, match)
+	if err != nil {
+		return false, err
+	}
+	seated, err := seatedCount(tx, match.ID)
+	if err != nil {
+		return false, err
+	}
+	return seated < int64(capacity), nil
+}
+
+// matchCapacity 回傳配對局的名額，未設定時使用活動的 target_count
+func matchCapacity(tx *gorm.DB, match *models.Match) (int, error) {
+	if match.Capacity > 0 {
+		return match.Capacity, nil
+	}
+	var activity models.Activity
+	if err := tx.Select("id", "target_count").First(&activity, match.ActivityID).Error; err != nil {
+		return 0, err
+	}
+	return activity.TargetCount, nil
+}
+
+// 審核參與者時回傳的錯誤代碼
+const (
+	ErrCodeMatchFull                = "match_full"
+	ErrCodeInvalidParticipantStatus = "invalid_participant_status"
+)
+
+// approvableStatus 判斷參與者能否被審核通過：只有等待審核與等待重新確認的參與者可以，
+// 被拒絕、移除或釋出名額的參與者不會重新佔用名額
+func approvableStatus(status string) bool {
+	return status == "pending" || status == "reconfirm"
+}
+
+// rejectableStatus 判斷參與者能否被審核拒絕：只有等待審核、已通過與等待重新確認的參與者可以，
+// 移除或釋出名額的參與者保留原本的狀態與理由
+func rejectableStatus(status string) bool {
+	return status == "pending" || status == "approved" || status == "reconfirm"
+}
+
+// checkSeatAvailable 確認配對局還有名額；match 需在同一個交易中以 FOR UPDATE 讀取
+func checkSeatAvailable(tx *gorm.DB, match *models.Match) error {
+	capacity, err := matchCapacity(tx, match)
+	if err != nil {
+		return apperrors.MapGORMError(err)
+	}
+	seated, err := seatedCount(tx, match.ID)
+	if err != nil {
+		return apperrors.MapGORMError(err)
+	}
+	if seated >= int64(capacity) {
+		return apperrors.NewCodedError(http.StatusConflict, ErrCodeMatchFull, "配對局名額已滿")
+	}
+	return nil
+}
+
+// seatedCount 計算佔用名額的參與者數，等待重新確認的參與者仍保留名額
+func seatedCount(tx *gorm.DB, matchID int64) (int64, error) {
+	var seated int64
+	err := tx.Model(&models.MatchParticipant{}).
+		Where("match_id = ? AND status IN ?", matchID, []string{"approved", "reconfirm"}).
+		Count(&seated).Error
+	return seated, err
+}
+
+// meetsApprovalGate 檢查使用者收到的平均評分與以參與者身份完成的配對局數是否達到門檻；沒有評分的使用者不符合評分門檻
+func meetsApprovalGate(tx *gorm.DB, match *models.Match, userID int64) (bool, error) {
+	if match.GateMinReviewAverage > 0 {
+		var average *float64
+		if err := tx.Model(&models.Review{}).Select("AVG(score)").Where("reviewee_id = ?", userID).Scan(&average).Error; err != nil {
+			return false, err
+		}
+		if average == nil || *average < match.GateMinReviewAverage {
+			return false, nil
+		}
+	}
+
+	if match.GateMinCompletedMatches > 0 {
+		completed, err := quota.CompletedParticipations(tx, userID)
+		if err != nil {
+			return false, err
+		}
+		if completed < int64(match.GateMinCompletedMatches) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,70 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"free2free/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApprovalModes(t *testing.T) {
+	db := setupUserTestDatabase(t)
+
+	organizer := seedUser(t, db, "organizer")
+	first := seedUser(t, db, "first")
+	second := seedUser(t, db, "second")
+	third := seedUser(t, db, "third")
+
+	join := func(match models.Match, userID int64) string {
+		c, w := newUserContext("POST", "/", nil, userID)
+		c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(match.ID)}}
+		joinMatch(c)
+		assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var participant models.MatchParticipant
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &participant))
+		return participant.Status
+	}
+
+	// 預設為 manual，所有人都需要審核
+	manual := seedMatch(t, db, organizer.ID)
+	assert.Equal(t, "pending", join(manual, first.ID))
+
+	// auto 在名額 (活動的 target_count = 2) 內直接通過
+	auto := seedMatch(t, db, organizer.ID)
+	assert.NoError(t, db.Model(&auto).Update("approval_mode", models.ApprovalAuto).Error)
+	assert.Equal(t, "approved", join(auto, first.ID))
+	assert.Equal(t, "approved", join(auto, second.ID))
+	assert.Equal(t, "pending", join(auto, third.ID))
+
+	// gated 只讓平均評分達到門檻的使用者直接通過
+	history := seedMatch(t, db, organizer.ID)
+	assert.NoError(t, db.Create(&models.Review{MatchID: history.ID, ReviewerID: organizer.ID, RevieweeID: first.ID, Score: 5, CreatedAt: time.Now()}).Error)
+	assert.NoError(t, db.Create(&models.Review{MatchID: history.ID, ReviewerID: organizer.ID, RevieweeID: second.ID, Score: 2, CreatedAt: time.Now()}).Error)
+
+	gated := seedMatch(t, db, organizer.ID)
+	assert.NoError(t, db.Model(&gated).Updates(map[string]interface{}{"approval_mode": models.ApprovalGated, "gate_min_review_average": 4.0}).Error)
+	assert.Equal(t, "approved", join(gated, first.ID))
+	assert.Equal(t, "pending", join(gated, second.ID))
+	assert.Equal(t, "pending", join(gated, third.ID))
+
+	// 建立配對局時檢查門檻設定
+	activityID := manual.ActivityID
+	start := time.Now().Add(48 * time.Hour).UTC().Format(time.RFC3339)
+	create := func(body string) int {
+		c, w := newUserContext("POST", "/", []byte(body), organizer.ID)
+		createMatch(c)
+		if len(c.Errors) > 0 {
+			return http.StatusBadRequest
+		}
+		return w.Code
+	}
+	assert.Equal(t, http.StatusBadRequest, create(fmt.Sprintf(`{"activity_id": %d, "organizer_id": 1, "status": "open", "match_time": "%s", "approval_mode": "gated"}`, activityID, start)))
+	assert.Equal(t, http.StatusBadRequest, create(fmt.Sprintf(`{"activity_id": %d, "organizer_id": 1, "status": "open", "match_time": "%s", "approval_mode": "auto", "gate_min_completed_matches": 3}`, activityID, start)))
+	assert.Equal(t, http.StatusCreated, create(fmt.Sprintf(`{"activity_id": %d, "organizer_id": 1, "status": "open", "match_time": "%s", "approval_mode": "gated", "gate_min_completed_matches": 3}`, activityID, start)))
+}
@@ -0,0 +1,241 @@
+package routes
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"free2free/database"
+	"free2free/ical"
+	"free2free/models"
+	"free2free/utils"
+
+	apperrors "free2free/errors"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const calendarProdID = "-//free2free//Match Calendar//ZH-TW"
+
+// calendarFeedLookback 行事曆訂閱中保留的過去配對局天數，讓已結束的配對局不會立即從行事曆消失
+const calendarFeedLookback = 30 * 24 * time.Hour
+
+// calendarFeedLimit 行事曆訂閱最多包含的配對局數
+const calendarFeedLimit = 500
+
+// CalendarFeed 行事曆訂閱網址
+type CalendarFeed struct {
+	Token string `json:"token"`
+	URL   string `json:"url"`
+}
+
+// SetupCalendarRoutes 設定行事曆路由
+func SetupCalendarRoutes(r *gin.Engine) {
+	// 行事曆 app 無法帶入登入資訊，以網址中的私密 token 驗證
+	r.GET("/calendar/:token", calendarFeed)
+
+	user := r.Group("/user")
+	user.Use(UserAuthMiddleware())
+	{
+		user.POST("/calendar/token", rotateCalendarToken)
+		user.DELETE("/calendar/token", revokeCalendarToken)
+		user.GET("/matches/:id/ics", downloadMatchCalendar)
+	}
+}
+
+// rotateCalendarToken 產生行事曆訂閱網址
+// @Summary 產生行事曆訂閱網址
+// @Description 產生私密的 .ics 訂閱網址，包含自己開局、共同開局或已審核通過的配對局；重新產生後舊網址立即失效
+// @Tags 使用者
+// @Produce json
+// @Success 201 {object} CalendarFeed
+// @Failure 401 {object} map[string]string "未登入"
+// @Failure 500 {object} map[string]string "無法產生訂閱網址"
+// @Router /user/calendar/token [post]
+// @Security ApiKeyAuth
+func rotateCalendarToken(c *gin.Context) {
+	user, err := utils.GetAuthenticatedUser(c)
+	if err != nil {
+		c.Error(apperrors.NewUnauthorizedError("未登入"))
+		return
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		c.Error(apperrors.NewAppError(http.StatusInternalServerError, "無法產生訂閱網址"))
+		return
+	}
+	token := hex.EncodeToString(buf)
+
+	// 每位使用者只有一個訂閱網址，只保存 token 的雜湊值
+	record := models.CalendarToken{UserID: user.ID, TokenHash: hashCalendarToken(token), CreatedAt: time.Now()}
+	err = database.GlobalDB.Conn.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"token_hash", "created_at"}),
+	}).Create(&record).Error
+	if err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, CalendarFeed{
+		Token: token,
+		URL:   fmt.Sprintf("%s/calendar/%s.ics", os.Getenv("BASE_URL"), token),
+	})
+}
+
+// revokeCalendarToken 停用行事曆訂閱網址
+// @Summary 停用行事曆訂閱網址
+// @Description 停用目前的 .ics 訂閱網址
+// @Tags 使用者
+// @Produce json
+// @Success 200 {object} map[string]string "訂閱網址已停用"
+// @Failure 401 {object} map[string]string "未登入"
+// @Router /user/calendar/token [delete]
+// @Security ApiKeyAuth
+func revokeCalendarToken(c *gin.Context) {
+	user, err := utils.GetAuthenticatedUser(c)
+	if err != nil {
+		c.Error(apperrors.NewUnauthorizedError("未登入"))
+		return
+	}
+
+	if err := database.GlobalDB.Conn.Where("user_id = ?", user.ID).Delete(&models.CalendarToken{}).Error; err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "訂閱網址已停用"})
+}
+
+// calendarFeed 行事曆訂閱
+// @Summary 行事曆訂閱
+// @Description 以私密 token 取得自己開局、共同開局或已審核通過的配對局 (含 30 天內已結束的)；已取消的配對局以 STATUS:CANCELLED 呈現
+// @Tags 使用者
+// @Produce text/calendar
+// @Param token path string true "訂閱 token (可加上 .ics)"
+// @Success 200 {string} string "text/calendar"
+// @Failure 404 {object} map[string]string "訂閱網址無效"
+// @Router /calendar/{token} [get]
+func calendarFeed(c *gin.Context) {
+	token := strings.TrimSuffix(c.Param("token"), ".ics")
+
+	var record models.CalendarToken
+	if err := database.GlobalDB.Conn.Where("token_hash = ?", hashCalendarToken(token)).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Error(apperrors.NewAppError(http.StatusNotFound, "訂閱網址無效"))
+			return
+		}
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+
+	db := database.GlobalDB.Conn
+	approved := db.Model(&models.MatchParticipant{}).
+		Select("match_id").
+		Where("user_id = ? AND status IN ?", record.UserID, models.ApprovedParticipantStatuses)
+	coOrganized := db.Model(&models.MatchCoOrganizer{}).
+		Select("match_id").
+		Where("user_id = ?", record.UserID)
+
+	var matches []models.Match
+	err := db.Preload("Activity.Location").
+		Where("matches.organizer_id = ? OR matches.id IN (?) OR matches.id IN (?)", record.UserID, approved, coOrganized).
+		Where("matches.match_time >= ?", time.Now().Add(-calendarFeedLookback)).
+		Order("matches.match_time ASC").
+		Limit(calendarFeedLimit).
+		Find(&matches).Error
+	if err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+
+	cal := ical.Calendar{ProdID: calendarProdID, Name: "free2free 配對"}
+	for _, match := range matches {
+		cal.Events = append(cal.Events, matchEvent(match))
+	}
+	writeCalendar(c, &cal, "")
+}
+
+// downloadMatchCalendar 下載單一配對局的 .ics
+// @Summary 下載配對局 .ics
+// @Description 下載單一配對局的行事曆檔案，方便加入行事曆
+// @Tags 使用者
+// @Produce text/calendar
+// @Param id path int true "配對局ID"
+// @Success 200 {string} string "text/calendar"
+// @Failure 400 {object} map[string]string "無效的配對局 ID"
+// @Failure 404 {object} map[string]string "配對局不存在"
+// @Router /user/matches/{id}/ics [get]
+// @Security ApiKeyAuth
+func downloadMatchCalendar(c *gin.Context) {
+	matchID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || matchID <= 0 {
+		c.Error(apperrors.NewValidationError("無效的配對局 ID"))
+		return
+	}
+
+	var match models.Match
+	if err := database.GlobalDB.Conn.Preload("Activity.Location").First(&match, matchID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Error(apperrors.NewAppError(http.StatusNotFound, "配對局不存在"))
+			return
+		}
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+
+	cal := ical.Calendar{ProdID: calendarProdID, Events: []ical.Event{matchEvent(match)}}
+	writeCalendar(c, &cal, fmt.Sprintf("match-%d.ics", match.ID))
+}
+
+// matchEvent 將配對局轉為行事曆事件，未成局或已取消的配對局標記為 CANCELLED
+func matchEvent(match models.Match) ical.Event {
+	location := match.Activity.Location
+	event := ical.Event{
+		UID:         fmt.Sprintf("match-%d@free2free", match.ID),
+		Summary:     match.Activity.Title,
+		Description: match.Activity.Description,
+		Start:       match.MatchTime,
+		End:         match.EndTime,
+		Status:      ical.StatusConfirmed,
+	}
+	if location.ID != 0 {
+		event.Location = location.Name + ", " + location.Address
+		event.Latitude = location.Latitude
+		event.Longitude = location.Longitude
+		event.HasGeo = true
+	}
+	if match.Status == "cancelled" || match.Status == "expired" {
+		event.Status = ical.StatusCancelled
+	}
+	return event
+}
+
+// writeCalendar 輸出 text/calendar 回應，filename 不為空時以附件下載
+func writeCalendar(c *gin.Context, cal *ical.Calendar, filename string) {
+	var buf bytes.Buffer
+	if err := cal.Encode(&buf, time.Now()); err != nil {
+		c.Error(apperrors.NewAppError(http.StatusInternalServerError, "無法產生行事曆"))
+		return
+	}
+	if filename != "" {
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	}
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", buf.Bytes())
+}
+
+// hashCalendarToken 資料庫只保存訂閱 token 的 SHA-256
+func hashCalendarToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
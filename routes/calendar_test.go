@@ -0,0 +1,77 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"free2free/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCalendarFeed(t *testing.T) {
+	db := setupUserTestDatabase(t)
+
+	organizer := seedUser(t, db, "organizer")
+	member := seedUser(t, db, "member")
+	organized := seedMatch(t, db, organizer.ID)
+	joined := seedMatch(t, db, organizer.ID)
+	waiting := seedMatch(t, db, organizer.ID)
+	assert.NoError(t, db.Model(&joined).Update("status", "cancelled").Error)
+	assert.NoError(t, db.Create(&models.MatchParticipant{MatchID: joined.ID, UserID: member.ID, Status: "approved", JoinedAt: time.Now()}).Error)
+	assert.NoError(t, db.Create(&models.MatchParticipant{MatchID: waiting.ID, UserID: member.ID, Status: "pending", JoinedAt: time.Now()}).Error)
+
+	rotate := func() CalendarFeed {
+		c, w := newUserContext("POST", "/", nil, member.ID)
+		rotateCalendarToken(c)
+		assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var feed CalendarFeed
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &feed))
+		return feed
+	}
+	fetch := func(token string) (int, string) {
+		c, w := newUserContext("GET", "/", nil, 0)
+		c.Params = gin.Params{{Key: "token", Value: token + ".ics"}}
+		calendarFeed(c)
+		if len(c.Errors) > 0 {
+			return http.StatusNotFound, ""
+		}
+		return w.Code, w.Body.String()
+	}
+
+	old := rotate()
+	feed := rotate()
+	assert.True(t, strings.HasSuffix(feed.URL, "/calendar/"+feed.Token+".ics"))
+
+	// 重新產生後舊網址失效
+	code, _ := fetch(old.Token)
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, body := fetch(feed.Token)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, fmt.Sprintf("UID:match-%d@free2free", joined.ID))
+	assert.NotContains(t, body, fmt.Sprintf("UID:match-%d@free2free", organized.ID), "只包含開局或已審核通過的配對局")
+	assert.NotContains(t, body, fmt.Sprintf("UID:match-%d@free2free", waiting.ID))
+	assert.Contains(t, body, "STATUS:CANCELLED")
+	assert.Contains(t, body, "GEO:25.030000;121.560000")
+	assert.Contains(t, body, `LOCATION:全家便利商店\, 台北市信義區`)
+
+	// 共同開局的配對局也會出現在訂閱中
+	assert.NoError(t, db.Create(&models.MatchCoOrganizer{MatchID: organized.ID, UserID: member.ID, AddedBy: organizer.ID}).Error)
+	_, body = fetch(feed.Token)
+	assert.Contains(t, body, fmt.Sprintf("UID:match-%d@free2free", organized.ID))
+
+	// 單一配對局下載
+	c, w := newUserContext("GET", "/", nil, organizer.ID)
+	c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(organized.ID)}}
+	downloadMatchCalendar(c)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, fmt.Sprintf(`attachment; filename="match-%d.ics"`, organized.ID), w.Header().Get("Content-Disposition"))
+	assert.Contains(t, w.Body.String(), "SUMMARY:咖啡買一送一")
+	assert.Contains(t, w.Body.String(), "STATUS:CONFIRMED")
+}
@@ -0,0 +1,508 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"free2free/database"
+	"free2free/models"
+	"free2free/utils"
+
+	apperrors "free2free/errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+const (
+	// messageEditWindow 訊息送出後可以修改或刪除的時間
+	messageEditWindow = 15 * time.Minute
+	// chatReadOnlyAfter 配對局結束後仍可發送訊息的時間，之後對話串變為唯讀
+	chatReadOnlyAfter = 24 * time.Hour
+)
+
+// ErrCodeChatReadOnly 對話串已變為唯讀時回傳的錯誤代碼
+const ErrCodeChatReadOnly = "chat_read_only"
+
+// MessageRequest 發送或修改訊息的請求
+type MessageRequest struct {
+	Body string `json:"body" validate:"required,min=1,max=1000"`
+}
+
+// ReadMarkerRequest 更新已讀位置的請求，省略 last_read_message_id 時標記到最新一則
+type ReadMarkerRequest struct {
+	LastReadMessageID int64 `json:"last_read_message_id" validate:"omitempty,min=1"`
+}
+
+// ReadState 使用者在對話串中的已讀狀態
+type ReadState struct {
+	LastReadMessageID int64 `json:"last_read_message_id"`
+	UnreadCount       int64 `json:"unread_count"`
+}
+
+// ReportRequest 檢舉訊息的請求
+type ReportRequest struct {
+	Reason string `json:"reason" validate:"required,min=1,max=500"`
+}
+
+// messageListSpec 訊息列表以 ID 排序，預設由新到舊
+var messageListSpec = ListSpec[models.MatchMessage]{
+	IDColumn: "match_messages.id",
+	ID:       func(m models.MatchMessage) int64 { return m.ID },
+	Sorts: map[string]SortField[models.MatchMessage]{
+		"id": {Column: "match_messages.id", Kind: sortInt, Value: func(m models.MatchMessage) interface{} { return m.ID }},
+	},
+	DefaultSort: "-id",
+	Preloads:    []string{"Sender"},
+}
+
+// SetupChatRoutes 設定配對局對話串路由
+func SetupChatRoutes(r *gin.Engine) {
+	chat := r.Group("/user/matches/:id/messages")
+	chat.Use(UserAuthMiddleware())
+	{
+		chat.GET("", listMessages)
+		chat.POST("", createMessage)
+
+		// 已讀位置
+		chat.GET("/read", getReadState)
+		chat.POST("/read", markRead)
+
+		chat.PUT("/:message_id", updateMessage)
+		chat.DELETE("/:message_id", deleteMessage)
+		chat.POST("/:message_id/report", reportMessage)
+	}
+}
+
+// listMessages 取得配對局對話串
+// @Summary 取得對話串訊息
+// @Description 取得配對局的訊息，只有開局者、共同開局者與已審核通過的參與者可以查看；已被拒絕或移除的參與者的訊息不會顯示
+// @Tags 使用者
+// @Produce json
+// @Param id path int true "配對局ID"
+// @Param sort query string false "排序欄位 (id)，前綴 - 表示遞減，預設 -id"
+// @Param limit query int false "每頁筆數 (1-100)，預設 20"
+// @Param cursor query string false "上一頁回傳的 X-Next-Cursor"
+// @Success 200 {array} MatchMessage
+// @Header 200 {integer} X-Total-Count "訊息總數"
+// @Header 200 {string} X-Next-Cursor "下一頁游標，沒有下一頁時為空"
+// @Failure 403 {object} map[string]string "只有開局者、共同開局者與已審核通過的參與者可以使用對話串"
+// @Router /user/matches/{id}/messages [get]
+// @Security ApiKeyAuth
+func listMessages(c *gin.Context) {
+	match, _, ok := loadChatMatch(c)
+	if !ok {
+		return
+	}
+
+	messages, err := Paginate(c, visibleMessages(database.GlobalDB.Conn, match), messageListSpec)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, messages)
+}
+
+// createMessage 發送訊息
+// @Summary 發送訊息
+// @Description 在配對局對話串發送訊息；配對局結束 24 小時後對話串變為唯讀
+// @Tags 使用者
+// @Accept json
+// @Produce json
+// @Param id path int true "配對局ID"
+// @Param message body MessageRequest true "訊息內容"
+// @Success 201 {object} MatchMessage
+// @Failure 400 {object} map[string]string "無效的請求資料"
+// @Failure 403 {object} map[string]string "無權使用對話串或對話串已唯讀 (error_code: chat_read_only)"
+// @Router /user/matches/{id}/messages [post]
+// @Security ApiKeyAuth
+func createMessage(c *gin.Context) {
+	match, user, ok := loadChatMatch(c)
+	if !ok {
+		return
+	}
+	if err := chatWritable(match); err != nil {
+		c.Error(err)
+		return
+	}
+
+	var req MessageRequest
+	if !bindMessageRequest(c, &req) {
+		return
+	}
+
+	message := models.MatchMessage{
+		MatchID:  match.ID,
+		SenderID: user.ID,
+		Body:     req.Body,
+	}
+	if err := database.GlobalDB.Conn.Create(&message).Error; err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+
+	database.GlobalDB.Conn.Preload("Sender").First(&message, message.ID)
+	c.JSON(http.StatusCreated, message)
+}
+
+// updateMessage 修改訊息
+// @Summary 修改訊息
+// @Description 修改自己發送的訊息，限送出後 15 分鐘內
+// @Tags 使用者
+// @Accept json
+// @Produce json
+// @Param id path int true "配對局ID"
+// @Param message_id path int true "訊息ID"
+// @Param message body MessageRequest true "訊息內容"
+// @Success 200 {object} MatchMessage
+// @Failure 400 {object} map[string]string "無效的請求資料"
+// @Failure 403 {object} map[string]string "只能修改自己的訊息或已超過可修改時間"
+// @Failure 404 {object} map[string]string "訊息不存在"
+// @Router /user/matches/{id}/messages/{message_id} [put]
+// @Security ApiKeyAuth
+func updateMessage(c *gin.Context) {
+	match, user, ok := loadChatMatch(c)
+	if !ok {
+		return
+	}
+	message, ok := loadOwnEditableMessage(c, match, user)
+	if !ok {
+		return
+	}
+
+	var req MessageRequest
+	if !bindMessageRequest(c, &req) {
+		return
+	}
+
+	now := time.Now()
+	if err := database.GlobalDB.Conn.Model(message).Updates(map[string]interface{}{"body": req.Body, "edited_at": now}).Error; err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+
+	database.GlobalDB.Conn.Preload("Sender").First(message, message.ID)
+	c.JSON(http.StatusOK, message)
+}
+
+// deleteMessage 刪除訊息
+// @Summary 刪除訊息
+// @Description 刪除自己發送的訊息，限送出後 15 分鐘內
+// @Tags 使用者
+// @Produce json
+// @Param id path int true "配對局ID"
+// @Param message_id path int true "訊息ID"
+// @Success 200 {object} map[string]string "訊息已刪除"
+// @Failure 403 {object} map[string]string "只能刪除自己的訊息或已超過可刪除時間"
+// @Failure 404 {object} map[string]string "訊息不存在"
+// @Router /user/matches/{id}/messages/{message_id} [delete]
+// @Security ApiKeyAuth
+func deleteMessage(c *gin.Context) {
+	match, user, ok := loadChatMatch(c)
+	if !ok {
+		return
+	}
+	message, ok := loadOwnEditableMessage(c, match, user)
+	if !ok {
+		return
+	}
+
+	// 保留訊息內容供檢舉審查，只標記為已刪除
+	if err := database.GlobalDB.Conn.Model(message).Update("deleted_at", time.Now()).Error; err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "訊息已刪除"})
+}
+
+// getReadState 取得已讀狀態
+// @Summary 取得已讀狀態
+// @Description 取得自己在對話串中的已讀位置與未讀訊息數
+// @Tags 使用者
+// @Produce json
+// @Param id path int true "配對局ID"
+// @Success 200 {object} ReadState
+// @Failure 403 {object} map[string]string "只有開局者、共同開局者與已審核通過的參與者可以使用對話串"
+// @Router /user/matches/{id}/messages/read [get]
+// @Security ApiKeyAuth
+func getReadState(c *gin.Context) {
+	match, user, ok := loadChatMatch(c)
+	if !ok {
+		return
+	}
+
+	var marker models.MatchMessageRead
+	err := database.GlobalDB.Conn.Where("match_id = ? AND user_id = ?", match.ID, user.ID).First(&marker).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+
+	state, err := readState(match, user.ID, marker.LastReadMessageID)
+	if err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+	c.JSON(http.StatusOK, state)
+}
+
+// markRead 更新已讀位置
+// @Summary 更新已讀位置
+// @Description 將已讀位置移到指定訊息，省略時標記到最新一則；已讀位置只會往後移動
+// @Tags 使用者
+// @Accept json
+// @Produce json
+// @Param id path int true "配對局ID"
+// @Param marker body ReadMarkerRequest false "已讀位置"
+// @Success 200 {object} ReadState
+// @Failure 400 {object} map[string]string "無效的請求資料"
+// @Failure 403 {object} map[string]string "只有開局者、共同開局者與已審核通過的參與者可以使用對話串"
+// @Router /user/matches/{id}/messages/read [post]
+// @Security ApiKeyAuth
+func markRead(c *gin.Context) {
+	match, user, ok := loadChatMatch(c)
+	if !ok {
+		return
+	}
+
+	var req ReadMarkerRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.Error(apperrors.NewValidationError("無效的請求資料"))
+			return
+		}
+	}
+	v := validator.New()
+	if err := v.Struct(&req); err != nil {
+		c.Error(apperrors.NewValidationError(err.Error()))
+		return
+	}
+
+	db := database.GlobalDB.Conn
+	lastID := req.LastReadMessageID
+	if lastID == 0 {
+		if err := db.Model(&models.MatchMessage{}).Where("match_id = ?", match.ID).Select("COALESCE(MAX(id), 0)").Scan(&lastID).Error; err != nil {
+			c.Error(apperrors.MapGORMError(err))
+			return
+		}
+	} else {
+		var count int64
+		if err := db.Model(&models.MatchMessage{}).Where("id = ? AND match_id = ?", lastID, match.ID).Count(&count).Error; err != nil {
+			c.Error(apperrors.MapGORMError(err))
+			return
+		}
+		if count == 0 {
+			c.Error(apperrors.NewValidationError("指定的訊息不存在或不屬於此配對局"))
+			return
+		}
+	}
+
+	var marker models.MatchMessageRead
+	err := db.Where(models.MatchMessageRead{MatchID: match.ID, UserID: user.ID}).FirstOrCreate(&marker).Error
+	if err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+	// 已讀位置只往後移動，避免較舊的請求覆蓋較新的位置
+	if err := db.Model(&marker).Where("last_read_message_id < ?", lastID).Update("last_read_message_id", lastID).Error; err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+	if lastID > marker.LastReadMessageID {
+		marker.LastReadMessageID = lastID
+	}
+
+	state, err := readState(match, user.ID, marker.LastReadMessageID)
+	if err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+	c.JSON(http.StatusOK, state)
+}
+
+// reportMessage 檢舉訊息
+// @Summary 檢舉訊息
+// @Description 檢舉對話串中的單則訊息，每位使用者對同一則訊息只能檢舉一次
+// @Tags 使用者
+// @Accept json
+// @Produce json
+// @Param id path int true "配對局ID"
+// @Param message_id path int true "訊息ID"
+// @Param report body ReportRequest true "檢舉原因"
+// @Success 201 {object} MessageReport
+// @Failure 400 {object} map[string]string "無效的請求資料或不能檢舉自己的訊息"
+// @Failure 404 {object} map[string]string "訊息不存在"
+// @Failure 409 {object} map[string]string "已檢舉過此訊息"
+// @Router /user/matches/{id}/messages/{message_id}/report [post]
+// @Security ApiKeyAuth
+func reportMessage(c *gin.Context) {
+	match, user, ok := loadChatMatch(c)
+	if !ok {
+		return
+	}
+	message, ok := loadVisibleMessage(c, match)
+	if !ok {
+		return
+	}
+	if message.SenderID == user.ID {
+		c.Error(apperrors.NewValidationError("不能檢舉自己的訊息"))
+		return
+	}
+
+	var req ReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.NewValidationError("無效的請求資料"))
+		return
+	}
+	v := validator.New()
+	if err := v.Struct(&req); err != nil {
+		c.Error(apperrors.NewValidationError(err.Error()))
+		return
+	}
+
+	report := models.MessageReport{
+		MessageID:  message.ID,
+		ReporterID: user.ID,
+		Reason:     req.Reason,
+	}
+	if err := database.GlobalDB.Conn.Create(&report).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			c.Error(apperrors.NewAppError(http.StatusConflict, "您已經檢舉過此訊息"))
+			return
+		}
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+	c.JSON(http.StatusCreated, report)
+}
+
+// loadChatMatch 讀取路徑中的配對局，並確認當前使用者為開局者、共同開局者或已審核通過的參與者
+func loadChatMatch(c *gin.Context) (*models.Match, *models.User, bool) {
+	matchID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || matchID <= 0 {
+		c.Error(apperrors.NewValidationError("無效的配對局 ID"))
+		return nil, nil, false
+	}
+
+	user, err := utils.GetAuthenticatedUser(c)
+	if err != nil {
+		c.Error(apperrors.NewUnauthorizedError("未登入"))
+		return nil, nil, false
+	}
+
+	db := database.GlobalDB.Conn
+	var match models.Match
+	if err := db.First(&match, matchID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Error(apperrors.NewAppError(http.StatusNotFound, "配對局不存在"))
+			return nil, nil, false
+		}
+		c.Error(apperrors.MapGORMError(err))
+		return nil, nil, false
+	}
+
+	member, err := isMatchMember(db, &match, user.ID)
+	if err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return nil, nil, false
+	}
+	if !member {
+		c.Error(apperrors.NewForbiddenError("只有開局者、共同開局者與已審核通過的參與者可以使用對話串"))
+		return nil, nil, false
+	}
+	return &match, user, true
+}
+
+// visibleMessages 對話串中可以顯示的訊息：未刪除，且發送者仍為開局者、共同開局者或已審核通過的參與者
+func visibleMessages(db *gorm.DB, match *models.Match) *gorm.DB {
+	approved := db.Model(&models.MatchParticipant{}).
+		Select("user_id").
+		Where("match_id = ? AND status IN ?", match.ID, models.ApprovedParticipantStatuses)
+	coOrganizers := db.Model(&models.MatchCoOrganizer{}).
+		Select("user_id").
+		Where("match_id = ?", match.ID)
+	return db.Model(&models.MatchMessage{}).
+		Where("match_messages.match_id = ? AND match_messages.deleted_at IS NULL", match.ID).
+		Where("match_messages.sender_id = ? OR match_messages.sender_id IN (?) OR match_messages.sender_id IN (?)", match.OrganizerID, approved, coOrganizers)
+}
+
+// loadVisibleMessage 讀取路徑中屬於此配對局且可以顯示的訊息
+func loadVisibleMessage(c *gin.Context, match *models.Match) (*models.MatchMessage, bool) {
+	messageID, err := strconv.ParseInt(c.Param("message_id"), 10, 64)
+	if err != nil || messageID <= 0 {
+		c.Error(apperrors.NewValidationError("無效的訊息 ID"))
+		return nil, false
+	}
+
+	var message models.MatchMessage
+	if err := visibleMessages(database.GlobalDB.Conn, match).Where("match_messages.id = ?", messageID).First(&message).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Error(apperrors.NewAppError(http.StatusNotFound, "訊息不存在"))
+			return nil, false
+		}
+		c.Error(apperrors.MapGORMError(err))
+		return nil, false
+	}
+	return &message, true
+}
+
+// loadOwnEditableMessage 讀取自己發送且仍在可修改時間內的訊息
+func loadOwnEditableMessage(c *gin.Context, match *models.Match, user *models.User) (*models.MatchMessage, bool) {
+	message, ok := loadVisibleMessage(c, match)
+	if !ok {
+		return nil, false
+	}
+	if message.SenderID != user.ID {
+		c.Error(apperrors.NewForbiddenError("只能修改或刪除自己的訊息"))
+		return nil, false
+	}
+	if time.Since(message.CreatedAt) > messageEditWindow {
+		c.Error(apperrors.NewForbiddenError("訊息已超過可修改或刪除的時間"))
+		return nil, false
+	}
+	if err := chatWritable(match); err != nil {
+		c.Error(err)
+		return nil, false
+	}
+	return message, true
+}
+
+// chatWritable 配對局已取消或未成局時對話串為唯讀，結束一段時間後也變為唯讀
+func chatWritable(match *models.Match) error {
+	if match.Status == "cancelled" || match.Status == "expired" {
+		return apperrors.NewCodedError(http.StatusForbidden, ErrCodeChatReadOnly, "配對局已取消或未成局，對話串為唯讀")
+	}
+	if time.Now().After(match.EndTime.Add(chatReadOnlyAfter)) {
+		return apperrors.NewCodedError(http.StatusForbidden, ErrCodeChatReadOnly, "配對局已結束，對話串為唯讀")
+	}
+	return nil
+}
+
+// readState 計算已讀位置之後、不是自己發送的可見訊息數
+func readState(match *models.Match, userID, lastReadID int64) (*ReadState, error) {
+	var unread int64
+	err := visibleMessages(database.GlobalDB.Conn, match).
+		Where("match_messages.id > ? AND match_messages.sender_id <> ?", lastReadID, userID).
+		Count(&unread).Error
+	if err != nil {
+		return nil, err
+	}
+	return &ReadState{LastReadMessageID: lastReadID, UnreadCount: unread}, nil
+}
+
+// bindMessageRequest 解析並驗證訊息內容
+func bindMessageRequest(c *gin.Context, req *MessageRequest) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.Error(apperrors.NewValidationError("無效的請求資料"))
+		return false
+	}
+	v := validator.New()
+	if err := v.Struct(req); err != nil {
+		c.Error(apperrors.NewValidationError(err.Error()))
+		return false
+	}
+	return true
+}
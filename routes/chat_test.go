@@ -0,0 +1,166 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"free2free/models"
+
+	apperrors "free2free/errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchChat(t *testing.T) {
+	db := setupUserTestDatabase(t)
+
+	organizer := seedUser(t, db, "organizer")
+	approved := seedUser(t, db, "approved")
+	removed := seedUser(t, db, "removed")
+	pending := seedUser(t, db, "pending")
+	match := seedMatch(t, db, organizer.ID)
+	matchParam := gin.Param{Key: "id", Value: fmt.Sprint(match.ID)}
+
+	assert.NoError(t, db.Create(&models.MatchParticipant{MatchID: match.ID, UserID: approved.ID, Status: "approved", JoinedAt: time.Now()}).Error)
+	removedParticipant := models.MatchParticipant{MatchID: match.ID, UserID: removed.ID, Status: "approved", JoinedAt: time.Now()}
+	assert.NoError(t, db.Create(&removedParticipant).Error)
+	assert.NoError(t, db.Create(&models.MatchParticipant{MatchID: match.ID, UserID: pending.ID, Status: "pending", JoinedAt: time.Now()}).Error)
+
+	send := func(userID int64, body string) (*apperrors.AppError, models.MatchMessage) {
+		payload, _ := json.Marshal(MessageRequest{Body: body})
+		c, w := newUserContext("POST", "/", payload, userID)
+		c.Params = gin.Params{matchParam}
+		createMessage(c)
+		var message models.MatchMessage
+		if len(c.Errors) > 0 {
+			appErr, _ := c.Errors.Last().Err.(*apperrors.AppError)
+			return appErr, message
+		}
+		json.Unmarshal(w.Body.Bytes(), &message)
+		return nil, message
+	}
+	list := func(userID int64) []models.MatchMessage {
+		c, w := newUserContext("GET", "/", nil, userID)
+		c.Params = gin.Params{matchParam}
+		listMessages(c)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var messages []models.MatchMessage
+		json.Unmarshal(w.Body.Bytes(), &messages)
+		return messages
+	}
+
+	appErr, first := send(organizer.ID, "我在櫃台，藍色外套")
+	assert.Nil(t, appErr)
+	appErr, _ = send(approved.ID, "馬上到")
+	assert.Nil(t, appErr)
+	appErr, _ = send(removed.ID, "我也快到了")
+	assert.Nil(t, appErr)
+
+	// 等待審核中的使用者無法使用對話串
+	appErr, _ = send(pending.ID, "可以加入嗎")
+	if assert.NotNil(t, appErr) {
+		assert.Equal(t, http.StatusForbidden, appErr.Code)
+	}
+
+	assert.Len(t, list(approved.ID), 3)
+
+	// 被拒絕的參與者的訊息不再顯示
+	assert.NoError(t, db.Model(&removedParticipant).Update("status", "rejected").Error)
+	messages := list(approved.ID)
+	if assert.Len(t, messages, 2) {
+		assert.Equal(t, "馬上到", messages[0].Body)
+	}
+
+	// 已讀位置與未讀數
+	c, w := newUserContext("POST", "/", []byte(fmt.Sprintf(`{"last_read_message_id": %d}`, first.ID)), approved.ID)
+	c.Params = gin.Params{matchParam}
+	markRead(c)
+	var state ReadState
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &state))
+	assert.Equal(t, ReadState{LastReadMessageID: first.ID, UnreadCount: 0}, state)
+
+	c, w = newUserContext("POST", "/", nil, organizer.ID)
+	c.Params = gin.Params{matchParam}
+	markRead(c)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &state))
+	assert.Equal(t, int64(0), state.UnreadCount)
+
+	// 開局者讀到最新一則後，另一位參與者再發送一則
+	send(approved.ID, "到了")
+	c, w = newUserContext("GET", "/", nil, organizer.ID)
+	c.Params = gin.Params{matchParam}
+	getReadState(c)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &state))
+	assert.Equal(t, int64(1), state.UnreadCount)
+
+	// 超過可修改時間的訊息無法修改
+	assert.NoError(t, db.Model(&first).Update("created_at", time.Now().Add(-time.Hour)).Error)
+	c, _ = newUserContext("PUT", "/", []byte(`{"body": "改一下"}`), organizer.ID)
+	c.Params = gin.Params{matchParam, {Key: "message_id", Value: fmt.Sprint(first.ID)}}
+	updateMessage(c)
+	if assert.Len(t, c.Errors, 1) {
+		assert.Equal(t, http.StatusForbidden, c.Errors.Last().Err.(*apperrors.AppError).Code)
+	}
+
+	// 檢舉訊息，重複檢舉回傳 409
+	for _, expected := range []int{http.StatusCreated, http.StatusConflict} {
+		c, w = newUserContext("POST", "/", []byte(`{"reason": "騷擾"}`), approved.ID)
+		c.Params = gin.Params{matchParam, {Key: "message_id", Value: fmt.Sprint(first.ID)}}
+		reportMessage(c)
+		if len(c.Errors) > 0 {
+			assert.Equal(t, expected, c.Errors.Last().Err.(*apperrors.AppError).Code)
+		} else {
+			assert.Equal(t, expected, w.Code)
+		}
+	}
+
+	// 已取消的配對局對話串為唯讀，仍可讀取
+	assert.NoError(t, db.Model(&match).Update("status", "cancelled").Error)
+	appErr, _ = send(approved.ID, "還有人要去嗎")
+	if assert.NotNil(t, appErr) {
+		assert.Equal(t, ErrCodeChatReadOnly, appErr.ErrorCode)
+	}
+	assert.Len(t, list(approved.ID), 3)
+
+	// 配對局結束一天後對話串變為唯讀
+	assert.NoError(t, db.Model(&match).Updates(map[string]interface{}{
+		"match_time": time.Now().Add(-50 * time.Hour),
+		"end_time":   time.Now().Add(-48 * time.Hour),
+		"status":     "completed",
+	}).Error)
+	appErr, _ = send(organizer.ID, "謝謝大家")
+	if assert.NotNil(t, appErr) {
+		assert.Equal(t, ErrCodeChatReadOnly, appErr.ErrorCode)
+	}
+	assert.Len(t, list(organizer.ID), 3)
+}
+
+func TestMatchChatCoOrganizer(t *testing.T) {
+	db := setupUserTestDatabase(t)
+
+	organizer := seedUser(t, db, "organizer")
+	coOrganizer := seedUser(t, db, "co-organizer")
+	match := seedMatch(t, db, organizer.ID)
+	matchParam := gin.Param{Key: "id", Value: fmt.Sprint(match.ID)}
+	assert.NoError(t, db.Create(&models.MatchCoOrganizer{MatchID: match.ID, UserID: coOrganizer.ID, AddedBy: organizer.ID}).Error)
+
+	// 共同開局者不是參與者也可以使用對話串，訊息對其他人可見
+	c, w := newUserContext("POST", "/", []byte(`{"body": "我負責帶大家進場"}`), coOrganizer.ID)
+	c.Params = gin.Params{matchParam}
+	createMessage(c)
+	assert.Empty(t, c.Errors)
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	c, w = newUserContext("GET", "/", nil, organizer.ID)
+	c.Params = gin.Params{matchParam}
+	listMessages(c)
+	var messages []models.MatchMessage
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &messages))
+	if assert.Len(t, messages, 1) {
+		assert.Equal(t, coOrganizer.ID, messages[0].SenderID)
+	}
+}
@@ -0,0 +1,245 @@
+package routes
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"free2free/database"
+	"free2free/geo"
+	"free2free/models"
+	"free2free/utils"
+
+	apperrors "free2free/errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+const (
+	// checkInOpensBefore 配對開始前多久開放報到，報到在配對結束時截止
+	checkInOpensBefore = 30 * time.Minute
+	// checkInCodePeriod 報到碼輪替的間隔，前一組報到碼仍可使用以容許時間差
+	checkInCodePeriod = 30 * time.Second
+	// maxCheckInAttempts 每位參與者最多可輸入錯誤報到碼的次數
+	maxCheckInAttempts = 10
+)
+
+// 報到失敗時回傳的錯誤代碼
+const (
+	ErrCodeCheckInClosed       = "check_in_closed"
+	ErrCodeCheckInInvalidCode  = "check_in_invalid_code"
+	ErrCodeCheckInTooFar       = "check_in_too_far"
+	ErrCodeCheckInLocked       = "check_in_locked"
+	ErrCodeCheckInLocationNeed = "check_in_location_required"
+)
+
+// CheckInCode 開局者在現場出示的報到碼
+type CheckInCode struct {
+	Code        string    `json:"code"`
+	QRPayload   string    `json:"qr_payload"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	WindowStart time.Time `json:"window_start"`
+	WindowEnd   time.Time `json:"window_end"`
+}
+
+// CheckInRequest 參與者報到的請求，活動設定報到半徑時需附上目前位置
+type CheckInRequest struct {
+	Code      string   `json:"code" validate:"required,len=6,numeric"`
+	Latitude  *float64 `json:"latitude" validate:"omitempty,min=-90,max=90"`
+	Longitude *float64 `json:"longitude" validate:"omitempty,min=-180,max=180"`
+}
+
+// getCheckInCode 取得報到碼
+// @Summary 取得報到碼
+// @Description 開局者在報到時間內 (配對開始前 30 分鐘至配對結束) 取得每 30 秒輪替的報到碼與 QR code 內容
+// @Tags 開局者
+// @Produce json
+// @Param id path int true "配對局ID"
+// @Success 200 {object} CheckInCode
+// @Failure 400 {object} map[string]string "無效的配對局 ID 或不在報到時間內 (error_code: check_in_closed)"
+// @Failure 403 {object} map[string]string "需要開局者權限"
+// @Router /organizer/matches/{id}/checkin-code [get]
+// @Security ApiKeyAuth
+func getCheckInCode(c *gin.Context) {
+	match, ok := loadCheckInMatch(c)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	start, end := checkInWindow(match)
+	if now.Before(start) || now.After(end) {
+		c.Error(apperrors.NewCodedError(http.StatusBadRequest, ErrCodeCheckInClosed, "不在報到時間內"))
+		return
+	}
+
+	step := now.Unix() / int64(checkInCodePeriod.Seconds())
+	code := checkInCode(match.ID, step)
+	c.JSON(http.StatusOK, CheckInCode{
+		Code:        code,
+		QRPayload:   fmt.Sprintf("free2free://checkin?match_id=%d&code=%s", match.ID, code),
+		ExpiresAt:   time.Unix((step+1)*int64(checkInCodePeriod.Seconds()), 0),
+		WindowStart: start,
+		WindowEnd:   end,
+	})
+}
+
+// checkIn 參與者報到
+// @Summary 報到
+// @Description 已審核通過的參與者在報到時間內輸入開局者出示的報到碼；活動設定報到半徑時，回報的位置需在地點的半徑內。完成報到才能評分
+// @Tags 使用者
+// @Accept json
+// @Produce json
+// @Param id path int true "配對局ID"
+// @Param checkin body CheckInRequest true "報到碼與目前位置"
+// @Success 200 {object} MatchParticipant
+// @Failure 400 {object} map[string]string "無效的請求資料、不在報到時間內、報到碼錯誤或距離太遠"
+// @Failure 403 {object} map[string]string "只有已審核通過的參與者可以報到"
+// @Failure 429 {object} map[string]string "報到碼錯誤次數過多 (error_code: check_in_locked)"
+// @Router /user/matches/{id}/checkin [post]
+// @Security ApiKeyAuth
+func checkIn(c *gin.Context) {
+	match, ok := loadCheckInMatch(c)
+	if !ok {
+		return
+	}
+
+	var req CheckInRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.NewValidationError("無效的請求資料"))
+		return
+	}
+	v := validator.New()
+	if err := v.Struct(&req); err != nil {
+		c.Error(apperrors.NewValidationError(err.Error()))
+		return
+	}
+
+	user, err := utils.GetAuthenticatedUser(c)
+	if err != nil {
+		c.Error(apperrors.NewUnauthorizedError("未登入"))
+		return
+	}
+
+	db := database.GlobalDB.Conn
+	var participant models.MatchParticipant
+	if err := db.Where("match_id = ? AND user_id = ? AND status = ?", match.ID, user.ID, "approved").First(&participant).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Error(apperrors.NewForbiddenError("只有已審核通過的參與者可以報到"))
+			return
+		}
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+	if participant.CheckedInAt != nil {
+		c.JSON(http.StatusOK, participant)
+		return
+	}
+
+	now := time.Now()
+	start, end := checkInWindow(match)
+	if now.Before(start) || now.After(end) {
+		c.Error(apperrors.NewCodedError(http.StatusBadRequest, ErrCodeCheckInClosed, "不在報到時間內"))
+		return
+	}
+	// 驗證前先以條件式遞增預扣一次嘗試，同時送出的請求不會一起超過次數上限
+	result := db.Model(&models.MatchParticipant{}).
+		Where("id = ? AND check_in_attempts < ?", participant.ID, maxCheckInAttempts).
+		Update("check_in_attempts", gorm.Expr("check_in_attempts + 1"))
+	if result.Error != nil {
+		c.Error(apperrors.MapGORMError(result.Error))
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.Error(apperrors.NewCodedError(http.StatusTooManyRequests, ErrCodeCheckInLocked, "報到碼錯誤次數過多，請聯絡開局者"))
+		return
+	}
+
+	if !validCheckInCode(match.ID, req.Code, now) {
+		c.Error(apperrors.NewCodedError(http.StatusBadRequest, ErrCodeCheckInInvalidCode, "報到碼錯誤或已過期"))
+		return
+	}
+	// 報到碼正確時退回預扣的次數，只有輸入錯誤的報到碼才計入
+	if err := db.Model(&models.MatchParticipant{}).Where("id = ?", participant.ID).Update("check_in_attempts", gorm.Expr("check_in_attempts - 1")).Error; err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+
+	if radius := match.Activity.CheckInRadiusMeters; radius > 0 {
+		if req.Latitude == nil || req.Longitude == nil {
+			c.Error(apperrors.NewCodedError(http.StatusBadRequest, ErrCodeCheckInLocationNeed, "此活動報到時需要提供目前位置"))
+			return
+		}
+		location := match.Activity.Location
+		distanceM := geo.Haversine(*req.Latitude, *req.Longitude, location.Latitude, location.Longitude) * 1000
+		if distanceM > float64(radius) {
+			c.Error(apperrors.NewCodedError(http.StatusBadRequest, ErrCodeCheckInTooFar, fmt.Sprintf("距離地點 %.0f 公尺，需在 %d 公尺內才能報到", distanceM, radius)))
+			return
+		}
+	}
+
+	if err := db.Model(&participant).Update("checked_in_at", now).Error; err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+	participant.CheckedInAt = &now
+	c.JSON(http.StatusOK, participant)
+}
+
+// loadCheckInMatch 讀取路徑中的配對局與活動地點，只有 open 或 completed 的配對局可以報到
+func loadCheckInMatch(c *gin.Context) (*models.Match, bool) {
+	matchID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || matchID <= 0 {
+		c.Error(apperrors.NewValidationError("無效的配對局 ID"))
+		return nil, false
+	}
+
+	var match models.Match
+	err = database.GlobalDB.Conn.Preload("Activity.Location").
+		Where("id = ? AND status IN ?", matchID, []string{"open", "completed"}).
+		First(&match).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Error(apperrors.NewValidationError("指定的配對局不存在或已取消"))
+			return nil, false
+		}
+		c.Error(apperrors.MapGORMError(err))
+		return nil, false
+	}
+	return &match, true
+}
+
+// checkInWindow 回傳配對局的報到時間範圍
+func checkInWindow(match *models.Match) (time.Time, time.Time) {
+	return match.MatchTime.Add(-checkInOpensBefore), match.EndTime
+}
+
+// validCheckInCode 檢查報到碼是否為目前或前一個時段的報到碼
+func validCheckInCode(matchID int64, code string, now time.Time) bool {
+	step := now.Unix() / int64(checkInCodePeriod.Seconds())
+	for _, s := range []int64{step, step - 1} {
+		if hmac.Equal([]byte(code), []byte(checkInCode(matchID, s))) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkInCode 以 JWT_SECRET 產生配對局在指定時段的 6 位數報到碼 (與 TOTP 相同的截斷方式)
+func checkInCode(matchID, step int64) string {
+	mac := hmac.New(sha256.New, []byte(os.Getenv("JWT_SECRET")))
+	mac.Write([]byte(fmt.Sprintf("match-checkin:%d:%d", matchID, step)))
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%06d", value%1000000)
+}
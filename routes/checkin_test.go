@@ -0,0 +1,128 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"free2free/models"
+
+	apperrors "free2free/errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckIn(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-key-for-check-in-codes")
+	db := setupUserTestDatabase(t)
+
+	organizer := seedUser(t, db, "organizer")
+	guest := seedUser(t, db, "guest")
+	remote := seedUser(t, db, "remote")
+	outsider := seedUser(t, db, "outsider")
+	match := seedMatch(t, db, organizer.ID)
+	matchParam := gin.Param{Key: "id", Value: fmt.Sprint(match.ID)}
+
+	for _, user := range []models.User{guest, remote} {
+		assert.NoError(t, db.Create(&models.MatchParticipant{MatchID: match.ID, UserID: user.ID, Status: "approved", JoinedAt: time.Now()}).Error)
+	}
+
+	getCode := func() (*apperrors.AppError, CheckInCode) {
+		c, w := newUserContext("GET", "/", nil, organizer.ID)
+		c.Params = gin.Params{matchParam}
+		getCheckInCode(c)
+		var code CheckInCode
+		if len(c.Errors) > 0 {
+			appErr, _ := c.Errors.Last().Err.(*apperrors.AppError)
+			return appErr, code
+		}
+		json.Unmarshal(w.Body.Bytes(), &code)
+		return nil, code
+	}
+	checkInAs := func(userID int64, body string) (*apperrors.AppError, models.MatchParticipant) {
+		c, w := newUserContext("POST", "/", []byte(body), userID)
+		c.Params = gin.Params{matchParam}
+		checkIn(c)
+		var participant models.MatchParticipant
+		if len(c.Errors) > 0 {
+			appErr, _ := c.Errors.Last().Err.(*apperrors.AppError)
+			return appErr, participant
+		}
+		json.Unmarshal(w.Body.Bytes(), &participant)
+		return nil, participant
+	}
+
+	// 配對開始前 30 分鐘才開放報到
+	appErr, _ := getCode()
+	if assert.NotNil(t, appErr) {
+		assert.Equal(t, ErrCodeCheckInClosed, appErr.ErrorCode)
+	}
+
+	start := time.Now().Add(10 * time.Minute)
+	assert.NoError(t, db.Model(&match).Updates(map[string]interface{}{"match_time": start, "end_time": start.Add(2 * time.Hour)}).Error)
+	assert.NoError(t, db.Model(&models.Activity{}).Where("id = ?", match.ActivityID).Update("check_in_radius_meters", 200).Error)
+
+	appErr, code := getCode()
+	assert.Nil(t, appErr)
+	assert.Len(t, code.Code, 6)
+	assert.Contains(t, code.QRPayload, code.Code)
+	assert.True(t, code.ExpiresAt.After(time.Now()))
+
+	// 前一個時段的報到碼仍然有效，更早的則無效
+	now := time.Now()
+	step := now.Unix() / int64(checkInCodePeriod.Seconds())
+	assert.True(t, validCheckInCode(match.ID, checkInCode(match.ID, step-1), now))
+	assert.False(t, validCheckInCode(match.ID, checkInCode(match.ID, step-2), now))
+	assert.NotEqual(t, checkInCode(match.ID, step), checkInCode(match.ID+1, step))
+
+	// 未通過審核的使用者不能報到
+	appErr, _ = checkInAs(outsider.ID, fmt.Sprintf(`{"code": "%s", "latitude": 25.03, "longitude": 121.56}`, code.Code))
+	if assert.NotNil(t, appErr) {
+		assert.Equal(t, http.StatusForbidden, appErr.Code)
+	}
+
+	// 活動設定報到半徑時必須提供位置，且需在半徑內
+	appErr, _ = checkInAs(remote.ID, fmt.Sprintf(`{"code": "%s"}`, code.Code))
+	if assert.NotNil(t, appErr) {
+		assert.Equal(t, ErrCodeCheckInLocationNeed, appErr.ErrorCode)
+	}
+	appErr, _ = checkInAs(remote.ID, fmt.Sprintf(`{"code": "%s", "latitude": 25.05, "longitude": 121.56}`, code.Code))
+	if assert.NotNil(t, appErr) {
+		assert.Equal(t, ErrCodeCheckInTooFar, appErr.ErrorCode)
+	}
+
+	appErr, participant := checkInAs(guest.ID, fmt.Sprintf(`{"code": "%s", "latitude": 25.0305, "longitude": 121.5605}`, code.Code))
+	assert.Nil(t, appErr)
+	assert.NotNil(t, participant.CheckedInAt)
+
+	// 報到碼錯誤太多次後鎖定
+	wrong := "000000"
+	if wrong == code.Code {
+		wrong = "000001"
+	}
+	for i := 0; i < maxCheckInAttempts; i++ {
+		appErr, _ = checkInAs(remote.ID, fmt.Sprintf(`{"code": "%s"}`, wrong))
+		if assert.NotNil(t, appErr) {
+			assert.Equal(t, ErrCodeCheckInInvalidCode, appErr.ErrorCode)
+		}
+	}
+	appErr, _ = checkInAs(remote.ID, fmt.Sprintf(`{"code": "%s", "latitude": 25.03, "longitude": 121.56}`, code.Code))
+	if assert.NotNil(t, appErr) {
+		assert.Equal(t, http.StatusTooManyRequests, appErr.Code)
+	}
+	// 鎖定後的請求不再增加次數，位置不符但報到碼正確的嘗試也不計入
+	var attempts []int
+	assert.NoError(t, db.Model(&models.MatchParticipant{}).Where("match_id = ? AND user_id = ?", match.ID, remote.ID).Pluck("check_in_attempts", &attempts).Error)
+	assert.Equal(t, []int{maxCheckInAttempts}, attempts)
+
+	// 只有完成報到的參與者可以評分
+	ended := time.Now().Add(-time.Hour)
+	assert.NoError(t, db.Model(&match).Updates(map[string]interface{}{"match_time": ended.Add(-2 * time.Hour), "end_time": ended, "status": "completed"}).Error)
+	c, _ := newUserContext("POST", "/", nil, guest.ID)
+	assert.True(t, canReviewMatch(c, match.ID))
+	c, _ = newUserContext("POST", "/", nil, remote.ID)
+	assert.False(t, canReviewMatch(c, match.ID))
+}
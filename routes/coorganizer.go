@@ -0,0 +1,382 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"free2free/database"
+	"free2free/models"
+	"free2free/utils"
+
+	apperrors "free2free/errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CoOrganizerRequest 新增共同開局者或轉移開局者的對象
+type CoOrganizerRequest struct {
+	UserID int64 `json:"user_id" validate:"required,min=1"`
+}
+
+// TransferResponseRequest 回覆開局者轉移的請求
+type TransferResponseRequest struct {
+	Accept *bool `json:"accept" validate:"required"`
+}
+
+// listCoOrganizers 取得共同開局者
+// @Summary 共同開局者列表
+// @Description 列出配對局的共同開局者，開局者與共同開局者都可以查看
+// @Tags 開局者
+// @Produce json
+// @Param id path int true "配對局ID"
+// @Success 200 {array} MatchCoOrganizer
+// @Failure 403 {object} map[string]string "需要開局者權限"
+// @Router /organizer/matches/{id}/co-organizers [get]
+// @Security ApiKeyAuth
+func listCoOrganizers(c *gin.Context) {
+	matchID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || matchID <= 0 {
+		c.Error(apperrors.NewValidationError("無效的配對局 ID"))
+		return
+	}
+
+	coOrganizers := []models.MatchCoOrganizer{}
+	if err := database.GlobalDB.Conn.Preload("User").Where("match_id = ?", matchID).Order("id").Find(&coOrganizers).Error; err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+	c.JSON(http.StatusOK, coOrganizers)
+}
+
+// addCoOrganizer 新增共同開局者
+// @Summary 新增共同開局者
+// @Description 開局者將已審核通過的參與者設為共同開局者，共同開局者與開局者有相同的審核權限。只有開局者本人可以管理共同開局者
+// @Tags 開局者
+// @Accept json
+// @Produce json
+// @Param id path int true "配對局ID"
+// @Param co_organizer body CoOrganizerRequest true "使用者ID"
+// @Success 201 {object} MatchCoOrganizer
+// @Failure 400 {object} map[string]string "無效的請求資料或使用者不是已審核通過的參與者"
+// @Failure 403 {object} map[string]string "只有開局者可以管理共同開局者"
+// @Failure 409 {object} map[string]string "已是共同開局者"
+// @Router /organizer/matches/{id}/co-organizers [post]
+// @Security ApiKeyAuth
+func addCoOrganizer(c *gin.Context) {
+	match, user, ok := loadOwnedMatch(c)
+	if !ok {
+		return
+	}
+
+	var req CoOrganizerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.NewValidationError("無效的請求資料"))
+		return
+	}
+	v := validator.New()
+	if err := v.Struct(&req); err != nil {
+		c.Error(apperrors.NewValidationError(err.Error()))
+		return
+	}
+
+	coOrganizer := models.MatchCoOrganizer{MatchID: match.ID, UserID: req.UserID, AddedBy: user.ID, CreatedAt: time.Now()}
+	err := database.GlobalDB.Conn.Transaction(func(tx *gorm.DB) error {
+		if err := requireApprovedParticipant(tx, match, req.UserID); err != nil {
+			return err
+		}
+		if err := tx.Create(&coOrganizer).Error; err != nil {
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return apperrors.NewAppError(http.StatusConflict, "此使用者已是共同開局者")
+			}
+			return apperrors.MapGORMError(err)
+		}
+		change := models.MatchChange{MatchID: match.ID, ChangedBy: user.ID, Field: "co_organizers", NewValue: strconv.FormatInt(req.UserID, 10), CreatedAt: time.Now()}
+		if err := tx.Create(&change).Error; err != nil {
+			return apperrors.MapGORMError(err)
+		}
+		return nil
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	database.GlobalDB.Conn.Preload("User").First(&coOrganizer, coOrganizer.ID)
+	c.JSON(http.StatusCreated, coOrganizer)
+}
+
+// removeCoOrganizer 移除共同開局者
+// @Summary 移除共同開局者
+// @Description 開局者取消使用者的共同開局者身份，使用者仍是參與者
+// @Tags 開局者
+// @Produce json
+// @Param id path int true "配對局ID"
+// @Param user_id path int true "使用者ID"
+// @Success 200 {object} map[string]string
+// @Failure 403 {object} map[string]string "只有開局者可以管理共同開局者"
+// @Failure 404 {object} map[string]string "使用者不是共同開局者"
+// @Router /organizer/matches/{id}/co-organizers/{user_id} [delete]
+// @Security ApiKeyAuth
+func removeCoOrganizer(c *gin.Context) {
+	match, user, ok := loadOwnedMatch(c)
+	if !ok {
+		return
+	}
+
+	coOrganizerID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil || coOrganizerID <= 0 {
+		c.Error(apperrors.NewValidationError("無效的使用者 ID"))
+		return
+	}
+
+	err = database.GlobalDB.Conn.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("match_id = ? AND user_id = ?", match.ID, coOrganizerID).Delete(&models.MatchCoOrganizer{})
+		if result.Error != nil {
+			return apperrors.MapGORMError(result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return apperrors.NewAppError(http.StatusNotFound, "此使用者不是共同開局者")
+		}
+		change := models.MatchChange{MatchID: match.ID, ChangedBy: user.ID, Field: "co_organizers", OldValue: strconv.FormatInt(coOrganizerID, 10), CreatedAt: time.Now()}
+		if err := tx.Create(&change).Error; err != nil {
+			return apperrors.MapGORMError(err)
+		}
+		return nil
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "已移除共同開局者"})
+}
+
+// transferMatch 轉移開局者
+// @Summary 轉移開局者
+// @Description 開局者無法出席時，將配對局轉移給已審核通過的參與者，對方接受後才生效。新的轉移會取消尚未回覆的轉移
+// @Tags 開局者
+// @Accept json
+// @Produce json
+// @Param id path int true "配對局ID"
+// @Param transfer body CoOrganizerRequest true "接手的使用者ID"
+// @Success 201 {object} MatchTransfer
+// @Failure 400 {object} map[string]string "無效的請求資料、配對局已開始或使用者不是已審核通過的參與者"
+// @Failure 403 {object} map[string]string "只有開局者可以轉移配對局"
+// @Router /organizer/matches/{id}/transfer [post]
+// @Security ApiKeyAuth
+func transferMatch(c *gin.Context) {
+	match, user, ok := loadOwnedMatch(c)
+	if !ok {
+		return
+	}
+
+	var req CoOrganizerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.NewValidationError("無效的請求資料"))
+		return
+	}
+	v := validator.New()
+	if err := v.Struct(&req); err != nil {
+		c.Error(apperrors.NewValidationError(err.Error()))
+		return
+	}
+	if req.UserID == user.ID {
+		c.Error(apperrors.NewValidationError("不能轉移給自己"))
+		return
+	}
+	if match.Status != "open" || !match.MatchTime.After(time.Now()) {
+		c.Error(apperrors.NewValidationError("只能轉移尚未開始的配對局"))
+		return
+	}
+
+	transfer := models.MatchTransfer{MatchID: match.ID, FromUserID: user.ID, ToUserID: req.UserID, Status: "pending", CreatedAt: time.Now()}
+	err := database.GlobalDB.Conn.Transaction(func(tx *gorm.DB) error {
+		if err := requireApprovedParticipant(tx, match, req.UserID); err != nil {
+			return err
+		}
+		err := tx.Model(&models.MatchTransfer{}).
+			Where("match_id = ? AND status = ?", match.ID, "pending").
+			Updates(map[string]interface{}{"status": "cancelled", "responded_at": time.Now()}).Error
+		if err != nil {
+			return apperrors.MapGORMError(err)
+		}
+		if err := tx.Create(&transfer).Error; err != nil {
+			return apperrors.MapGORMError(err)
+		}
+
+		var activity models.Activity
+		if err := tx.Select("id", "title").First(&activity, match.ActivityID).Error; err != nil {
+			return apperrors.MapGORMError(err)
+		}
+		notification := models.Notification{
+			UserID:    req.UserID,
+			Type:      models.NotificationTransfer,
+			MatchID:   &match.ID,
+			Message:   fmt.Sprintf("開局者希望將配對局「%s」轉移給您，請確認是否接手", activity.Title),
+			CreatedAt: time.Now(),
+		}
+		if err := tx.Create(&notification).Error; err != nil {
+			return apperrors.MapGORMError(err)
+		}
+		return nil
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, transfer)
+}
+
+// respondTransfer 回覆開局者轉移
+// @Summary 回覆開局者轉移
+// @Description 接手的參與者接受或拒絕開局者轉移。接受後成為開局者，原開局者不再有開局者權限，變更會記錄在配對局變更紀錄與時間軸中
+// @Tags 使用者
+// @Accept json
+// @Produce json
+// @Param id path int true "配對局ID"
+// @Param response body TransferResponseRequest true "是否接受"
+// @Success 200 {object} MatchTransfer
+// @Failure 400 {object} map[string]string "無效的請求資料、沒有待回覆的轉移或配對局已開始"
+// @Router /user/matches/{id}/transfer [post]
+// @Security ApiKeyAuth
+func respondTransfer(c *gin.Context) {
+	matchID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || matchID <= 0 {
+		c.Error(apperrors.NewValidationError("無效的配對局 ID"))
+		return
+	}
+
+	var req TransferResponseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.NewValidationError("無效的請求資料"))
+		return
+	}
+	v := validator.New()
+	if err := v.Struct(&req); err != nil {
+		c.Error(apperrors.NewValidationError(err.Error()))
+		return
+	}
+
+	user, err := utils.GetAuthenticatedUser(c)
+	if err != nil {
+		c.Error(apperrors.NewUnauthorizedError("未登入"))
+		return
+	}
+
+	var transfer models.MatchTransfer
+	err = database.GlobalDB.Conn.Transaction(func(tx *gorm.DB) error {
+		// 鎖定配對局，避免與開局者的其他操作同時進行
+		var match models.Match
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Activity").First(&match, matchID).Error; err != nil {
+			return apperrors.MapGORMError(err)
+		}
+		if err := tx.Where("match_id = ? AND to_user_id = ? AND status = ?", matchID, user.ID, "pending").First(&transfer).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return apperrors.NewValidationError("沒有待回覆的開局者轉移")
+			}
+			return apperrors.MapGORMError(err)
+		}
+
+		now := time.Now()
+		transfer.RespondedAt = &now
+		transfer.Status = "declined"
+		message := fmt.Sprintf("對方拒絕接手配對局「%s」", match.Activity.Title)
+		if *req.Accept {
+			if match.OrganizerID != transfer.FromUserID || match.Status != "open" || !match.MatchTime.After(now) {
+				return apperrors.NewValidationError("配對局已無法轉移")
+			}
+			if err := requireApprovedParticipant(tx, &match, user.ID); err != nil {
+				return err
+			}
+			if err := tx.Model(&match).Update("organizer_id", user.ID).Error; err != nil {
+				return apperrors.MapGORMError(err)
+			}
+			// 新的開局者不再需要共同開局者身份
+			if err := tx.Where("match_id = ? AND user_id = ?", match.ID, user.ID).Delete(&models.MatchCoOrganizer{}).Error; err != nil {
+				return apperrors.MapGORMError(err)
+			}
+			change := models.MatchChange{
+				MatchID:   match.ID,
+				ChangedBy: user.ID,
+				Field:     "organizer_id",
+				OldValue:  strconv.FormatInt(transfer.FromUserID, 10),
+				NewValue:  strconv.FormatInt(user.ID, 10),
+				CreatedAt: now,
+			}
+			if err := tx.Create(&change).Error; err != nil {
+				return apperrors.MapGORMError(err)
+			}
+			// 負責人變更也記錄在時間軸，狀態不變
+			reason := fmt.Sprintf("開局者由使用者 %d 轉移給使用者 %d", transfer.FromUserID, user.ID)
+			if err := recordEvent(tx, models.NewMatchEvent(match.ID, match.Status, match.Status, user.ID, reason)); err != nil {
+				return err
+			}
+			transfer.Status = "accepted"
+			message = fmt.Sprintf("對方已接手配對局「%s」，您已不是開局者", match.Activity.Title)
+		}
+
+		if err := tx.Model(&transfer).Updates(map[string]interface{}{"status": transfer.Status, "responded_at": now}).Error; err != nil {
+			return apperrors.MapGORMError(err)
+		}
+		notification := models.Notification{UserID: transfer.FromUserID, Type: models.NotificationTransferReply, MatchID: &match.ID, Message: message, CreatedAt: now}
+		if err := tx.Create(&notification).Error; err != nil {
+			return apperrors.MapGORMError(err)
+		}
+		return nil
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, transfer)
+}
+
+// loadOwnedMatch 取得配對局並確認當前使用者是開局者本人；共同開局者不能管理開局者身份
+func loadOwnedMatch(c *gin.Context) (*models.Match, *models.User, bool) {
+	matchID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || matchID <= 0 {
+		c.Error(apperrors.NewValidationError("無效的配對局 ID"))
+		return nil, nil, false
+	}
+
+	user, err := utils.GetAuthenticatedUser(c)
+	if err != nil {
+		c.Error(apperrors.NewUnauthorizedError("未登入"))
+		return nil, nil, false
+	}
+
+	var match models.Match
+	if err := database.GlobalDB.Conn.First(&match, matchID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Error(apperrors.NewAppError(http.StatusNotFound, "配對局不存在"))
+			return nil, nil, false
+		}
+		c.Error(apperrors.MapGORMError(err))
+		return nil, nil, false
+	}
+	if match.OrganizerID != user.ID {
+		c.Error(apperrors.NewForbiddenError("只有開局者本人可以執行此操作"))
+		return nil, nil, false
+	}
+	return &match, user, true
+}
+
+// requireApprovedParticipant 確認使用者是配對局已審核通過的參與者
+func requireApprovedParticipant(tx *gorm.DB, match *models.Match, userID int64) error {
+	var approved int64
+	err := tx.Model(&models.MatchParticipant{}).
+		Where("match_id = ? AND user_id = ? AND status = ?", match.ID, userID, "approved").
+		Count(&approved).Error
+	if err != nil {
+		return apperrors.MapGORMError(err)
+	}
+	if approved == 0 {
+		return apperrors.NewValidationError("使用者必須是此配對局已審核通過的參與者")
+	}
+	return nil
+}
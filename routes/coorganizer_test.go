@@ -0,0 +1,128 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"free2free/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCoOrganizersAndTransfer(t *testing.T) {
+	db := setupUserTestDatabase(t)
+
+	organizer := seedUser(t, db, "organizer")
+	alice := seedUser(t, db, "alice")
+	bob := seedUser(t, db, "bob")
+	outsider := seedUser(t, db, "outsider")
+	match := seedMatch(t, db, organizer.ID)
+	for _, userID := range []int64{alice.ID, bob.ID} {
+		assert.NoError(t, db.Create(&models.MatchParticipant{MatchID: match.ID, UserID: userID, Status: "approved", JoinedAt: time.Now()}).Error)
+	}
+
+	isOrganizer := func(userID int64) bool {
+		c, _ := newUserContext("GET", "/", nil, userID)
+		return isMatchOrganizer(c, match.ID)
+	}
+	call := func(handler gin.HandlerFunc, userID int64, body string, params ...gin.Param) (*gin.Context, []byte) {
+		c, w := newUserContext("POST", "/", []byte(body), userID)
+		c.Params = append(gin.Params{{Key: "id", Value: fmt.Sprint(match.ID)}}, params...)
+		handler(c)
+		return c, w.Body.Bytes()
+	}
+
+	// 共同開局者與開局者有相同的權限
+	assert.False(t, isOrganizer(alice.ID))
+	c, _ := call(addCoOrganizer, organizer.ID, fmt.Sprintf(`{"user_id": %d}`, alice.ID))
+	assert.Empty(t, c.Errors)
+	assert.True(t, isOrganizer(alice.ID))
+
+	// 只有已審核通過的參與者可以成為共同開局者，共同開局者不能管理共同開局者
+	c, _ = call(addCoOrganizer, organizer.ID, fmt.Sprintf(`{"user_id": %d}`, outsider.ID))
+	assert.NotEmpty(t, c.Errors)
+	c, _ = call(addCoOrganizer, alice.ID, fmt.Sprintf(`{"user_id": %d}`, bob.ID))
+	assert.NotEmpty(t, c.Errors)
+
+	// 轉移需要對方接受才生效
+	c, body := call(transferMatch, organizer.ID, fmt.Sprintf(`{"user_id": %d}`, bob.ID))
+	assert.Empty(t, c.Errors)
+	var transfer models.MatchTransfer
+	assert.NoError(t, json.Unmarshal(body, &transfer))
+	assert.Equal(t, "pending", transfer.Status)
+	assert.True(t, isOrganizer(organizer.ID))
+
+	// 只有接手的使用者可以回覆
+	c, _ = call(respondTransfer, alice.ID, `{"accept": true}`)
+	assert.NotEmpty(t, c.Errors)
+
+	c, body = call(respondTransfer, bob.ID, `{"accept": true}`)
+	assert.Empty(t, c.Errors)
+	assert.NoError(t, json.Unmarshal(body, &transfer))
+	assert.Equal(t, "accepted", transfer.Status)
+
+	var updated models.Match
+	assert.NoError(t, db.First(&updated, match.ID).Error)
+	assert.Equal(t, bob.ID, updated.OrganizerID)
+	assert.False(t, isOrganizer(organizer.ID))
+	assert.True(t, isOrganizer(bob.ID))
+	assert.True(t, isOrganizer(alice.ID))
+
+	var history []models.MatchChange
+	assert.NoError(t, db.Where("match_id = ?", match.ID).Order("id").Find(&history).Error)
+	if assert.Len(t, history, 2) {
+		assert.Equal(t, "co_organizers", history[0].Field)
+		assert.Equal(t, "organizer_id", history[1].Field)
+		assert.Equal(t, fmt.Sprint(organizer.ID), history[1].OldValue)
+		assert.Equal(t, fmt.Sprint(bob.ID), history[1].NewValue)
+	}
+
+	var replies int64
+	assert.NoError(t, db.Model(&models.Notification{}).Where("user_id = ? AND type = ?", organizer.ID, models.NotificationTransferReply).Count(&replies).Error)
+	assert.Equal(t, int64(1), replies)
+
+	// 拒絕轉移時開局者不變
+	c, _ = call(transferMatch, bob.ID, fmt.Sprintf(`{"user_id": %d}`, alice.ID))
+	assert.Empty(t, c.Errors)
+	c, body = call(respondTransfer, alice.ID, `{"accept": false}`)
+	assert.Empty(t, c.Errors)
+	assert.NoError(t, json.Unmarshal(body, &transfer))
+	assert.Equal(t, "declined", transfer.Status)
+	assert.NoError(t, db.First(&updated, match.ID).Error)
+	assert.Equal(t, bob.ID, updated.OrganizerID)
+}
+
+func TestTransferRecordsTimelineEvent(t *testing.T) {
+	db := setupUserTestDatabase(t)
+
+	organizer := seedUser(t, db, "organizer")
+	bob := seedUser(t, db, "bob")
+	match := seedMatch(t, db, organizer.ID)
+	assert.NoError(t, db.Create(&models.MatchParticipant{MatchID: match.ID, UserID: bob.ID, Status: "approved", JoinedAt: time.Now()}).Error)
+
+	c, _ := newUserContext("POST", "/", []byte(fmt.Sprintf(`{"user_id": %d}`, bob.ID)), organizer.ID)
+	c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(match.ID)}}
+	transferMatch(c)
+	assert.Empty(t, c.Errors)
+	c, _ = newUserContext("POST", "/", []byte(`{"accept": true}`), bob.ID)
+	c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(match.ID)}}
+	respondTransfer(c)
+	assert.Empty(t, c.Errors)
+
+	// 負責人變更記錄在時間軸，包含執行者與前後的開局者
+	var events []models.MatchEvent
+	assert.NoError(t, db.Where("match_id = ? AND participant_id IS NULL", match.ID).Find(&events).Error)
+	if assert.Len(t, events, 1) {
+		event := events[0]
+		if assert.NotNil(t, event.ActorID) {
+			assert.Equal(t, bob.ID, *event.ActorID)
+		}
+		assert.Equal(t, "open", event.FromStatus)
+		assert.Equal(t, "open", event.ToStatus)
+		assert.Contains(t, event.Reason, fmt.Sprint(organizer.ID))
+		assert.Contains(t, event.Reason, fmt.Sprint(bob.ID))
+	}
+}
@@ -0,0 +1,328 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"free2free/database"
+	"free2free/models"
+	"free2free/utils"
+
+	apperrors "free2free/errors"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 儀表板每個分組預設與最多回傳的配對局數
+const (
+	defaultDashboardLimit = 10
+	maxDashboardLimit     = 50
+)
+
+// maxReviewWindow 活動可設定的最長評分期限 (review_window_hours 上限 168)
+const maxReviewWindow = 168 * time.Hour
+
+// DashboardMatch 儀表板中的配對局；開局中 (包含共同開局) 的配對局另外附上待審核人數，
+// 即將參加但需要重新確認的配對局附上回覆期限
+type DashboardMatch struct {
+	models.Match
+	PendingRequests   *int64     `json:"pending_requests,omitempty"`
+	ReconfirmDeadline *time.Time `json:"reconfirm_deadline,omitempty"`
+}
+
+// DashboardGroup 儀表板的一個分組，count 為完整數量，matches 最多 limit 筆
+type DashboardGroup struct {
+	Count   int64            `json:"count"`
+	Matches []DashboardMatch `json:"matches"`
+}
+
+// ReviewTarget 尚未評分的對象
+type ReviewTarget struct {
+	UserID    int64  `json:"user_id"`
+	Name      string `json:"name"`
+	AvatarURL string `json:"avatar_url"`
+}
+
+// ReviewReminder 仍可評分且還有對象未評分的配對局
+type ReviewReminder struct {
+	Match     models.Match   `json:"match"`
+	Deadline  time.Time      `json:"deadline"`
+	Reviewees []ReviewTarget `json:"reviewees"`
+}
+
+// Dashboard 使用者的配對局總覽
+type Dashboard struct {
+	Organizing       DashboardGroup   `json:"organizing"`
+	AwaitingApproval DashboardGroup   `json:"awaiting_approval"`
+	Upcoming         DashboardGroup   `json:"upcoming"`
+	Past             DashboardGroup   `json:"past"`
+	Cancelled        DashboardGroup   `json:"cancelled"`
+	Rejected         DashboardGroup   `json:"rejected"`
+	ReviewReminders  []ReviewReminder `json:"review_reminders"`
+}
+
+// getDashboard 取得使用者的配對局總覽
+// @Summary 我的配對局總覽
+// @Description 將使用者的配對局分為開局中、等待審核、即將參加 (包含等待重新確認，附上回覆期限)、過去、已取消與被拒絕，並列出仍可評分的配對局
+// @Tags 使用者
+// @Produce json
+// @Param limit query int false "每個分組回傳的配對局數 (1-50)，預設 10"
+// @Success 200 {object} Dashboard
+// @Failure 400 {object} map[string]string "無效的 limit 參數"
+// @Failure 401 {object} map[string]string "未登入"
+// @Failure 500 {object} map[string]string "無法取得配對局總覽"
+// @Router /user/dashboard [get]
+// @Security ApiKeyAuth
+func getDashboard(c *gin.Context) {
+	user, err := utils.GetAuthenticatedUser(c)
+	if err != nil {
+		c.Error(apperrors.NewUnauthorizedError("未登入"))
+		return
+	}
+
+	limit := defaultDashboardLimit
+	if raw := c.Query("limit"); raw != "" {
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxDashboardLimit {
+			c.Error(apperrors.NewCodedError(http.StatusBadRequest, ErrCodeInvalidLimit, "limit 必須介於 1 到 50 之間"))
+			return
+		}
+	}
+
+	db := database.GlobalDB.Conn
+	now := time.Now()
+	participation := func(statuses ...string) *gorm.DB {
+		return db.Model(&models.MatchParticipant{}).
+			Select("match_id").
+			Where("user_id = ? AND status IN ?", user.ID, statuses)
+	}
+	matches := func() *gorm.DB {
+		return db.Model(&models.Match{})
+	}
+	coOrganized := db.Model(&models.MatchCoOrganizer{}).
+		Select("match_id").
+		Where("user_id = ?", user.ID)
+
+	var dashboard Dashboard
+	groups := []struct {
+		target *DashboardGroup
+		query  *gorm.DB
+		order  string
+	}{
+		{
+			target: &dashboard.Organizing,
+			query: matches().
+				Where("organizer_id = ? OR id IN (?)", user.ID, coOrganized).
+				Where("status = ? AND end_time > ?", "open", now),
+			order: "match_time ASC",
+		},
+		{
+			target: &dashboard.AwaitingApproval,
+			query:  matches().Where("id IN (?) AND status = ? AND end_time > ?", participation("pending"), "open", now),
+			order:  "match_time ASC",
+		},
+		{
+			target: &dashboard.Upcoming,
+			// 等待重新確認的參與者仍保留名額，需在期限前回覆
+			query: matches().Where("id IN (?) AND status = ? AND end_time > ?", participation("approved", "reconfirm"), "open", now),
+			order: "match_time ASC",
+		},
+		{
+			// 已結束但尚未被背景工作標記為 completed 的配對局也算在過去
+			target: &dashboard.Past,
+			query: matches().
+				Where("organizer_id = ? OR id IN (?)", user.ID, participation(models.ApprovedParticipantStatuses...)).
+				Where("status = ? OR (status = ? AND end_time <= ?)", "completed", "open", now),
+			order: "match_time DESC",
+		},
+		{
+			target: &dashboard.Cancelled,
+			query: matches().
+				Where("organizer_id = ? OR id IN (?)", user.ID, participation("pending", "approved", "reconfirm")).
+				Where("status IN ?", []string{"cancelled", "expired"}),
+			order: "match_time DESC",
+		},
+		{
+			target: &dashboard.Rejected,
+			query:  matches().Where("id IN (?)", participation("rejected")),
+			order:  "match_time DESC",
+		},
+	}
+
+	for _, group := range groups {
+		result, err := loadDashboardGroup(group.query, group.order, limit)
+		if err != nil {
+			c.Error(apperrors.MapGORMError(err))
+			return
+		}
+		*group.target = result
+	}
+
+	if err := attachPendingRequests(db, &dashboard.Organizing); err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+
+	if err := attachReconfirmDeadlines(db, user.ID, &dashboard.Upcoming); err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+
+	reminders, err := reviewReminders(db, user.ID, now)
+	if err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+	dashboard.ReviewReminders = reminders
+
+	c.JSON(http.StatusOK, dashboard)
+}
+
+// loadDashboardGroup 計算分組的完整數量並取出前 limit 筆
+func loadDashboardGroup(query *gorm.DB, order string, limit int) (DashboardGroup, error) {
+	group := DashboardGroup{Matches: []DashboardMatch{}}
+	if err := query.Session(&gorm.Session{}).Count(&group.Count).Error; err != nil {
+		return group, err
+	}
+
+	var matches []models.Match
+	err := query.Session(&gorm.Session{}).
+		Preload("Activity.Location").
+		Preload("Organizer").
+		Order(order).
+		Order("id").
+		Limit(limit).
+		Find(&matches).Error
+	if err != nil {
+		return group, err
+	}
+	for _, match := range matches {
+		group.Matches = append(group.Matches, DashboardMatch{Match: match})
+	}
+	return group, nil
+}
+
+// attachPendingRequests 為開局中的配對局附上待審核人數
+func attachPendingRequests(db *gorm.DB, group *DashboardGroup) error {
+	if len(group.Matches) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(group.Matches))
+	for i, match := range group.Matches {
+		ids[i] = match.ID
+	}
+
+	var rows []struct {
+		MatchID int64
+		Count   int64
+	}
+	err := db.Model(&models.MatchParticipant{}).
+		Select("match_id, COUNT(*) AS count").
+		Where("match_id IN ? AND status = ?", ids, "pending").
+		Group("match_id").
+		Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+
+	counts := make(map[int64]int64, len(rows))
+	for _, row := range rows {
+		counts[row.MatchID] = row.Count
+	}
+	for i := range group.Matches {
+		count := counts[group.Matches[i].ID]
+		group.Matches[i].PendingRequests = &count
+	}
+	return nil
+}
+
+// attachReconfirmDeadlines 為使用者需要重新確認的配對局附上回覆期限
+func attachReconfirmDeadlines(db *gorm.DB, userID int64, group *DashboardGroup) error {
+	if len(group.Matches) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(group.Matches))
+	for i, match := range group.Matches {
+		ids[i] = match.ID
+	}
+
+	var participants []models.MatchParticipant
+	err := db.Select("match_id", "reconfirm_deadline").
+		Where("match_id IN ? AND user_id = ? AND status = ?", ids, userID, "reconfirm").
+		Find(&participants).Error
+	if err != nil {
+		return err
+	}
+
+	deadlines := make(map[int64]*time.Time, len(participants))
+	for _, p := range participants {
+		deadlines[p.MatchID] = p.ReconfirmDeadline
+	}
+	for i := range group.Matches {
+		group.Matches[i].ReconfirmDeadline = deadlines[group.Matches[i].ID]
+	}
+	return nil
+}
+
+// reviewReminders 找出使用者仍可評分 (與 canReviewMatch 的條件相同) 且還有對象未評分的配對局
+func reviewReminders(db *gorm.DB, userID int64, now time.Time) ([]ReviewReminder, error) {
+	approved := db.Model(&models.MatchParticipant{}).
+		Select("match_id").
+		Where("user_id = ? AND status IN ?", userID, models.ApprovedParticipantStatuses).
+		Where("checked_in_at IS NOT NULL OR status = ?", "attended")
+
+	var matches []models.Match
+	err := db.Preload("Activity.Location").
+		Preload("Organizer").
+		Where("id IN (?) AND status = ? AND end_time > ?", approved, "completed", now.Add(-maxReviewWindow)).
+		Order("end_time ASC").
+		Find(&matches).Error
+	if err != nil {
+		return nil, err
+	}
+
+	reminders := []ReviewReminder{}
+	for _, match := range matches {
+		deadline := reviewDeadline(&match)
+		if !now.Before(deadline) {
+			continue
+		}
+
+		var reviewed []int64
+		err := db.Model(&models.Review{}).
+			Where("match_id = ? AND reviewer_id = ?", match.ID, userID).
+			Pluck("reviewee_id", &reviewed).Error
+		if err != nil {
+			return nil, err
+		}
+		done := map[int64]bool{userID: true}
+		for _, id := range reviewed {
+			done[id] = true
+		}
+
+		var participants []models.MatchParticipant
+		if err := db.Preload("User").Where("match_id = ? AND status IN ?", match.ID, models.ApprovedParticipantStatuses).Find(&participants).Error; err != nil {
+			return nil, err
+		}
+
+		candidates := []models.User{match.Organizer}
+		for _, p := range participants {
+			candidates = append(candidates, p.User)
+		}
+
+		var reviewees []ReviewTarget
+		for _, candidate := range candidates {
+			if done[candidate.ID] {
+				continue
+			}
+			done[candidate.ID] = true
+			reviewees = append(reviewees, ReviewTarget{UserID: candidate.ID, Name: candidate.Name, AvatarURL: candidate.AvatarURL})
+		}
+		if len(reviewees) > 0 {
+			reminders = append(reminders, ReviewReminder{Match: match, Deadline: deadline, Reviewees: reviewees})
+		}
+	}
+	return reminders, nil
+}
@@ -0,0 +1,132 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"free2free/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDashboardGroupsMatches(t *testing.T) {
+	db := setupUserTestDatabase(t)
+
+	me := seedUser(t, db, "me")
+	other := seedUser(t, db, "other")
+	friend := seedUser(t, db, "friend")
+
+	organizing := seedMatch(t, db, me.ID)
+	awaiting := seedMatch(t, db, other.ID)
+	upcoming := seedMatch(t, db, other.ID)
+	past := seedMatch(t, db, other.ID)
+	cancelled := seedMatch(t, db, other.ID)
+	rejected := seedMatch(t, db, other.ID)
+
+	ended := time.Now().Add(-time.Hour)
+	assert.NoError(t, db.Model(&past).Updates(map[string]interface{}{"match_time": ended.Add(-2 * time.Hour), "end_time": ended, "status": "completed"}).Error)
+	assert.NoError(t, db.Model(&cancelled).Update("status", "cancelled").Error)
+
+	join := func(match models.Match, userID int64, status string) {
+		assert.NoError(t, db.Create(&models.MatchParticipant{MatchID: match.ID, UserID: userID, Status: status, JoinedAt: time.Now()}).Error)
+	}
+	join(organizing, other.ID, "pending")
+	join(awaiting, me.ID, "pending")
+	join(upcoming, me.ID, "approved")
+	join(past, me.ID, "approved")
+	join(past, friend.ID, "approved")
+	join(cancelled, me.ID, "approved")
+	join(rejected, me.ID, "rejected")
+
+	// 完成報到才會出現在評分提醒
+	assert.NoError(t, db.Model(&models.MatchParticipant{}).Where("match_id = ? AND user_id = ?", past.ID, me.ID).Update("checked_in_at", ended.Add(-2*time.Hour)).Error)
+
+	// 已經評分過開局者，只剩另一位參與者需要評分
+	assert.NoError(t, db.Create(&models.Review{MatchID: past.ID, ReviewerID: me.ID, RevieweeID: other.ID, Score: 5, CreatedAt: time.Now()}).Error)
+
+	c, w := newUserContext("GET", "/user/dashboard", nil, me.ID)
+	getDashboard(c)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var dashboard Dashboard
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &dashboard))
+
+	groups := map[string]struct {
+		group DashboardGroup
+		match models.Match
+	}{
+		"organizing":        {dashboard.Organizing, organizing},
+		"awaiting_approval": {dashboard.AwaitingApproval, awaiting},
+		"upcoming":          {dashboard.Upcoming, upcoming},
+		"past":              {dashboard.Past, past},
+		"cancelled":         {dashboard.Cancelled, cancelled},
+		"rejected":          {dashboard.Rejected, rejected},
+	}
+	for name, expected := range groups {
+		assert.Equal(t, int64(1), expected.group.Count, name)
+		if assert.Len(t, expected.group.Matches, 1, name) {
+			assert.Equal(t, expected.match.ID, expected.group.Matches[0].ID, name)
+		}
+	}
+
+	if assert.NotNil(t, dashboard.Organizing.Matches[0].PendingRequests) {
+		assert.Equal(t, int64(1), *dashboard.Organizing.Matches[0].PendingRequests)
+	}
+
+	if assert.Len(t, dashboard.ReviewReminders, 1) {
+		reminder := dashboard.ReviewReminders[0]
+		assert.Equal(t, past.ID, reminder.Match.ID)
+		assert.WithinDuration(t, ended.Add(4*time.Hour), reminder.Deadline, time.Second)
+		if assert.Len(t, reminder.Reviewees, 1) {
+			assert.Equal(t, friend.ID, reminder.Reviewees[0].UserID)
+		}
+	}
+}
+
+func TestDashboardIncludesCoOrganizedMatches(t *testing.T) {
+	db := setupUserTestDatabase(t)
+
+	me := seedUser(t, db, "me")
+	other := seedUser(t, db, "other")
+	coOrganized := seedMatch(t, db, other.ID)
+	assert.NoError(t, db.Create(&models.MatchParticipant{MatchID: coOrganized.ID, UserID: me.ID, Status: "approved", JoinedAt: time.Now()}).Error)
+	assert.NoError(t, db.Create(&models.MatchCoOrganizer{MatchID: coOrganized.ID, UserID: me.ID, AddedBy: other.ID}).Error)
+
+	c, w := newUserContext("GET", "/user/dashboard", nil, me.ID)
+	getDashboard(c)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var dashboard Dashboard
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &dashboard))
+	assert.Equal(t, int64(1), dashboard.Organizing.Count)
+	if assert.Len(t, dashboard.Organizing.Matches, 1) {
+		assert.Equal(t, coOrganized.ID, dashboard.Organizing.Matches[0].ID)
+		assert.NotNil(t, dashboard.Organizing.Matches[0].PendingRequests)
+	}
+}
+
+func TestDashboardShowsReconfirmDeadline(t *testing.T) {
+	db := setupUserTestDatabase(t)
+
+	me := seedUser(t, db, "me")
+	other := seedUser(t, db, "other")
+	match := seedMatch(t, db, other.ID)
+	deadline := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+	assert.NoError(t, db.Create(&models.MatchParticipant{MatchID: match.ID, UserID: me.ID, Status: "reconfirm", JoinedAt: time.Now(), ReconfirmDeadline: &deadline}).Error)
+
+	c, w := newUserContext("GET", "/user/dashboard", nil, me.ID)
+	getDashboard(c)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var dashboard Dashboard
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &dashboard))
+	assert.Equal(t, int64(1), dashboard.Upcoming.Count)
+	if assert.Len(t, dashboard.Upcoming.Matches, 1) {
+		assert.Equal(t, match.ID, dashboard.Upcoming.Matches[0].ID)
+		if assert.NotNil(t, dashboard.Upcoming.Matches[0].ReconfirmDeadline) {
+			assert.True(t, deadline.Equal(*dashboard.Upcoming.Matches[0].ReconfirmDeadline))
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"free2free/database"
+	"free2free/models"
+	"free2free/utils"
+
+	apperrors "free2free/errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 批次審核中每位參與者的結果
+const (
+	DecisionApproved           = "approved"
+	DecisionRejected           = "rejected"
+	DecisionUnchanged          = "unchanged"
+	DecisionNotFound           = "not_found"
+	DecisionAttendanceRecorded = "attendance_recorded"
+	DecisionCapacityExceeded   = "capacity_exceeded"
+	DecisionInvalidStatus      = "invalid_status"
+)
+
+// RejectionRequest 拒絕參與的理由，reason_code 從 models.RejectionReasons 選擇，reason 為自行填寫的說明
+type RejectionRequest struct {
+	ReasonCode string `json:"reason_code" validate:"omitempty,oneof=full schedule profile other"`
+	Reason     string `json:"reason" validate:"omitempty,max=500"`
+}
+
+// BatchDecisionRequest 批次審核參與者的請求
+type BatchDecisionRequest struct {
+	ParticipantIDs []int64 `json:"participant_ids" validate:"required,min=1,max=100,unique,dive,min=1"`
+	Action         string  `json:"action" validate:"required,oneof=approve reject"`
+	RejectionRequest
+}
+
+// ParticipantDecision 批次審核中單一參與者的結果
+type ParticipantDecision struct {
+	ParticipantID int64  `json:"participant_id"`
+	UserID        int64  `json:"user_id,omitempty"`
+	Result        string `json:"result"`
+	Status        string `json:"status,omitempty"`
+}
+
+// BatchDecisionResult 批次審核的結果；任一參與者無法處理時整批都不會套用
+type BatchDecisionResult struct {
+	Applied bool                  `json:"applied"`
+	Results []ParticipantDecision `json:"results"`
+}
+
+// errBatchRejected 批次中有無法處理的參與者，回滾整批
+var errBatchRejected = errors.New("batch decision rejected")
+
+// batchDecideParticipants 批次審核參與者
+// @Summary 批次審核參與者
+// @Description 開局者一次審核通過或拒絕多位參與者，整批一起套用。審核通過的人數超過剩餘名額、參與者不存在或已記錄出席狀況時整批都不套用，回傳 409 與每位參與者的結果。拒絕時可以選擇理由代碼 (full, schedule, profile, other) 或自行填寫理由，理由會顯示給申請者
+// @Tags 開局者
+// @Accept json
+// @Produce json
+// @Param id path int true "配對局ID"
+// @Param decision body BatchDecisionRequest true "參與者 ID 與審核動作"
+// @Success 200 {object} BatchDecisionResult
+// @Failure 400 {object} map[string]string "無效的請求資料"
+// @Failure 403 {object} map[string]string "需要開局者權限"
+// @Failure 409 {object} BatchDecisionResult "有參與者無法處理，整批未套用"
+// @Router /organizer/matches/{id}/participants/batch [post]
+// @Security ApiKeyAuth
+func batchDecideParticipants(c *gin.Context) {
+	matchID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || matchID <= 0 {
+		c.Error(apperrors.NewValidationError("無效的配對局 ID"))
+		return
+	}
+
+	var req BatchDecisionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.NewValidationError("無效的請求資料"))
+		return
+	}
+	v := validator.New()
+	if err := v.Struct(&req); err != nil {
+		c.Error(apperrors.NewValidationError(err.Error()))
+		return
+	}
+	if req.Action == "approve" && (req.ReasonCode != "" || req.Reason != "") {
+		c.Error(apperrors.NewValidationError("只有拒絕時可以填寫理由"))
+		return
+	}
+	code, reason, err := rejectionReason(req.RejectionRequest)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	user, err := utils.GetAuthenticatedUser(c)
+	if err != nil {
+		c.Error(apperrors.NewUnauthorizedError("未登入"))
+		return
+	}
+
+	result := BatchDecisionResult{Results: make([]ParticipantDecision, len(req.ParticipantIDs))}
+	err = database.GlobalDB.Conn.Transaction(func(tx *gorm.DB) error {
+		// 鎖定配對局，避免同時審核或參與時超過名額
+		var match models.Match
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&match, matchID).Error; err != nil {
+			return apperrors.MapGORMError(err)
+		}
+
+		var participants []models.MatchParticipant
+		if err := tx.Where("id IN ? AND match_id = ?", req.ParticipantIDs, matchID).Find(&participants).Error; err != nil {
+			return apperrors.MapGORMError(err)
+		}
+		byID := make(map[int64]models.MatchParticipant, len(participants))
+		for _, p := range participants {
+			byID[p.ID] = p
+		}
+
+		remaining := 0
+		if req.Action == "approve" {
+			capacity, err := matchCapacity(tx, &match)
+			if err != nil {
+				return apperrors.MapGORMError(err)
+			}
+			seated, err := seatedCount(tx, matchID)
+			if err != nil {
+				return apperrors.MapGORMError(err)
+			}
+			remaining = capacity - int(seated)
+		}
+
+		failed := false
+		var approveIDs, rejectIDs []int64
+		for i, id := range req.ParticipantIDs {
+			p, ok := byID[id]
+			decision := ParticipantDecision{ParticipantID: id, UserID: p.UserID, Status: p.Status}
+			switch {
+			case !ok:
+				decision.Result = DecisionNotFound
+			case containsStatus(models.AttendanceStatuses, p.Status):
+				decision.Result = DecisionAttendanceRecorded
+			case req.Action == "approve" && p.Status == "approved":
+				decision.Result = DecisionUnchanged
+			case req.Action == "approve" && !approvableStatus(p.Status):
+				// 被拒絕、移除或釋出名額的參與者不能重新審核通過
+				decision.Result = DecisionInvalidStatus
+			case req.Action == "approve":
+				// 等待重新確認的參與者已佔用名額
+				if p.Status != "reconfirm" {
+					if remaining <= 0 {
+						decision.Result = DecisionCapacityExceeded
+						break
+					}
+					remaining--
+				}
+				decision.Result = DecisionApproved
+				decision.Status = "approved"
+				approveIDs = append(approveIDs, id)
+			case p.Status == "rejected" && p.RejectionCode == code && p.RejectionReason == reason:
+				decision.Result = DecisionUnchanged
+			case !rejectableStatus(p.Status):
+				// 移除或釋出名額的參與者保留原本的狀態與理由
+				decision.Result = DecisionInvalidStatus
+			default:
+				decision.Result = DecisionRejected
+				decision.Status = "rejected"
+				rejectIDs = append(rejectIDs, id)
+			}
+			switch decision.Result {
+			case DecisionNotFound, DecisionAttendanceRecorded, DecisionCapacityExceeded, DecisionInvalidStatus:
+				failed = true
+			}
+			result.Results[i] = decision
+		}
+		if failed {
+			return errBatchRejected
+		}
+
+		if len(approveIDs) > 0 {
+			err := tx.Model(&models.MatchParticipant{}).Where("id IN ?", approveIDs).
+				Updates(map[string]interface{}{"status": "approved", "reconfirm_deadline": nil, "rejection_code": "", "rejection_reason": ""}).Error
+			if err != nil {
+				return apperrors.MapGORMError(err)
+			}
+		}
+		if len(rejectIDs) > 0 {
+			err := tx.Model(&models.MatchParticipant{}).Where("id IN ?", rejectIDs).
+				Updates(map[string]interface{}{"status": "rejected", "reconfirm_deadline": nil, "rejection_code": code, "rejection_reason": reason}).Error
+			if err != nil {
+				return apperrors.MapGORMError(err)
+			}
+		}
+
+		// 每位狀態有變更的參與者各記錄一筆
+		var events []models.MatchEvent
+		for _, id := range approveIDs {
+			p := byID[id]
+			events = append(events, models.NewParticipantEvent(&p, p.Status, "approved", user.ID, ""))
+		}
+		for _, id := range rejectIDs {
+			p := byID[id]
+			events = append(events, models.NewParticipantEvent(&p, p.Status, "rejected", user.ID, reason))
+		}
+		if len(events) > 0 {
+			if err := tx.Create(&events).Error; err != nil {
+				return apperrors.MapGORMError(err)
+			}
+		}
+		result.Applied = true
+		return nil
+	})
+	if errors.Is(err, errBatchRejected) {
+		c.JSON(http.StatusConflict, result)
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// rejectionReason 依理由代碼與自行填寫的說明決定要儲存的理由；只填說明時視為 other，選擇 other 時必須填寫說明
+func rejectionReason(req RejectionRequest) (string, string, error) {
+	switch {
+	case req.ReasonCode == "" && req.Reason == "":
+		return "", "", nil
+	case req.ReasonCode == "":
+		return "other", req.Reason, nil
+	case req.ReasonCode == "other" && req.Reason == "":
+		return "", "", apperrors.NewValidationError("選擇其他理由時需要填寫 reason")
+	case req.Reason != "":
+		return req.ReasonCode, req.Reason, nil
+	}
+	return req.ReasonCode, models.RejectionReasons[req.ReasonCode], nil
+}
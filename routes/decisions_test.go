@@ -0,0 +1,195 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"free2free/models"
+
+	apperrors "free2free/errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchDecideParticipants(t *testing.T) {
+	db := setupUserTestDatabase(t)
+
+	organizer := seedUser(t, db, "organizer")
+	// seedMatch 的活動 target_count 為 2
+	match := seedMatch(t, db, organizer.ID)
+	var pending []models.MatchParticipant
+	for _, name := range []string{"alice", "bob", "carol"} {
+		user := seedUser(t, db, name)
+		p := models.MatchParticipant{MatchID: match.ID, UserID: user.ID, Status: "pending", JoinedAt: time.Now()}
+		assert.NoError(t, db.Create(&p).Error)
+		pending = append(pending, p)
+	}
+
+	decide := func(body string) (int, BatchDecisionResult, *gin.Context) {
+		c, w := newUserContext("POST", "/", []byte(body), organizer.ID)
+		c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(match.ID)}}
+		batchDecideParticipants(c)
+		var result BatchDecisionResult
+		if len(c.Errors) == 0 {
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+		}
+		return w.Code, result, c
+	}
+	statusOf := func(id int64) models.MatchParticipant {
+		var p models.MatchParticipant
+		assert.NoError(t, db.First(&p, id).Error)
+		return p
+	}
+
+	// 超過名額時整批都不套用
+	code, result, _ := decide(fmt.Sprintf(`{"action": "approve", "participant_ids": [%d, %d, %d]}`, pending[0].ID, pending[1].ID, pending[2].ID))
+	assert.Equal(t, http.StatusConflict, code)
+	assert.False(t, result.Applied)
+	if assert.Len(t, result.Results, 3) {
+		assert.Equal(t, DecisionApproved, result.Results[0].Result)
+		assert.Equal(t, DecisionApproved, result.Results[1].Result)
+		assert.Equal(t, DecisionCapacityExceeded, result.Results[2].Result)
+	}
+	assert.Equal(t, "pending", statusOf(pending[0].ID).Status)
+
+	// 不屬於此配對局的參與者同樣讓整批失敗
+	code, result, _ = decide(fmt.Sprintf(`{"action": "approve", "participant_ids": [%d, 9999]}`, pending[0].ID))
+	assert.Equal(t, http.StatusConflict, code)
+	assert.Equal(t, DecisionNotFound, result.Results[1].Result)
+
+	code, result, _ = decide(fmt.Sprintf(`{"action": "approve", "participant_ids": [%d, %d]}`, pending[0].ID, pending[1].ID))
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, result.Applied)
+	assert.Equal(t, "approved", statusOf(pending[1].ID).Status)
+
+	// 拒絕理由可以選擇預設理由，顯示給申請者
+	code, result, _ = decide(fmt.Sprintf(`{"action": "reject", "participant_ids": [%d], "reason_code": "full"}`, pending[2].ID))
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, DecisionRejected, result.Results[0].Result)
+	rejected := statusOf(pending[2].ID)
+	assert.Equal(t, "rejected", rejected.Status)
+	assert.Equal(t, "full", rejected.RejectionCode)
+	assert.Equal(t, models.RejectionReasons["full"], rejected.RejectionReason)
+
+	// 自行填寫的理由視為 other
+	code, _, _ = decide(fmt.Sprintf(`{"action": "reject", "participant_ids": [%d], "reason": "這次想找同事"}`, pending[1].ID))
+	assert.Equal(t, http.StatusOK, code)
+	rejected = statusOf(pending[1].ID)
+	assert.Equal(t, "other", rejected.RejectionCode)
+	assert.Equal(t, "這次想找同事", rejected.RejectionReason)
+
+	// 審核通過時不能填寫理由，other 需要說明
+	_, _, c := decide(fmt.Sprintf(`{"action": "approve", "participant_ids": [%d], "reason_code": "full"}`, pending[1].ID))
+	assert.NotEmpty(t, c.Errors)
+	_, _, c = decide(fmt.Sprintf(`{"action": "reject", "participant_ids": [%d], "reason_code": "other"}`, pending[1].ID))
+	assert.NotEmpty(t, c.Errors)
+	_, _, c = decide(fmt.Sprintf(`{"action": "reject", "participant_ids": [%d, %d]}`, pending[1].ID, pending[1].ID))
+	assert.NotEmpty(t, c.Errors)
+
+	// 已拒絕的參與者不能重新審核通過
+	code, result, _ = decide(fmt.Sprintf(`{"action": "approve", "participant_ids": [%d]}`, pending[1].ID))
+	assert.Equal(t, http.StatusConflict, code)
+	assert.Equal(t, DecisionInvalidStatus, result.Results[0].Result)
+	assert.Equal(t, "rejected", statusOf(pending[1].ID).Status)
+}
+
+func TestApproveParticipantRespectsCapacity(t *testing.T) {
+	db := setupUserTestDatabase(t)
+
+	organizer := seedUser(t, db, "organizer")
+	// seedMatch 的活動 target_count 為 2
+	match := seedMatch(t, db, organizer.ID)
+	var pending []models.MatchParticipant
+	for _, name := range []string{"alice", "bob", "carol"} {
+		user := seedUser(t, db, name)
+		p := models.MatchParticipant{MatchID: match.ID, UserID: user.ID, Status: "pending", JoinedAt: time.Now()}
+		assert.NoError(t, db.Create(&p).Error)
+		pending = append(pending, p)
+	}
+
+	approve := func(id int64) *gin.Context {
+		c, _ := newUserContext("PUT", "/", nil, organizer.ID)
+		c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(match.ID)}, {Key: "participant_id", Value: fmt.Sprint(id)}}
+		approveParticipant(c)
+		return c
+	}
+
+	assert.Empty(t, approve(pending[0].ID).Errors)
+	assert.Empty(t, approve(pending[1].ID).Errors)
+	c := approve(pending[2].ID)
+	if appErr, ok := c.Errors.Last().Err.(*apperrors.AppError); assert.True(t, ok) {
+		assert.Equal(t, http.StatusConflict, appErr.Code)
+		assert.Equal(t, ErrCodeMatchFull, appErr.ErrorCode)
+	}
+
+	var p models.MatchParticipant
+	db.First(&p, pending[2].ID)
+	assert.Equal(t, "pending", p.Status)
+
+	// 等待重新確認的參與者已佔用名額，名額已滿時仍可以直接通過
+	assert.NoError(t, db.Model(&pending[1]).Update("status", "reconfirm").Error)
+	assert.Empty(t, approve(pending[1].ID).Errors)
+
+	// 已通過、被拒絕、移除或釋出名額的參與者不能再審核通過
+	for _, status := range []string{"approved", "rejected", "removed", "released"} {
+		assert.NoError(t, db.Model(&pending[0]).Update("status", status).Error)
+		c := approve(pending[0].ID)
+		if appErr, ok := c.Errors.Last().Err.(*apperrors.AppError); assert.True(t, ok, status) {
+			assert.Equal(t, http.StatusConflict, appErr.Code)
+			assert.Equal(t, ErrCodeInvalidParticipantStatus, appErr.ErrorCode)
+		}
+		var current models.MatchParticipant
+		db.First(&current, pending[0].ID)
+		assert.Equal(t, status, current.Status)
+	}
+}
+
+func TestRejectKeepsRemovedParticipants(t *testing.T) {
+	db := setupUserTestDatabase(t)
+
+	organizer := seedUser(t, db, "organizer")
+	match := seedMatch(t, db, organizer.ID)
+	removedUser := seedUser(t, db, "removed")
+	removed := models.MatchParticipant{MatchID: match.ID, UserID: removedUser.ID, Status: "removed", RejectionReason: "多次爽約", JoinedAt: time.Now()}
+	released := models.MatchParticipant{MatchID: match.ID, UserID: seedUser(t, db, "released").ID, Status: "released", JoinedAt: time.Now()}
+	assert.NoError(t, db.Create(&removed).Error)
+	assert.NoError(t, db.Create(&released).Error)
+
+	for _, p := range []models.MatchParticipant{removed, released} {
+		c, _ := newUserContext("PUT", "/", []byte(`{"reason_code": "full"}`), organizer.ID)
+		c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(match.ID)}, {Key: "participant_id", Value: fmt.Sprint(p.ID)}}
+		rejectParticipant(c)
+		if assert.Len(t, c.Errors, 1) {
+			appErr := c.Errors.Last().Err.(*apperrors.AppError)
+			assert.Equal(t, http.StatusConflict, appErr.Code)
+			assert.Equal(t, ErrCodeInvalidParticipantStatus, appErr.ErrorCode)
+		}
+	}
+
+	c, w := newUserContext("POST", "/", []byte(fmt.Sprintf(`{"action": "reject", "participant_ids": [%d]}`, removed.ID)), organizer.ID)
+	c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(match.ID)}}
+	batchDecideParticipants(c)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	var result BatchDecisionResult
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	if assert.Len(t, result.Results, 1) {
+		assert.Equal(t, DecisionInvalidStatus, result.Results[0].Result)
+	}
+
+	// 移除的狀態與理由保留，使用者重新參與時仍被擋下
+	var current models.MatchParticipant
+	assert.NoError(t, db.First(&current, removed.ID).Error)
+	assert.Equal(t, "removed", current.Status)
+	assert.Equal(t, "多次爽約", current.RejectionReason)
+
+	c, _ = newUserContext("POST", "/", nil, removedUser.ID)
+	c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(match.ID)}}
+	joinMatch(c)
+	if assert.Len(t, c.Errors, 1) {
+		assert.Equal(t, ErrCodeRemovedFromMatch, c.Errors.Last().Err.(*apperrors.AppError).ErrorCode)
+	}
+}
@@ -0,0 +1,129 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"free2free/database"
+	"free2free/models"
+	"free2free/utils"
+
+	apperrors "free2free/errors"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// matchEventListSpec 狀態變更紀錄以 ID 排序，預設由新到舊
+var matchEventListSpec = ListSpec[models.MatchEvent]{
+	IDColumn: "match_events.id",
+	ID:       func(e models.MatchEvent) int64 { return e.ID },
+	Sorts: map[string]SortField[models.MatchEvent]{
+		"id": {Column: "match_events.id", Kind: sortInt, Value: func(e models.MatchEvent) interface{} { return e.ID }},
+	},
+	DefaultSort: "-id",
+}
+
+// recordEvent 在變更狀態的交易中寫入狀態變更紀錄
+func recordEvent(tx *gorm.DB, event models.MatchEvent) error {
+	if err := tx.Create(&event).Error; err != nil {
+		return apperrors.MapGORMError(err)
+	}
+	return nil
+}
+
+// getMatchTimeline 取得配對局的狀態變更時間軸
+// @Summary 配對局時間軸
+// @Description 依時間順序列出配對局的狀態變更紀錄。開局者與共同開局者可以看到所有參與者的紀錄，參與者只看到配對局本身與自己參與狀態的紀錄；actor_id 為 null 表示由系統自動變更
+// @Tags 使用者
+// @Produce json
+// @Param id path int true "配對局ID"
+// @Success 200 {array} MatchEvent
+// @Failure 400 {object} map[string]string "無效的配對局 ID"
+// @Failure 403 {object} map[string]string "沒有參與此配對局"
+// @Failure 404 {object} map[string]string "配對局不存在"
+// @Router /user/matches/{id}/timeline [get]
+// @Security ApiKeyAuth
+func getMatchTimeline(c *gin.Context) {
+	matchID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || matchID <= 0 {
+		c.Error(apperrors.NewValidationError("無效的配對局 ID"))
+		return
+	}
+
+	user, err := utils.GetAuthenticatedUser(c)
+	if err != nil {
+		c.Error(apperrors.NewUnauthorizedError("未登入"))
+		return
+	}
+
+	db := database.GlobalDB.Conn
+	var match models.Match
+	if err := db.First(&match, matchID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Error(apperrors.NewAppError(http.StatusNotFound, "配對局不存在"))
+			return
+		}
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+
+	query := db.Where("match_id = ?", match.ID)
+	if !isMatchOrganizer(c, match.ID) {
+		var joined int64
+		if err := db.Model(&models.MatchParticipant{}).Where("match_id = ? AND user_id = ?", match.ID, user.ID).Count(&joined).Error; err != nil {
+			c.Error(apperrors.MapGORMError(err))
+			return
+		}
+		if joined == 0 {
+			c.Error(apperrors.NewForbiddenError("只有開局者與參與者可以查看時間軸"))
+			return
+		}
+		query = query.Where("participant_id IS NULL OR user_id = ?", user.ID)
+	}
+
+	events := []models.MatchEvent{}
+	if err := query.Order("id ASC").Find(&events).Error; err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+	c.JSON(http.StatusOK, events)
+}
+
+// listMatchEvents 取得所有狀態變更紀錄
+// @Summary 取得狀態變更紀錄
+// @Description 管理員查看所有配對局與參與者的狀態變更紀錄，用於處理申訴，支援游標分頁
+// @Tags 管理員
+// @Produce json
+// @Param match_id query int false "配對局ID"
+// @Param user_id query int false "狀態變更的參與者ID"
+// @Param actor_id query int false "執行變更的使用者ID"
+// @Param limit query int false "每頁筆數 (1-100)，預設 20"
+// @Param cursor query string false "上一頁回傳的 X-Next-Cursor"
+// @Success 200 {array} MatchEvent
+// @Header 200 {integer} X-Total-Count "符合條件的總筆數"
+// @Header 200 {string} X-Next-Cursor "下一頁游標，沒有下一頁時為空"
+// @Failure 400 {object} map[string]string "無效的查詢參數"
+// @Router /admin/events [get]
+// @Security ApiKeyAuth
+func listMatchEvents(c *gin.Context) {
+	query := database.GlobalDB.Conn.Model(&models.MatchEvent{})
+	for _, column := range []string{"match_id", "user_id", "actor_id"} {
+		value, ok, err := queryInt64(c, column)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		if ok {
+			query = query.Where("match_events."+column+" = ?", value)
+		}
+	}
+
+	events, err := Paginate(c, query, matchEventListSpec)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, events)
+}
@@ -0,0 +1,87 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"free2free/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchTimelineRecordsTransitions(t *testing.T) {
+	db := setupUserTestDatabase(t)
+
+	organizer := seedUser(t, db, "organizer")
+	alice := seedUser(t, db, "alice")
+	bob := seedUser(t, db, "bob")
+	outsider := seedUser(t, db, "outsider")
+	match := seedMatch(t, db, organizer.ID)
+
+	participantIDs := map[int64]int64{}
+	for _, user := range []models.User{alice, bob} {
+		c, w := newUserContext("POST", "/", nil, user.ID)
+		c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(match.ID)}}
+		joinMatch(c)
+		assert.Empty(t, c.Errors)
+		var participant models.MatchParticipant
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &participant))
+		participantIDs[user.ID] = participant.ID
+	}
+
+	c, _ := newUserContext("PUT", "/", nil, organizer.ID)
+	c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(match.ID)}, {Key: "participant_id", Value: fmt.Sprint(participantIDs[alice.ID])}}
+	approveParticipant(c)
+	assert.Empty(t, c.Errors)
+
+	c, _ = newUserContext("PUT", "/", []byte(`{"reason_code": "full"}`), organizer.ID)
+	c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(match.ID)}, {Key: "participant_id", Value: fmt.Sprint(participantIDs[bob.ID])}}
+	rejectParticipant(c)
+	assert.Empty(t, c.Errors)
+
+	timeline := func(userID int64) (*gin.Context, []models.MatchEvent) {
+		c, w := newUserContext("GET", "/", nil, userID)
+		c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(match.ID)}}
+		getMatchTimeline(c)
+		var events []models.MatchEvent
+		if len(c.Errors) == 0 {
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &events))
+		}
+		return c, events
+	}
+
+	// 開局者看到所有參與者的紀錄
+	_, events := timeline(organizer.ID)
+	assert.Len(t, events, 4)
+
+	// 參與者只看到自己的紀錄
+	_, events = timeline(alice.ID)
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, "", events[0].FromStatus)
+		assert.Equal(t, "pending", events[0].ToStatus)
+		assert.Equal(t, "pending", events[1].FromStatus)
+		assert.Equal(t, "approved", events[1].ToStatus)
+		if assert.NotNil(t, events[1].ActorID) {
+			assert.Equal(t, organizer.ID, *events[1].ActorID)
+		}
+	}
+
+	c, _ = timeline(outsider.ID)
+	assert.NotEmpty(t, c.Errors)
+
+	// 管理員可以依參與者篩選完整紀錄，拒絕理由一併保留
+	c, w := newUserContext("GET", fmt.Sprintf("/admin/events?user_id=%d", bob.ID), nil, organizer.ID)
+	listMatchEvents(c)
+	assert.Empty(t, c.Errors)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("X-Total-Count"))
+	var logged []models.MatchEvent
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &logged))
+	if assert.Len(t, logged, 2) {
+		assert.Equal(t, "rejected", logged[0].ToStatus)
+		assert.Equal(t, models.RejectionReasons["full"], logged[0].Reason)
+	}
+}
@@ -0,0 +1,435 @@
+package routes
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"free2free/database"
+	"free2free/models"
+	"free2free/utils"
+
+	apperrors "free2free/errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 邀請連結無法使用時回傳的錯誤代碼
+const (
+	ErrCodeInviteInvalid   = "invite_invalid"
+	ErrCodeInviteExpired   = "invite_expired"
+	ErrCodeInviteRevoked   = "invite_revoked"
+	ErrCodeInviteExhausted = "invite_exhausted"
+)
+
+// inviteCodeAlphabet 邀請碼使用 Crockford Base32 字元，去除容易與數字混淆的 I/L/O/U
+// 長度為 32 可整除 256，隨機位元組取餘數時不會有偏差
+const inviteCodeAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+const inviteCodeLength = 8
+
+// InviteRequest 建立邀請連結的請求
+type InviteRequest struct {
+	MaxUses   int        `json:"max_uses" validate:"omitempty,min=1,max=100"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// InviteLink 邀請連結與其簽章後的 token
+type InviteLink struct {
+	models.MatchInvite
+	Token string `json:"token"`
+	URL   string `json:"url"`
+}
+
+// InvitePreview 開啟邀請連結時顯示的配對局資訊
+type InvitePreview struct {
+	Match         models.Match `json:"match"`
+	ExpiresAt     time.Time    `json:"expires_at"`
+	RemainingUses *int         `json:"remaining_uses"`
+}
+
+// createInvite 建立邀請連結
+// @Summary 建立邀請連結
+// @Description 開局者為配對局建立簽章過的邀請連結，可設定使用次數與到期時間；到期時間預設且最晚為配對開始時間
+// @Tags 開局者
+// @Accept json
+// @Produce json
+// @Param id path int true "配對局ID"
+// @Param invite body InviteRequest false "邀請設定"
+// @Success 201 {object} InviteLink
+// @Failure 400 {object} map[string]string "無效的請求資料或配對局已關閉"
+// @Failure 403 {object} map[string]string "需要開局者權限"
+// @Failure 500 {object} map[string]string "無法建立邀請連結"
+// @Router /organizer/matches/{id}/invites [post]
+// @Security ApiKeyAuth
+func createInvite(c *gin.Context) {
+	matchID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || matchID <= 0 {
+		c.Error(apperrors.NewValidationError("無效的配對局 ID"))
+		return
+	}
+
+	var req InviteRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.Error(apperrors.NewValidationError("無效的請求資料"))
+			return
+		}
+	}
+
+	v := validator.New()
+	if err := v.Struct(&req); err != nil {
+		c.Error(apperrors.NewValidationError(err.Error()))
+		return
+	}
+
+	user, err := utils.GetAuthenticatedUser(c)
+	if err != nil {
+		c.Error(apperrors.NewUnauthorizedError("未登入"))
+		return
+	}
+
+	var match models.Match
+	if err := database.GlobalDB.Conn.Where("id = ? AND status = ? AND match_time > ?", matchID, "open", time.Now()).First(&match).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Error(apperrors.NewValidationError("指定的配對局不存在或已關閉"))
+			return
+		}
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+
+	// 配對開始後邀請即無意義，因此到期時間最晚為配對開始時間
+	expiresAt := match.MatchTime
+	if req.ExpiresAt != nil {
+		if !req.ExpiresAt.After(time.Now()) {
+			c.Error(apperrors.NewValidationError("到期時間必須在未來"))
+			return
+		}
+		if req.ExpiresAt.Before(expiresAt) {
+			expiresAt = *req.ExpiresAt
+		}
+	}
+
+	code, err := generateInviteCode()
+	if err != nil {
+		c.Error(apperrors.NewAppError(http.StatusInternalServerError, "無法產生邀請碼"))
+		return
+	}
+
+	invite := models.MatchInvite{
+		MatchID:   match.ID,
+		CreatedBy: user.ID,
+		Code:      code,
+		MaxUses:   req.MaxUses,
+		ExpiresAt: expiresAt,
+	}
+	if err := database.GlobalDB.Conn.Create(&invite).Error; err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, newInviteLink(invite))
+}
+
+// listInvites 取得配對局的邀請連結
+// @Summary 取得邀請連結列表
+// @Description 開局者取得配對局的所有邀請連結 (包含已撤銷與已到期的)
+// @Tags 開局者
+// @Produce json
+// @Param id path int true "配對局ID"
+// @Success 200 {array} InviteLink
+// @Failure 400 {object} map[string]string "無效的配對局 ID"
+// @Failure 403 {object} map[string]string "需要開局者權限"
+// @Failure 500 {object} map[string]string "無法取得邀請連結"
+// @Router /organizer/matches/{id}/invites [get]
+// @Security ApiKeyAuth
+func listInvites(c *gin.Context) {
+	matchID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || matchID <= 0 {
+		c.Error(apperrors.NewValidationError("無效的配對局 ID"))
+		return
+	}
+
+	var invites []models.MatchInvite
+	if err := database.GlobalDB.Conn.Where("match_id = ?", matchID).Order("id DESC").Find(&invites).Error; err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+
+	links := make([]InviteLink, len(invites))
+	for i, invite := range invites {
+		links[i] = newInviteLink(invite)
+	}
+	c.JSON(http.StatusOK, links)
+}
+
+// revokeInvite 撤銷邀請連結
+// @Summary 撤銷邀請連結
+// @Description 開局者撤銷邀請連結，已透過此連結加入的參與者不受影響
+// @Tags 開局者
+// @Produce json
+// @Param id path int true "配對局ID"
+// @Param invite_id path int true "邀請ID"
+// @Success 200 {object} InviteLink
+// @Failure 400 {object} map[string]string "無效的配對局 ID 或邀請 ID"
+// @Failure 403 {object} map[string]string "需要開局者權限"
+// @Failure 404 {object} map[string]string "邀請不存在"
+// @Router /organizer/matches/{id}/invites/{invite_id} [delete]
+// @Security ApiKeyAuth
+func revokeInvite(c *gin.Context) {
+	matchID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || matchID <= 0 {
+		c.Error(apperrors.NewValidationError("無效的配對局 ID"))
+		return
+	}
+	inviteID, err := strconv.ParseInt(c.Param("invite_id"), 10, 64)
+	if err != nil || inviteID <= 0 {
+		c.Error(apperrors.NewValidationError("無效的邀請 ID"))
+		return
+	}
+
+	var invite models.MatchInvite
+	if err := database.GlobalDB.Conn.Where("id = ? AND match_id = ?", inviteID, matchID).First(&invite).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Error(apperrors.NewAppError(http.StatusNotFound, "邀請不存在"))
+			return
+		}
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+
+	if invite.RevokedAt == nil {
+		now := time.Now()
+		if err := database.GlobalDB.Conn.Model(&invite).Update("revoked_at", now).Error; err != nil {
+			c.Error(apperrors.MapGORMError(err))
+			return
+		}
+		invite.RevokedAt = &now
+	}
+
+	c.JSON(http.StatusOK, newInviteLink(invite))
+}
+
+// getInvite 預覽邀請連結
+// @Summary 預覽邀請連結
+// @Description 登入後開啟邀請連結時顯示配對局資訊，確認後再呼叫接受邀請
+// @Tags 使用者
+// @Produce json
+// @Param token path string true "邀請 token"
+// @Success 200 {object} InvitePreview
+// @Failure 404 {object} map[string]string "邀請連結無效 (error_code: invite_invalid)"
+// @Failure 410 {object} map[string]string "邀請連結已到期、已撤銷或已用完"
+// @Router /user/invites/{token} [get]
+// @Security ApiKeyAuth
+func getInvite(c *gin.Context) {
+	invite, err := findInvite(database.GlobalDB.Conn, c.Param("token"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if err := inviteUnusableError(invite); err != nil {
+		c.Error(err)
+		return
+	}
+
+	var match models.Match
+	if err := database.GlobalDB.Conn.Preload("Activity.Location").Preload("Organizer").First(&match, invite.MatchID).Error; err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+
+	preview := InvitePreview{Match: match, ExpiresAt: invite.ExpiresAt}
+	if invite.MaxUses > 0 {
+		remaining := invite.MaxUses - invite.UseCount
+		preview.RemainingUses = &remaining
+	}
+	c.JSON(http.StatusOK, preview)
+}
+
+// acceptInvite 透過邀請連結參與配對
+// @Summary 接受邀請
+// @Description 透過邀請連結直接以 approved 狀態參與配對局；已在等待審核中的參與記錄會直接通過
+// @Tags 使用者
+// @Produce json
+// @Param token path string true "邀請 token"
+// @Success 201 {object} MatchParticipant
+// @Failure 400 {object} map[string]string "配對局已關閉或開局者、共同開局者不能接受自己的邀請"
+// @Failure 403 {object} map[string]string "被開局者封鎖 (error_code: blocked_by_organizer)"
+// @Failure 404 {object} map[string]string "邀請連結無效 (error_code: invite_invalid)"
+// @Failure 409 {object} map[string]string "已參與此配對局 (error_code: already_joined) 或名額已滿 (error_code: match_full)"
+// @Failure 410 {object} map[string]string "邀請連結已到期、已撤銷或已用完"
+// @Failure 429 {object} map[string]string "超過參與配額 (error_code: quota_exceeded)"
+// @Router /user/invites/{token}/accept [post]
+// @Security ApiKeyAuth
+func acceptInvite(c *gin.Context) {
+	user, err := utils.GetAuthenticatedUser(c)
+	if err != nil {
+		c.Error(apperrors.NewUnauthorizedError("未登入"))
+		return
+	}
+
+	var participant models.MatchParticipant
+	err = database.GlobalDB.Conn.Transaction(func(tx *gorm.DB) error {
+		invite, err := findInvite(tx, c.Param("token"))
+		if err != nil {
+			return err
+		}
+
+		// 先鎖定配對局，計算名額時不會與其他加入或審核的請求重疊
+		now := time.Now()
+		var match models.Match
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&match, invite.MatchID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return apperrors.NewValidationError("指定的配對局不存在或已關閉")
+			}
+			return apperrors.MapGORMError(err)
+		}
+		var coOrganizer int64
+		if err := tx.Model(&models.MatchCoOrganizer{}).Where("match_id = ? AND user_id = ?", match.ID, user.ID).Count(&coOrganizer).Error; err != nil {
+			return apperrors.MapGORMError(err)
+		}
+		if invite.CreatedBy == user.ID || match.OrganizerID == user.ID || coOrganizer > 0 {
+			return apperrors.NewValidationError("開局者不能接受自己的邀請")
+		}
+
+		// 以條件式更新佔用一次使用次數，同時送出的請求不會超過上限
+		result := tx.Model(&models.MatchInvite{}).
+			Where("id = ? AND revoked_at IS NULL AND expires_at > ?", invite.ID, now).
+			Where("max_uses = 0 OR use_count < max_uses").
+			Update("use_count", gorm.Expr("use_count + 1"))
+		if result.Error != nil {
+			return apperrors.MapGORMError(result.Error)
+		}
+		if result.RowsAffected == 0 {
+			if err := inviteUnusableError(invite); err != nil {
+				return err
+			}
+			return apperrors.NewCodedError(http.StatusGone, ErrCodeInviteExhausted, "邀請連結已達使用次數上限")
+		}
+
+		// 已在等待審核的使用者直接通過
+		err = tx.Where("match_id = ? AND user_id = ? AND status = ?", invite.MatchID, user.ID, "pending").First(&participant).Error
+		if err == nil {
+			if match.Status != "open" || !match.MatchTime.After(now) {
+				return apperrors.NewValidationError("指定的配對局不存在或已關閉")
+			}
+			if err := checkOrganizerBlock(tx, match.OrganizerID, user.ID); err != nil {
+				return err
+			}
+			if err := checkSeatAvailable(tx, &match); err != nil {
+				return err
+			}
+			if err := tx.Model(&participant).Update("status", "approved").Error; err != nil {
+				return apperrors.MapGORMError(err)
+			}
+			participant.Status = "approved"
+			return recordEvent(tx, models.NewParticipantEvent(&participant, "pending", "approved", user.ID, "透過邀請連結加入"))
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperrors.MapGORMError(err)
+		}
+
+		// 等待審核的申請在送出時已計入配額，新加入的使用者才需要檢查
+		if err := checkJoinQuota(tx, user.ID); err != nil {
+			return err
+		}
+		// 與參與配對局相同，先建立參與記錄再確認名額後通過
+		participant = models.MatchParticipant{
+			MatchID:  invite.MatchID,
+			UserID:   user.ID,
+			Status:   "pending",
+			JoinedAt: now,
+		}
+		if err := createParticipant(tx, &participant); err != nil {
+			return err
+		}
+		if err := checkSeatAvailable(tx, &match); err != nil {
+			return err
+		}
+		if err := tx.Model(&participant).Update("status", "approved").Error; err != nil {
+			return apperrors.MapGORMError(err)
+		}
+		participant.Status = "approved"
+		return recordEvent(tx, models.NewParticipantEvent(&participant, "", "approved", user.ID, "透過邀請連結加入"))
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	database.GlobalDB.Conn.Preload("Match").Preload("User").First(&participant, participant.ID)
+	c.JSON(http.StatusCreated, participant)
+}
+
+// findInvite 驗證 token 的簽章並讀取對應的邀請
+func findInvite(db *gorm.DB, token string) (*models.MatchInvite, error) {
+	invalid := apperrors.NewCodedError(http.StatusNotFound, ErrCodeInviteInvalid, "邀請連結無效")
+
+	code, signature, ok := strings.Cut(token, ".")
+	if !ok || len(code) != inviteCodeLength || !hmac.Equal([]byte(signature), []byte(signInviteCode(code))) {
+		return nil, invalid
+	}
+
+	var invite models.MatchInvite
+	if err := db.Where("code = ?", code).First(&invite).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, invalid
+		}
+		return nil, apperrors.MapGORMError(err)
+	}
+	return &invite, nil
+}
+
+// inviteUnusableError 回傳邀請無法使用的原因，可以使用時回傳 nil
+func inviteUnusableError(invite *models.MatchInvite) error {
+	switch {
+	case invite.RevokedAt != nil:
+		return apperrors.NewCodedError(http.StatusGone, ErrCodeInviteRevoked, "邀請連結已被撤銷")
+	case !invite.ExpiresAt.After(time.Now()):
+		return apperrors.NewCodedError(http.StatusGone, ErrCodeInviteExpired, "邀請連結已到期")
+	case invite.MaxUses > 0 && invite.UseCount >= invite.MaxUses:
+		return apperrors.NewCodedError(http.StatusGone, ErrCodeInviteExhausted, "邀請連結已達使用次數上限")
+	}
+	return nil
+}
+
+// newInviteLink 組合邀請的 token 與分享用的網址，網址指向預覽邀請的 GET /user/invites/{token}
+func newInviteLink(invite models.MatchInvite) InviteLink {
+	token := invite.Code + "." + signInviteCode(invite.Code)
+	return InviteLink{
+		MatchInvite: invite,
+		Token:       token,
+		URL:         fmt.Sprintf("%s/user/invites/%s", os.Getenv("BASE_URL"), token),
+	}
+}
+
+// signInviteCode 以 JWT_SECRET 對邀請碼做 HMAC 簽章，避免邀請碼被猜測
+func signInviteCode(code string) string {
+	mac := hmac.New(sha256.New, []byte(os.Getenv("JWT_SECRET")))
+	mac.Write([]byte("match-invite:" + code))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:12])
+}
+
+// generateInviteCode 產生隨機邀請碼
+func generateInviteCode() (string, error) {
+	buf := make([]byte, inviteCodeLength)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	for i, b := range buf {
+		buf[i] = inviteCodeAlphabet[int(b)%len(inviteCodeAlphabet)]
+	}
+	return string(buf), nil
+}
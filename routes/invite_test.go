@@ -0,0 +1,184 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"free2free/models"
+
+	apperrors "free2free/errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/sessions"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInviteLinks(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-key-for-invite-signatures")
+	db := setupUserTestDatabase(t)
+
+	organizer := seedUser(t, db, "organizer")
+	friend := seedUser(t, db, "friend")
+	waiting := seedUser(t, db, "waiting")
+	latecomer := seedUser(t, db, "latecomer")
+	match := seedMatch(t, db, organizer.ID)
+	matchParam := gin.Param{Key: "id", Value: fmt.Sprint(match.ID)}
+
+	newInvite := func(body string) InviteLink {
+		c, w := newUserContext("POST", "/", []byte(body), organizer.ID)
+		c.Params = gin.Params{matchParam}
+		createInvite(c)
+		assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var link InviteLink
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &link))
+		return link
+	}
+	accept := func(token string, userID int64) (*apperrors.AppError, models.MatchParticipant) {
+		c, w := newUserContext("POST", "/", nil, userID)
+		c.Params = gin.Params{{Key: "token", Value: token}}
+		acceptInvite(c)
+		var participant models.MatchParticipant
+		if len(c.Errors) > 0 {
+			appErr, _ := c.Errors.Last().Err.(*apperrors.AppError)
+			return appErr, participant
+		}
+		json.Unmarshal(w.Body.Bytes(), &participant)
+		return nil, participant
+	}
+
+	single := newInvite(`{"max_uses": 1}`)
+	// 未指定到期時間時，以配對開始時間為期限
+	assert.WithinDuration(t, match.MatchTime, single.ExpiresAt, time.Second)
+
+	appErr, participant := accept(single.Token, friend.ID)
+	assert.Nil(t, appErr)
+	assert.Equal(t, "approved", participant.Status)
+
+	appErr, _ = accept(single.Token, latecomer.ID)
+	if assert.NotNil(t, appErr) {
+		assert.Equal(t, http.StatusGone, appErr.Code)
+		assert.Equal(t, ErrCodeInviteExhausted, appErr.ErrorCode)
+	}
+
+	// 已在等待審核的使用者透過邀請直接通過
+	assert.NoError(t, db.Create(&models.MatchParticipant{MatchID: match.ID, UserID: waiting.ID, Status: "pending", JoinedAt: time.Now()}).Error)
+	open := newInvite("")
+	appErr, participant = accept(open.Token, waiting.ID)
+	assert.Nil(t, appErr)
+	assert.Equal(t, "approved", participant.Status)
+
+	// 已參與的使用者不能再次使用邀請
+	appErr, _ = accept(open.Token, friend.ID)
+	if assert.NotNil(t, appErr) {
+		assert.Equal(t, ErrCodeAlreadyJoined, appErr.ErrorCode)
+	}
+
+	// 名額已滿時無法透過邀請加入
+	appErr, _ = accept(open.Token, latecomer.ID)
+	if assert.NotNil(t, appErr) {
+		assert.Equal(t, http.StatusConflict, appErr.Code)
+		assert.Equal(t, ErrCodeMatchFull, appErr.ErrorCode)
+	}
+
+	// 開局者與共同開局者都不能接受邀請
+	coOrganizer := seedUser(t, db, "co-organizer")
+	assert.NoError(t, db.Create(&models.MatchCoOrganizer{MatchID: match.ID, UserID: coOrganizer.ID, AddedBy: organizer.ID}).Error)
+	for _, userID := range []int64{organizer.ID, coOrganizer.ID} {
+		appErr, _ = accept(open.Token, userID)
+		if assert.NotNil(t, appErr) {
+			assert.Equal(t, http.StatusBadRequest, appErr.Code)
+		}
+	}
+
+	// 竄改過的 token 無效
+	appErr, _ = accept(open.Token[:len(open.Token)-1]+"x", latecomer.ID)
+	if assert.NotNil(t, appErr) {
+		assert.Equal(t, ErrCodeInviteInvalid, appErr.ErrorCode)
+	}
+
+	// 撤銷後無法使用
+	c, w := newUserContext("DELETE", "/", nil, organizer.ID)
+	c.Params = gin.Params{matchParam, {Key: "invite_id", Value: fmt.Sprint(open.ID)}}
+	revokeInvite(c)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	appErr, _ = accept(open.Token, latecomer.ID)
+	if assert.NotNil(t, appErr) {
+		assert.Equal(t, ErrCodeInviteRevoked, appErr.ErrorCode)
+	}
+
+	var invite models.MatchInvite
+	db.First(&invite, open.ID)
+	assert.Equal(t, 1, invite.UseCount, "失敗的使用不會佔用次數")
+}
+
+func TestInviteLinkResolvesToRoute(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-key-for-invite-signatures")
+	t.Setenv("BASE_URL", "http://api.example.com")
+	db := setupUserTestDatabase(t)
+
+	organizer := seedUser(t, db, "organizer")
+	friend := seedUser(t, db, "friend")
+	match := seedMatch(t, db, organizer.ID)
+
+	c, w := newUserContext("POST", "/", nil, organizer.ID)
+	c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(match.ID)}}
+	createInvite(c)
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var link InviteLink
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &link))
+
+	u, err := url.Parse(link.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, "api.example.com", u.Host)
+
+	// 以路由實際開啟分享的網址，應取得邀請預覽
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		session := sessions.NewSession(nil, "free2free-session")
+		session.Values["user_id"] = friend.ID
+		c.Set("session", session)
+	})
+	SetupUserRoutes(r)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", u.RequestURI(), nil))
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var preview InvitePreview
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &preview))
+	assert.Equal(t, match.ID, preview.Match.ID)
+}
+
+func TestInviteRespectsOrganizerBlock(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-key-for-invite-signatures")
+	db := setupUserTestDatabase(t)
+
+	organizer := seedUser(t, db, "organizer")
+	blocked := seedUser(t, db, "blocked")
+	match := seedMatch(t, db, organizer.ID)
+
+	c, w := newUserContext("POST", "/", []byte(`{}`), organizer.ID)
+	c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(match.ID)}}
+	createInvite(c)
+	var link InviteLink
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &link))
+
+	// 申請後才被開局者封鎖的使用者，不能透過邀請直接通過
+	assert.NoError(t, db.Create(&models.MatchParticipant{MatchID: match.ID, UserID: blocked.ID, Status: "pending", JoinedAt: time.Now()}).Error)
+	assert.NoError(t, db.Create(&models.OrganizerBlock{OrganizerID: organizer.ID, UserID: blocked.ID}).Error)
+
+	c, _ = newUserContext("POST", "/", nil, blocked.ID)
+	c.Params = gin.Params{{Key: "token", Value: link.Token}}
+	acceptInvite(c)
+	if appErr, ok := c.Errors.Last().Err.(*apperrors.AppError); assert.True(t, ok) {
+		assert.Equal(t, ErrCodeBlockedByOrganizer, appErr.ErrorCode)
+	}
+
+	var participant models.MatchParticipant
+	db.Where("match_id = ? AND user_id = ?", match.ID, blocked.ID).First(&participant)
+	assert.Equal(t, "pending", participant.Status)
+}
@@ -0,0 +1,148 @@
+package routes
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"free2free/database"
+	"free2free/geo"
+	"free2free/models"
+
+	apperrors "free2free/errors"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultNearbyRadiusKm = 5.0
+	maxNearbyRadiusKm     = 50.0
+	// maxNearbyCandidates 預篩後依近似距離排序，最多載入的配對局數量，避免熱門區域一次載入過多資料
+	maxNearbyCandidates = 500
+)
+
+// NearbyMatch 附近的配對局與距離
+type NearbyMatch struct {
+	models.Match
+	DistanceKm float64 `json:"distance_km"`
+}
+
+// listNearbyMatches 取得附近的配對列表
+// @Summary 取得附近的配對列表
+// @Description 取得指定座標半徑內尚未結束且狀態為open的配對列表，依距離由近到遠排序
+// @Tags 使用者
+// @Accept json
+// @Produce json
+// @Param lat query number true "緯度"
+// @Param lng query number true "經度"
+// @Param radius_km query number false "搜尋半徑 (公里)，預設 5，最多 50"
+// @Param limit query int false "回傳筆數 (1-100)，預設 20"
+// @Success 200 {array} NearbyMatch
+// @Failure 400 {object} map[string]string "無效的查詢參數"
+// @Failure 500 {object} map[string]string "無法取得附近的配對列表"
+// @Router /user/matches/nearby [get]
+// @Security ApiKeyAuth
+func listNearbyMatches(c *gin.Context) {
+	lat, err := queryCoordinate(c, "lat", 90)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	lng, err := queryCoordinate(c, "lng", 180)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	radius := defaultNearbyRadiusKm
+	if raw := c.Query("radius_km"); raw != "" {
+		radius, err = strconv.ParseFloat(raw, 64)
+		if err != nil || radius <= 0 || radius > maxNearbyRadiusKm {
+			c.Error(apperrors.NewCodedError(http.StatusBadRequest, ErrCodeInvalidFilter, fmt.Sprintf("radius_km 必須介於 0 到 %.0f 之間", maxNearbyRadiusKm)))
+			return
+		}
+	}
+
+	limit, err := parseLimit(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	// 以 geohash 前綴與經緯度範圍預篩，並在資料庫中依近似距離排序後才限制筆數；
+	// 精確的球面距離在程式中計算，以同時支援 MySQL 與 SQLite
+	cells := geo.CoverCells(lat, lng, radius)
+	cellConds := make([]string, len(cells))
+	cellArgs := make([]interface{}, len(cells))
+	for i, cell := range cells {
+		cellConds[i] = "locations.geohash LIKE ?"
+		cellArgs[i] = cell + "%"
+	}
+
+	box := geo.BoundingBox(lat, lng, radius)
+	query := database.GlobalDB.Conn.Model(&models.Match{}).
+		Joins("JOIN activities ON activities.id = matches.activity_id").
+		Joins("JOIN locations ON locations.id = activities.location_id").
+		Where("matches.status = ? AND matches.end_time > ?", "open", time.Now()).
+		Where("("+strings.Join(cellConds, " OR ")+")", cellArgs...).
+		Where("locations.latitude BETWEEN ? AND ?", box.MinLat, box.MaxLat)
+	if box.CrossesAntimeridian() {
+		query = query.Where("(locations.longitude >= ? OR locations.longitude <= ?)", box.MinLng, box.MaxLng)
+	} else {
+		query = query.Where("locations.longitude BETWEEN ? AND ?", box.MinLng, box.MaxLng)
+	}
+
+	var matches []models.Match
+	if err := query.Preload("Activity.Location").Preload("Organizer").
+		Order(nearbyDistanceOrder(lat, lng)).
+		Limit(maxNearbyCandidates).
+		Find(&matches).Error; err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+
+	results := []NearbyMatch{}
+	for _, match := range matches {
+		location := match.Activity.Location
+		distance := geo.Haversine(lat, lng, location.Latitude, location.Longitude)
+		if distance <= radius {
+			results = append(results, NearbyMatch{Match: match, DistanceKm: distance})
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].DistanceKm < results[j].DistanceKm
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	c.JSON(http.StatusOK, results)
+}
+
+// queryCoordinate 解析必填的經緯度參數
+func queryCoordinate(c *gin.Context, name string, bound float64) (float64, error) {
+	v, err := strconv.ParseFloat(c.Query(name), 64)
+	if err != nil || v < -bound || v > bound {
+		return 0, apperrors.NewCodedError(http.StatusBadRequest, ErrCodeInvalidFilter, fmt.Sprintf("無效的 %s 參數", name))
+	}
+	return v, nil
+}
+
+// nearbyDistanceOrder 依等距圓柱投影的距離平方排序，只使用四則運算，MySQL 與 SQLite 都能執行；
+// 在搜尋半徑內與球面距離的順序幾乎一致，經度差超過 180 度時從另一側計算
+func nearbyDistanceOrder(lat, lng float64) clause.OrderBy {
+	scale := math.Cos(lat * math.Pi / 180)
+	dLng := "(CASE WHEN ABS(locations.longitude - ?) > 180 THEN 360 - ABS(locations.longitude - ?) ELSE ABS(locations.longitude - ?) END * ?)"
+	dLat := "(locations.latitude - ?)"
+	return clause.OrderBy{Expression: clause.Expr{
+		SQL:                dLat + " * " + dLat + " + " + dLng + " * " + dLng + " ASC, matches.match_time ASC",
+		Vars:               []interface{}{lat, lat, lng, lng, lng, scale, lng, lng, lng, scale},
+		WithoutParentheses: true,
+	}}
+}
@@ -0,0 +1,101 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"free2free/geo"
+	"free2free/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListNearbyMatchesSortedByDistance(t *testing.T) {
+	db := setupUserTestDatabase(t)
+	organizer := seedUser(t, db, "organizer")
+
+	// 台北 101 附近、台北車站附近、高雄
+	stores := []models.Location{
+		{Name: "信義店", Address: "台北市信義區", Latitude: 25.0340, Longitude: 121.5645},
+		{Name: "站前店", Address: "台北市中正區", Latitude: 25.0479, Longitude: 121.5171},
+		{Name: "高雄店", Address: "高雄市", Latitude: 22.6273, Longitude: 120.3014},
+	}
+	start := time.Now().Add(time.Hour)
+	for i := range stores {
+		stores[i].Geohash = geo.Encode(stores[i].Latitude, stores[i].Longitude, geo.MaxPrecision)
+		assert.NoError(t, db.Create(&stores[i]).Error)
+		activity := models.Activity{Title: stores[i].Name + "咖啡", TargetCount: 2, LocationID: stores[i].ID, CreatedBy: organizer.ID}
+		assert.NoError(t, db.Create(&activity).Error)
+		match := models.Match{ActivityID: activity.ID, OrganizerID: organizer.ID, MatchTime: start, EndTime: start.Add(time.Hour), Status: "open"}
+		assert.NoError(t, db.Create(&match).Error)
+	}
+
+	// 從台北車站附近搜尋 10 公里內
+	c, w := newUserContext("GET", "/?lat=25.0478&lng=121.5170&radius_km=10", nil, organizer.ID)
+	listNearbyMatches(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var results []NearbyMatch
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+	if assert.Len(t, results, 2) {
+		assert.Equal(t, "站前店", results[0].Activity.Location.Name)
+		assert.Equal(t, "信義店", results[1].Activity.Location.Name)
+		assert.Less(t, results[0].DistanceKm, results[1].DistanceKm)
+	}
+}
+
+func TestListNearbyMatchesRanksByDistanceBeforeLimit(t *testing.T) {
+	db := setupUserTestDatabase(t)
+	organizer := seedUser(t, db, "organizer")
+
+	far := models.Location{Name: "信義店", Address: "台北市信義區", Latitude: 25.0340, Longitude: 121.5645}
+	near := models.Location{Name: "站前店", Address: "台北市中正區", Latitude: 25.0479, Longitude: 121.5171}
+	activities := map[string]models.Activity{}
+	for _, location := range []*models.Location{&far, &near} {
+		location.Geohash = geo.Encode(location.Latitude, location.Longitude, geo.MaxPrecision)
+		assert.NoError(t, db.Create(location).Error)
+		activity := models.Activity{Title: location.Name + "咖啡", TargetCount: 2, LocationID: location.ID, CreatedBy: organizer.ID}
+		assert.NoError(t, db.Create(&activity).Error)
+		activities[location.Name] = activity
+	}
+
+	// 較遠的店有超過候選上限、時間較早的配對局，最近的配對局仍要排在最前面
+	start := time.Now().Add(time.Hour)
+	crowded := make([]models.Match, maxNearbyCandidates)
+	for i := range crowded {
+		crowded[i] = models.Match{ActivityID: activities["信義店"].ID, OrganizerID: organizer.ID, MatchTime: start, EndTime: start.Add(time.Hour), Status: "open"}
+	}
+	assert.NoError(t, db.CreateInBatches(&crowded, 100).Error)
+	later := start.Add(24 * time.Hour)
+	assert.NoError(t, db.Create(&models.Match{ActivityID: activities["站前店"].ID, OrganizerID: organizer.ID, MatchTime: later, EndTime: later.Add(time.Hour), Status: "open"}).Error)
+
+	c, w := newUserContext("GET", "/?lat=25.0478&lng=121.5170&radius_km=10&limit=1", nil, organizer.ID)
+	listNearbyMatches(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var results []NearbyMatch
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, "站前店", results[0].Activity.Location.Name)
+	}
+}
+
+func TestListNearbyMatchesValidatesParameters(t *testing.T) {
+	setupUserTestDatabase(t)
+
+	for _, path := range []string{"/?lng=121", "/?lat=91&lng=121", "/?lat=25&lng=121&radius_km=500"} {
+		c, _ := newUserContext("GET", path, nil, 1)
+		listNearbyMatches(c)
+		assert.Len(t, c.Errors, 1, path)
+	}
+}
+
+func TestSetupUserRoutesRegistersNearbyBesideMatchID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	assert.NotPanics(t, func() {
+		SetupUserRoutes(gin.New())
+	})
+}
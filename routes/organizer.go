@@ -4,6 +4,7 @@ import (
 	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"free2free/models"
 	"free2free/database"
@@ -12,7 +13,9 @@ import (
 	apperrors "free2free/errors"
 
 	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // OrganizerAuthMiddleware 開局者認證中介層
@@ -37,7 +40,7 @@ func OrganizerAuthMiddleware() gin.HandlerFunc {
 	}
 }
 
-// isMatchOrganizer 檢查是否為指定配對局的開局者
+// isMatchOrganizer 檢查是否為指定配對局的開局者或共同開局者
 // 這是一個簡化的實作，實際應用中需要檢查 session 或 token
 func isMatchOrganizer(c *gin.Context, matchID int64) bool {
 	// 取得已認證的使用者
@@ -46,9 +49,12 @@ func isMatchOrganizer(c *gin.Context, matchID int64) bool {
 		return false
 	}
 
-	// 檢查配對局是否存在且開局者為當前使用者
+	// 檢查配對局是否存在且當前使用者為開局者或共同開局者
 	var match models.Match
-	err = database.GlobalDB.Conn.Where("id = ? AND organizer_id = ?", matchID, user.ID).First(&match).Error
+	err = database.GlobalDB.Conn.
+		Where("id = ? AND (organizer_id = ? OR id IN (?))", matchID, user.ID,
+			database.GlobalDB.Conn.Model(&models.MatchCoOrganizer{}).Select("match_id").Where("user_id = ?", user.ID)).
+		First(&match).Error
 	if errors.Is(err, gorm.ErrRecordNotFound) {
 		return false
 	}
@@ -64,9 +70,40 @@ func SetupOrganizerRoutes(r *gin.Engine) {
 	organizer := r.Group("/organizer")
 	organizer.Use(UserAuthMiddleware())
 	{
-		// 審核參與者
+		// 修改配對局
+		organizer.PATCH("/matches/:id", OrganizerAuthMiddleware(), updateMatch)
+
+		// 參與者列表與審核
+		organizer.GET("/matches/:id/participants", OrganizerAuthMiddleware(), listMatchParticipants)
 		organizer.PUT("/matches/:id/participants/:participant_id/approve", OrganizerAuthMiddleware(), approveParticipant)
 		organizer.PUT("/matches/:id/participants/:participant_id/reject", OrganizerAuthMiddleware(), rejectParticipant)
+		organizer.POST("/matches/:id/participants/batch", OrganizerAuthMiddleware(), batchDecideParticipants)
+
+		// 移除參與者與封鎖名單
+		organizer.PUT("/matches/:id/participants/:participant_id/remove", OrganizerAuthMiddleware(), removeParticipant)
+		organizer.GET("/blocks", listBlocks)
+		organizer.DELETE("/blocks/:user_id", unblockUser)
+
+		// 共同開局者與轉移開局者
+		organizer.GET("/matches/:id/co-organizers", OrganizerAuthMiddleware(), listCoOrganizers)
+		organizer.POST("/matches/:id/co-organizers", OrganizerAuthMiddleware(), addCoOrganizer)
+		organizer.DELETE("/matches/:id/co-organizers/:user_id", OrganizerAuthMiddleware(), removeCoOrganizer)
+		organizer.POST("/matches/:id/transfer", OrganizerAuthMiddleware(), transferMatch)
+
+		// 私人集合資訊
+		organizer.PUT("/matches/:id/private", OrganizerAuthMiddleware(), setPrivateDetail)
+		organizer.GET("/matches/:id/private/accesses", OrganizerAuthMiddleware(), listPrivateAccesses)
+
+		// 邀請連結
+		organizer.POST("/matches/:id/invites", OrganizerAuthMiddleware(), createInvite)
+		organizer.GET("/matches/:id/invites", OrganizerAuthMiddleware(), listInvites)
+		organizer.DELETE("/matches/:id/invites/:invite_id", OrganizerAuthMiddleware(), revokeInvite)
+
+		// 現場報到碼
+		organizer.GET("/matches/:id/checkin-code", OrganizerAuthMiddleware(), getCheckInCode)
+
+		// 回報出席狀況
+		organizer.PUT("/matches/:id/participants/:participant_id/attendance", OrganizerAuthMiddleware(), reportAttendance)
 	}
 }
 
@@ -80,6 +117,7 @@ func SetupOrganizerRoutes(r *gin.Engine) {
 // @Param participant_id path int true "參與者ID"
 // @Success 200 {object} MatchParticipant
 // @Failure 400 {object} map[string]string "無效的配對局 ID 或參與者 ID"
+// @Failure 409 {object} map[string]string "配對局名額已滿 (error_code: match_full) 或參與者不是等待審核的狀態 (error_code: invalid_participant_status)"
 // @Failure 500 {object} map[string]string "無法審核通過參與者"
 // @Router /organizer/matches/{id}/participants/{participant_id}/approve [put]
 // @Security ApiKeyAuth
@@ -109,26 +147,68 @@ func approveParticipant(c *gin.Context) {
 		return
 	}
 
-	// 更新參與者狀態為 approved
-	if err := database.GlobalDB.Conn.Model(&participant).Update("status", "approved").Error; err != nil {
-		c.Error(apperrors.MapGORMError(err))
+	// 已記錄出席狀況的參與者不能再變更審核狀態
+	if containsStatus(models.AttendanceStatuses, participant.Status) {
+		c.Error(apperrors.NewValidationError("參與者的出席狀況已記錄，無法變更審核狀態"))
+		return
+	}
+
+	user, err := utils.GetAuthenticatedUser(c)
+	if err != nil {
+		c.Error(apperrors.NewUnauthorizedError("未登入"))
+		return
+	}
+
+	// 更新參與者狀態為 approved，並清除先前的拒絕理由
+	previous := participant.Status
+	updates := map[string]interface{}{"status": "approved", "reconfirm_deadline": nil, "rejection_code": "", "rejection_reason": ""}
+	err = database.GlobalDB.Conn.Transaction(func(tx *gorm.DB) error {
+		// 鎖定配對局，避免同時審核或參與時超過名額；等待重新確認的參與者已佔用名額，直接通過
+		var match models.Match
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&match, matchID).Error; err != nil {
+			return apperrors.MapGORMError(err)
+		}
+		if err := tx.First(&participant, participant.ID).Error; err != nil {
+			return apperrors.MapGORMError(err)
+		}
+		previous = participant.Status
+		if !approvableStatus(previous) {
+			return apperrors.NewCodedError(http.StatusConflict, ErrCodeInvalidParticipantStatus, "只能審核通過等待審核或等待重新確認的參與者")
+		}
+		if previous == "pending" {
+			if err := checkSeatAvailable(tx, &match); err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&participant).Updates(updates).Error; err != nil {
+			return apperrors.MapGORMError(err)
+		}
+		return recordEvent(tx, models.NewParticipantEvent(&participant, previous, "approved", user.ID, ""))
+	})
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
 	participant.Status = "approved"
+	participant.ReconfirmDeadline = nil
+	participant.RejectionCode = ""
+	participant.RejectionReason = ""
 	c.JSON(http.StatusOK, participant)
 }
 
 // rejectParticipant 審核拒絕參與者
 // @Summary 審核拒絕參與者
-// @Description 開局者審核拒絕指定配對局的參與者
+// @Description 開局者審核拒絕指定配對局的參與者，可以附上顯示給申請者的理由
 // @Tags 開局者
 // @Accept json
 // @Produce json
 // @Param id path int true "配對局ID"
 // @Param participant_id path int true "參與者ID"
+// @Param reason body RejectionRequest false "拒絕理由"
 // @Success 200 {object} MatchParticipant
 // @Failure 400 {object} map[string]string "無效的配對局 ID 或參與者 ID"
+// @Failure 409 {object} map[string]string "參與者已被拒絕、移除或釋出名額 (error_code: invalid_participant_status)"
 // @Failure 500 {object} map[string]string "無法審核拒絕參與者"
 // @Router /organizer/matches/{id}/participants/{participant_id}/reject [put]
 // @Security ApiKeyAuth
@@ -147,6 +227,25 @@ func rejectParticipant(c *gin.Context) {
 		return
 	}
 
+	// 拒絕理由為選填
+	var req RejectionRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.Error(apperrors.NewValidationError("無效的請求資料"))
+			return
+		}
+		v := validator.New()
+		if err := v.Struct(&req); err != nil {
+			c.Error(apperrors.NewValidationError(err.Error()))
+			return
+		}
+	}
+	code, reason, err := rejectionReason(req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
 	// 檢查參與者是否屬於此配對局
 	var participant models.MatchParticipant
 	if err := database.GlobalDB.Conn.Where("id = ? AND match_id = ?", participantID, matchID).First(&participant).Error; err != nil {
@@ -158,12 +257,136 @@ func rejectParticipant(c *gin.Context) {
 		return
 	}
 
-	// 更新參與者狀態為 rejected
-	if err := database.GlobalDB.Conn.Model(&participant).Update("status", "rejected").Error; err != nil {
-		c.Error(apperrors.MapGORMError(err))
+	// 已記錄出席狀況的參與者不能再變更審核狀態
+	if containsStatus(models.AttendanceStatuses, participant.Status) {
+		c.Error(apperrors.NewValidationError("參與者的出席狀況已記錄，無法變更審核狀態"))
+		return
+	}
+	if !rejectableStatus(participant.Status) {
+		c.Error(apperrors.NewCodedError(http.StatusConflict, ErrCodeInvalidParticipantStatus, "只能拒絕等待審核、已通過或等待重新確認的參與者"))
+		return
+	}
+
+	user, err := utils.GetAuthenticatedUser(c)
+	if err != nil {
+		c.Error(apperrors.NewUnauthorizedError("未登入"))
+		return
+	}
+
+	// 更新參與者狀態為 rejected；以原本的狀態為條件，避免覆蓋同時被移除的參與者
+	previous := participant.Status
+	updates := map[string]interface{}{"status": "rejected", "reconfirm_deadline": nil, "rejection_code": code, "rejection_reason": reason}
+	err = database.GlobalDB.Conn.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.MatchParticipant{}).Where("id = ? AND status = ?", participant.ID, previous).Updates(updates)
+		if result.Error != nil {
+			return apperrors.MapGORMError(result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return apperrors.NewCodedError(http.StatusConflict, ErrCodeInvalidParticipantStatus, "參與者的狀態已變更，請重新整理")
+		}
+		return recordEvent(tx, models.NewParticipantEvent(&participant, previous, "rejected", user.ID, reason))
+	})
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
 	participant.Status = "rejected"
+	participant.ReconfirmDeadline = nil
+	participant.RejectionCode = code
+	participant.RejectionReason = reason
+	c.JSON(http.StatusOK, participant)
+}
+
+// AttendanceRequest 開局者回報參與者出席狀況的請求
+type AttendanceRequest struct {
+	Status string `json:"status" validate:"required,oneof=attended no_show late_cancel"`
+}
+
+// reportAttendance 回報參與者出席狀況
+// @Summary 回報參與者出席狀況
+// @Description 配對局完成後 48 小時內，開局者可以將已審核通過的參與者標記為出席 (attended)、未出席 (no_show) 或臨時取消 (late_cancel)，期限內可以修正；期限過後依報到紀錄自動記錄
+// @Tags 開局者
+// @Accept json
+// @Produce json
+// @Param id path int true "配對局ID"
+// @Param participant_id path int true "參與者ID"
+// @Param attendance body AttendanceRequest true "出席狀況"
+// @Success 200 {object} MatchParticipant
+// @Failure 400 {object} map[string]string "無效的請求資料、配對局尚未完成或已超過回報期限"
+// @Failure 500 {object} map[string]string "無法記錄出席狀況"
+// @Router /organizer/matches/{id}/participants/{participant_id}/attendance [put]
+// @Security ApiKeyAuth
+func reportAttendance(c *gin.Context) {
+	matchID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || matchID <= 0 {
+		c.Error(apperrors.NewValidationError("無效的配對局 ID"))
+		return
+	}
+
+	participantID, err := strconv.ParseInt(c.Param("participant_id"), 10, 64)
+	if err != nil || participantID <= 0 {
+		c.Error(apperrors.NewValidationError("無效的參與者 ID"))
+		return
+	}
+
+	var req AttendanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.NewValidationError("無效的請求資料"))
+		return
+	}
+	v := validator.New()
+	if err := v.Struct(&req); err != nil {
+		c.Error(apperrors.NewValidationError(err.Error()))
+		return
+	}
+
+	db := database.GlobalDB.Conn
+	var match models.Match
+	if err := db.Where("id = ? AND status = ?", matchID, "completed").First(&match).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Error(apperrors.NewValidationError("配對局尚未完成，無法回報出席狀況"))
+			return
+		}
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+	if time.Now().After(match.EndTime.Add(models.AttendanceReportWindow)) {
+		c.Error(apperrors.NewValidationError("已超過回報出席狀況的期限"))
+		return
+	}
+
+	// 只有已審核通過或已記錄出席狀況的參與者可以回報
+	reportable := append([]string{"approved"}, models.AttendanceStatuses...)
+	var participant models.MatchParticipant
+	err = db.Where("id = ? AND match_id = ? AND status IN ?", participantID, matchID, reportable).First(&participant).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Error(apperrors.NewValidationError("指定的參與者不存在或未審核通過"))
+			return
+		}
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+
+	user, err := utils.GetAuthenticatedUser(c)
+	if err != nil {
+		c.Error(apperrors.NewUnauthorizedError("未登入"))
+		return
+	}
+
+	previous := participant.Status
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&participant).Update("status", req.Status).Error; err != nil {
+			return apperrors.MapGORMError(err)
+		}
+		return recordEvent(tx, models.NewParticipantEvent(&participant, previous, req.Status, user.ID, ""))
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	participant.Status = req.Status
 	c.JSON(http.StatusOK, participant)
 }
@@ -0,0 +1,321 @@
+package routes
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"free2free/database"
+	"free2free/jobs"
+	"free2free/models"
+	"free2free/utils"
+
+	apperrors "free2free/errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+const (
+	// maxIntentWindow 意向時段最長的長度
+	maxIntentWindow = 7 * 24 * time.Hour
+	// maxOpenIntents 每位使用者同時可以等待配對的意向數
+	maxOpenIntents = 5
+)
+
+// IntentRequest 建立配對意向的請求，activity_id 與 chain 擇一指定
+type IntentRequest struct {
+	ActivityID    *int64    `json:"activity_id" validate:"omitempty,min=1"`
+	Chain         string    `json:"chain" validate:"omitempty,max=100"`
+	Latitude      float64   `json:"latitude" validate:"min=-90,max=90"`
+	Longitude     float64   `json:"longitude" validate:"min=-180,max=180"`
+	MaxDistanceKm float64   `json:"max_distance_km" validate:"required,gt=0,max=50"`
+	WindowStart   time.Time `json:"window_start" validate:"required"`
+	WindowEnd     time.Time `json:"window_end" validate:"required,gtfield=WindowStart"`
+}
+
+// notificationListSpec 通知列表以 ID 排序，預設由新到舊
+var notificationListSpec = ListSpec[models.Notification]{
+	IDColumn: "notifications.id",
+	ID:       func(n models.Notification) int64 { return n.ID },
+	Sorts: map[string]SortField[models.Notification]{
+		"id": {Column: "notifications.id", Kind: sortInt, Value: func(n models.Notification) interface{} { return n.ID }},
+	},
+	DefaultSort: "-id",
+}
+
+// SetupPairingRoutes 設定自動配對與通知路由
+func SetupPairingRoutes(r *gin.Engine) {
+	user := r.Group("/user")
+	user.Use(UserAuthMiddleware())
+	{
+		// 配對意向
+		user.GET("/intents", listIntents)
+		user.POST("/intents", createIntent)
+		user.POST("/intents/:id/cancel", cancelIntent)
+
+		// 通知
+		user.GET("/notifications", listNotifications)
+		user.POST("/notifications/:id/read", markNotificationRead)
+	}
+}
+
+// listIntents 列出自己的配對意向
+// @Summary 配對意向列表
+// @Description 列出當前使用者的配對意向，已配對的意向附上 match_id
+// @Tags 使用者
+// @Produce json
+// @Success 200 {array} PairingIntent
+// @Failure 401 {object} map[string]string "未登入"
+// @Router /user/intents [get]
+// @Security ApiKeyAuth
+func listIntents(c *gin.Context) {
+	user, err := utils.GetAuthenticatedUser(c)
+	if err != nil {
+		c.Error(apperrors.NewUnauthorizedError("未登入"))
+		return
+	}
+
+	var intents []models.PairingIntent
+	if err := database.GlobalDB.Conn.Where("user_id = ?", user.ID).Order("id DESC").Find(&intents).Error; err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+	c.JSON(http.StatusOK, intents)
+}
+
+// createIntent 建立配對意向
+// @Summary 建立配對意向
+// @Description 指定活動或連鎖店 (地點名稱)、可以參加的時段與最遠距離，系統會自動與相容的意向配成配對局，雙方都直接通過審核並收到通知。對象優先選擇出席可信度高的使用者，其次是距離較近的
+// @Tags 使用者
+// @Accept json
+// @Produce json
+// @Param intent body IntentRequest true "配對意向"
+// @Success 201 {object} PairingIntent
+// @Failure 400 {object} map[string]string "無效的請求資料"
+// @Failure 401 {object} map[string]string "未登入"
+// @Failure 409 {object} map[string]string "等待配對的意向過多"
+// @Router /user/intents [post]
+// @Security ApiKeyAuth
+func createIntent(c *gin.Context) {
+	var req IntentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.NewValidationError("無效的請求資料"))
+		return
+	}
+	v := validator.New()
+	if err := v.Struct(&req); err != nil {
+		c.Error(apperrors.NewValidationError(err.Error()))
+		return
+	}
+	if (req.ActivityID == nil) == (req.Chain == "") {
+		c.Error(apperrors.NewValidationError("activity_id 與 chain 必須擇一指定"))
+		return
+	}
+	if !req.WindowEnd.After(time.Now()) {
+		c.Error(apperrors.NewValidationError("window_end 必須晚於現在"))
+		return
+	}
+	if req.WindowEnd.Sub(req.WindowStart) > maxIntentWindow {
+		c.Error(apperrors.NewValidationError("時段最長為 7 天"))
+		return
+	}
+
+	user, err := utils.GetAuthenticatedUser(c)
+	if err != nil {
+		c.Error(apperrors.NewUnauthorizedError("未登入"))
+		return
+	}
+
+	db := database.GlobalDB.Conn
+	if req.ActivityID != nil {
+		if err := db.Select("id").First(&models.Activity{}, *req.ActivityID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.Error(apperrors.NewValidationError("指定的活動不存在"))
+				return
+			}
+			c.Error(apperrors.MapGORMError(err))
+			return
+		}
+	} else {
+		var locations int64
+		if err := db.Model(&models.Location{}).Where("name = ?", req.Chain).Count(&locations).Error; err != nil {
+			c.Error(apperrors.MapGORMError(err))
+			return
+		}
+		if locations == 0 {
+			c.Error(apperrors.NewValidationError("找不到此連鎖店的地點"))
+			return
+		}
+	}
+
+	var open int64
+	if err := db.Model(&models.PairingIntent{}).Where("user_id = ? AND status = ?", user.ID, "open").Count(&open).Error; err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+	if open >= maxOpenIntents {
+		c.Error(apperrors.NewAppError(http.StatusConflict, "等待配對的意向最多 5 筆，請先取消其他意向"))
+		return
+	}
+
+	intent := models.PairingIntent{
+		UserID:        user.ID,
+		ActivityID:    req.ActivityID,
+		Chain:         req.Chain,
+		Latitude:      req.Latitude,
+		Longitude:     req.Longitude,
+		MaxDistanceKm: req.MaxDistanceKm,
+		WindowStart:   req.WindowStart,
+		WindowEnd:     req.WindowEnd,
+		Status:        "open",
+		CreatedAt:     time.Now(),
+	}
+	if err := db.Create(&intent).Error; err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+
+	// 立即為這筆意向嘗試配對；沒有對象或失敗時由背景工作 pair_intents 重試
+	paired, err := jobs.PairIntent(c.Request.Context(), db, intent.ID)
+	if err != nil {
+		log.Printf("意向 %d 立即配對失敗: %v", intent.ID, err)
+	}
+	if paired {
+		db.First(&intent, intent.ID)
+	}
+	c.JSON(http.StatusCreated, intent)
+}
+
+// cancelIntent 取消配對意向
+// @Summary 取消配對意向
+// @Description 取消尚未配對的意向
+// @Tags 使用者
+// @Produce json
+// @Param id path int true "意向ID"
+// @Success 200 {object} PairingIntent
+// @Failure 400 {object} map[string]string "無效的意向 ID 或意向已配對"
+// @Failure 404 {object} map[string]string "意向不存在"
+// @Router /user/intents/{id}/cancel [post]
+// @Security ApiKeyAuth
+func cancelIntent(c *gin.Context) {
+	intentID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || intentID <= 0 {
+		c.Error(apperrors.NewValidationError("無效的意向 ID"))
+		return
+	}
+
+	user, err := utils.GetAuthenticatedUser(c)
+	if err != nil {
+		c.Error(apperrors.NewUnauthorizedError("未登入"))
+		return
+	}
+
+	db := database.GlobalDB.Conn
+	var intent models.PairingIntent
+	if err := db.Where("id = ? AND user_id = ?", intentID, user.ID).First(&intent).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Error(apperrors.NewAppError(http.StatusNotFound, "意向不存在"))
+			return
+		}
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+
+	// 只取消仍在等待配對的意向，避免與同時進行的配對衝突
+	result := db.Model(&models.PairingIntent{}).Where("id = ? AND status = ?", intent.ID, "open").Update("status", "cancelled")
+	if result.Error != nil {
+		c.Error(apperrors.MapGORMError(result.Error))
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.Error(apperrors.NewValidationError("只能取消等待配對中的意向"))
+		return
+	}
+
+	intent.Status = "cancelled"
+	c.JSON(http.StatusOK, intent)
+}
+
+// listNotifications 取得通知
+// @Summary 取得通知
+// @Description 取得當前使用者的通知，支援游標分頁；unread=true 只回傳未讀通知
+// @Tags 使用者
+// @Produce json
+// @Param unread query bool false "只回傳未讀通知"
+// @Param limit query int false "每頁筆數 (1-100)，預設 20"
+// @Param cursor query string false "上一頁回傳的 X-Next-Cursor"
+// @Success 200 {array} Notification
+// @Header 200 {integer} X-Total-Count "通知總數"
+// @Header 200 {string} X-Next-Cursor "下一頁游標，沒有下一頁時為空"
+// @Failure 401 {object} map[string]string "未登入"
+// @Router /user/notifications [get]
+// @Security ApiKeyAuth
+func listNotifications(c *gin.Context) {
+	user, err := utils.GetAuthenticatedUser(c)
+	if err != nil {
+		c.Error(apperrors.NewUnauthorizedError("未登入"))
+		return
+	}
+
+	query := database.GlobalDB.Conn.Model(&models.Notification{}).Where("notifications.user_id = ?", user.ID)
+	if c.Query("unread") == "true" {
+		query = query.Where("notifications.read_at IS NULL")
+	}
+
+	notifications, err := Paginate(c, query, notificationListSpec)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, notifications)
+}
+
+// markNotificationRead 將通知標記為已讀
+// @Summary 標記通知已讀
+// @Description 將指定的通知標記為已讀
+// @Tags 使用者
+// @Produce json
+// @Param id path int true "通知ID"
+// @Success 200 {object} Notification
+// @Failure 400 {object} map[string]string "無效的通知 ID"
+// @Failure 404 {object} map[string]string "通知不存在"
+// @Router /user/notifications/{id}/read [post]
+// @Security ApiKeyAuth
+func markNotificationRead(c *gin.Context) {
+	notificationID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || notificationID <= 0 {
+		c.Error(apperrors.NewValidationError("無效的通知 ID"))
+		return
+	}
+
+	user, err := utils.GetAuthenticatedUser(c)
+	if err != nil {
+		c.Error(apperrors.NewUnauthorizedError("未登入"))
+		return
+	}
+
+	db := database.GlobalDB.Conn
+	var notification models.Notification
+	if err := db.Where("id = ? AND user_id = ?", notificationID, user.ID).First(&notification).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Error(apperrors.NewAppError(http.StatusNotFound, "通知不存在"))
+			return
+		}
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+
+	if notification.ReadAt == nil {
+		now := time.Now()
+		if err := db.Model(&notification).Update("read_at", now).Error; err != nil {
+			c.Error(apperrors.MapGORMError(err))
+			return
+		}
+		notification.ReadAt = &now
+	}
+	c.JSON(http.StatusOK, notification)
+}
@@ -0,0 +1,124 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"free2free/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIntentPairing(t *testing.T) {
+	db := setupUserTestDatabase(t)
+
+	alice := seedUser(t, db, "alice")
+	bob := seedUser(t, db, "bob")
+	carol := seedUser(t, db, "carol")
+	// seedMatch 建立「全家便利商店」地點與活動
+	seeded := seedMatch(t, db, carol.ID)
+
+	windowStart := time.Now().Add(time.Hour).UTC().Truncate(time.Minute)
+	windowEnd := windowStart.Add(4 * time.Hour)
+	create := func(userID int64, target string) models.PairingIntent {
+		body := fmt.Sprintf(`{%s, "latitude": 25.031, "longitude": 121.561, "max_distance_km": 2, "window_start": "%s", "window_end": "%s"}`,
+			target, windowStart.Format(time.RFC3339), windowEnd.Format(time.RFC3339))
+		c, w := newUserContext("POST", "/", []byte(body), userID)
+		createIntent(c)
+		assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var intent models.PairingIntent
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &intent))
+		return intent
+	}
+
+	first := create(alice.ID, `"chain": "全家便利商店"`)
+	assert.Equal(t, "open", first.Status)
+
+	// 第二筆相容的意向立即配對成功
+	second := create(bob.ID, fmt.Sprintf(`"activity_id": %d`, seeded.ActivityID))
+	assert.Equal(t, "matched", second.Status)
+	if assert.NotNil(t, second.MatchID) {
+		var match models.Match
+		assert.NoError(t, db.First(&match, *second.MatchID).Error)
+		assert.Equal(t, seeded.ActivityID, match.ActivityID)
+		assert.Equal(t, alice.ID, match.OrganizerID)
+		assert.Equal(t, windowStart, match.MatchTime.UTC())
+
+		var approved int64
+		assert.NoError(t, db.Model(&models.MatchParticipant{}).Where("match_id = ? AND status = ?", match.ID, "approved").Count(&approved).Error)
+		assert.Equal(t, int64(2), approved)
+	}
+
+	var refreshed models.PairingIntent
+	assert.NoError(t, db.First(&refreshed, first.ID).Error)
+	assert.Equal(t, "matched", refreshed.Status)
+
+	// 雙方都收到通知
+	c, w := newUserContext("GET", "/user/notifications?unread=true", nil, alice.ID)
+	listNotifications(c)
+	var notifications []models.Notification
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &notifications))
+	if assert.Len(t, notifications, 1) {
+		assert.Equal(t, second.MatchID, notifications[0].MatchID)
+
+		c, _ = newUserContext("POST", "/", nil, alice.ID)
+		c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(notifications[0].ID)}}
+		markNotificationRead(c)
+		assert.Empty(t, c.Errors)
+	}
+	var bobNotifications int64
+	assert.NoError(t, db.Model(&models.Notification{}).Where("user_id = ?", bob.ID).Count(&bobNotifications).Error)
+	assert.Equal(t, int64(1), bobNotifications)
+
+	// 已配對的意向不能取消，等待中的可以
+	cancel := func(userID, intentID int64) int {
+		c, w := newUserContext("POST", "/", nil, userID)
+		c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(intentID)}}
+		cancelIntent(c)
+		if len(c.Errors) > 0 {
+			return http.StatusBadRequest
+		}
+		return w.Code
+	}
+	assert.Equal(t, http.StatusBadRequest, cancel(alice.ID, first.ID))
+	waiting := create(carol.ID, `"chain": "全家便利商店"`)
+	assert.Equal(t, "open", waiting.Status)
+	assert.Equal(t, http.StatusOK, cancel(carol.ID, waiting.ID))
+
+	// activity_id 與 chain 必須擇一
+	body := fmt.Sprintf(`{"latitude": 25.03, "longitude": 121.56, "max_distance_km": 2, "window_start": "%s", "window_end": "%s"}`,
+		windowStart.Format(time.RFC3339), windowEnd.Format(time.RFC3339))
+	c, _ = newUserContext("POST", "/", []byte(body), carol.ID)
+	createIntent(c)
+	assert.NotEmpty(t, c.Errors)
+}
+
+func TestIntentPairingSkipsBlockedUsers(t *testing.T) {
+	db := setupUserTestDatabase(t)
+
+	alice := seedUser(t, db, "alice")
+	bob := seedUser(t, db, "bob")
+	seeded := seedMatch(t, db, alice.ID)
+	// alice 曾把 bob 移出配對局並封鎖
+	assert.NoError(t, db.Create(&models.OrganizerBlock{OrganizerID: alice.ID, UserID: bob.ID, CreatedAt: time.Now()}).Error)
+
+	windowStart := time.Now().Add(time.Hour).UTC().Truncate(time.Minute)
+	for _, userID := range []int64{bob.ID, alice.ID} {
+		body := fmt.Sprintf(`{"activity_id": %d, "latitude": 25.031, "longitude": 121.561, "max_distance_km": 2, "window_start": "%s", "window_end": "%s"}`,
+			seeded.ActivityID, windowStart.Format(time.RFC3339), windowStart.Add(4*time.Hour).Format(time.RFC3339))
+		c, w := newUserContext("POST", "/", []byte(body), userID)
+		createIntent(c)
+		assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var intent models.PairingIntent
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &intent))
+		assert.Equal(t, "open", intent.Status)
+	}
+
+	var matches int64
+	assert.NoError(t, db.Model(&models.Match{}).Where("id <> ?", seeded.ID).Count(&matches).Error)
+	assert.Equal(t, int64(0), matches)
+}
@@ -0,0 +1,164 @@
+package routes
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"free2free/database"
+	"free2free/models"
+	"free2free/reliability"
+
+	apperrors "free2free/errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// participantStatuses 參與者列表可用的 status 篩選值
+var participantStatuses = []string{"pending", "approved", "rejected", "attended", "no_show", "late_cancel", "reconfirm", "released", "removed"}
+
+// QueuedParticipant 開局者審核時看到的參與者資料
+type QueuedParticipant struct {
+	ID                int64       `json:"id"`
+	Status            string      `json:"status"`
+	JoinedAt          time.Time   `json:"joined_at"`
+	CheckedInAt       *time.Time  `json:"checked_in_at,omitempty"`
+	ReconfirmDeadline *time.Time  `json:"reconfirm_deadline,omitempty"`
+	Profile           UserProfile `json:"profile"`
+	// ReviewAverage 收到的平均評分，沒有評分時為 null
+	ReviewAverage *float64 `json:"review_average"`
+	ReviewCount   int64    `json:"review_count"`
+}
+
+// participantSortValues 參與者列表可用的排序欄位，回傳 nil 表示沒有值，不論方向都排在最後
+var participantSortValues = map[string]func(p QueuedParticipant) *float64{
+	"joined_at": func(p QueuedParticipant) *float64 {
+		v := float64(p.JoinedAt.UnixMicro())
+		return &v
+	},
+	"review_average": func(p QueuedParticipant) *float64 { return p.ReviewAverage },
+	"reliability": func(p QueuedParticipant) *float64 {
+		if p.Profile.Reliability.Score == nil {
+			return nil
+		}
+		v := float64(*p.Profile.Reliability.Score)
+		return &v
+	},
+}
+
+// reviewStats 使用者收到的評分統計
+type reviewStats struct {
+	RevieweeID int64
+	Average    float64
+	Count      int64
+}
+
+// listMatchParticipants 開局者查看參與者列表
+// @Summary 參與者列表
+// @Description 開局者查看配對局的參與者與申請者，附上公開資料、收到的平均評分與出席可信度，方便審核。status 篩選狀態；sort 可用 joined_at (預設)、review_average、reliability，加上 - 前綴為遞減，沒有評分或可信度的參與者排在最後
+// @Tags 開局者
+// @Produce json
+// @Param id path int true "配對局ID"
+// @Param status query string false "參與狀態 (pending, approved, rejected, attended, no_show, late_cancel, reconfirm, released, removed)"
+// @Param sort query string false "排序欄位 (joined_at, review_average, reliability)，加上 - 前綴為遞減"
+// @Success 200 {array} QueuedParticipant
+// @Header 200 {integer} X-Total-Count "參與者總數"
+// @Failure 400 {object} map[string]string "無效的配對局 ID 或查詢參數"
+// @Failure 403 {object} map[string]string "需要開局者權限"
+// @Router /organizer/matches/{id}/participants [get]
+// @Security ApiKeyAuth
+func listMatchParticipants(c *gin.Context) {
+	matchID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || matchID <= 0 {
+		c.Error(apperrors.NewValidationError("無效的配對局 ID"))
+		return
+	}
+
+	status, hasStatus, err := queryEnum(c, "status", participantStatuses...)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	sortParam := c.DefaultQuery("sort", "joined_at")
+	value, ok := participantSortValues[strings.TrimPrefix(sortParam, "-")]
+	if !ok {
+		c.Error(apperrors.NewCodedError(http.StatusBadRequest, ErrCodeInvalidSort, "無效的 sort 參數，可用欄位: joined_at, reliability, review_average"))
+		return
+	}
+	desc := strings.HasPrefix(sortParam, "-")
+
+	db := database.GlobalDB.Conn
+	query := db.Preload("User").Where("match_id = ?", matchID)
+	if hasStatus {
+		query = query.Where("status = ?", status)
+	}
+	var participants []models.MatchParticipant
+	if err := query.Order("id").Find(&participants).Error; err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+
+	userIDs := make([]int64, len(participants))
+	for i, p := range participants {
+		userIDs[i] = p.UserID
+	}
+	records, err := reliability.Load(db, userIDs)
+	if err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+	var stats []reviewStats
+	err = db.Model(&models.Review{}).
+		Select("reviewee_id, AVG(score) AS average, COUNT(*) AS count").
+		Where("reviewee_id IN ?", userIDs).
+		Group("reviewee_id").
+		Scan(&stats).Error
+	if err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+	reviews := make(map[int64]reviewStats, len(stats))
+	for _, s := range stats {
+		reviews[s.RevieweeID] = s
+	}
+
+	queue := make([]QueuedParticipant, len(participants))
+	for i, p := range participants {
+		queue[i] = QueuedParticipant{
+			ID:                p.ID,
+			Status:            p.Status,
+			JoinedAt:          p.JoinedAt,
+			CheckedInAt:       p.CheckedInAt,
+			ReconfirmDeadline: p.ReconfirmDeadline,
+			Profile: UserProfile{
+				ID:          p.User.ID,
+				Name:        p.User.Name,
+				AvatarURL:   p.User.AvatarURL,
+				CreatedAt:   p.User.CreatedAt,
+				Reliability: records[p.UserID],
+			},
+		}
+		if s, ok := reviews[p.UserID]; ok {
+			average := s.Average
+			queue[i].ReviewAverage = &average
+			queue[i].ReviewCount = s.Count
+		}
+	}
+
+	// 每場配對局的參與者有限，直接在記憶體中排序，相同時依申請先後
+	sort.SliceStable(queue, func(i, j int) bool {
+		a, b := value(queue[i]), value(queue[j])
+		if a == nil || b == nil {
+			return a != nil && b == nil
+		}
+		if desc {
+			return *a > *b
+		}
+		return *a < *b
+	})
+
+	c.Header("X-Total-Count", strconv.Itoa(len(queue)))
+	c.JSON(http.StatusOK, queue)
+}
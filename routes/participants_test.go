@@ -0,0 +1,76 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"free2free/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListMatchParticipants(t *testing.T) {
+	db := setupUserTestDatabase(t)
+
+	organizer := seedUser(t, db, "organizer")
+	alice := seedUser(t, db, "alice")
+	bob := seedUser(t, db, "bob")
+	carol := seedUser(t, db, "carol")
+	match := seedMatch(t, db, organizer.ID)
+
+	joined := time.Now().Add(-time.Hour)
+	statuses := map[int64]string{carol.ID: "approved", alice.ID: "pending", bob.ID: "pending"}
+	for i, userID := range []int64{carol.ID, alice.ID, bob.ID} {
+		p := models.MatchParticipant{MatchID: match.ID, UserID: userID, Status: statuses[userID], JoinedAt: joined.Add(time.Duration(i) * time.Minute)}
+		assert.NoError(t, db.Create(&p).Error)
+	}
+	// alice 收到的平均評分較高，carol 沒有評分
+	for _, r := range []models.Review{
+		{MatchID: match.ID, ReviewerID: organizer.ID, RevieweeID: alice.ID, Score: 5},
+		{MatchID: match.ID, ReviewerID: carol.ID, RevieweeID: alice.ID, Score: 4},
+		{MatchID: match.ID, ReviewerID: organizer.ID, RevieweeID: bob.ID, Score: 3},
+	} {
+		assert.NoError(t, db.Create(&r).Error)
+	}
+
+	list := func(query string) ([]QueuedParticipant, *gin.Context) {
+		c, w := newUserContext("GET", "/organizer/matches/1/participants?"+query, nil, organizer.ID)
+		c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(match.ID)}}
+		listMatchParticipants(c)
+		var queue []QueuedParticipant
+		if len(c.Errors) == 0 {
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &queue))
+		}
+		return queue, c
+	}
+	names := func(queue []QueuedParticipant) []string {
+		result := make([]string, len(queue))
+		for i, p := range queue {
+			result[i] = p.Profile.Name
+		}
+		return result
+	}
+
+	queue, _ := list("")
+	assert.Equal(t, []string{"carol", "alice", "bob"}, names(queue))
+
+	queue, _ = list("status=pending&sort=-review_average")
+	assert.Equal(t, []string{"alice", "bob"}, names(queue))
+	if assert.NotNil(t, queue[0].ReviewAverage) {
+		assert.InDelta(t, 4.5, *queue[0].ReviewAverage, 0.001)
+		assert.Equal(t, int64(2), queue[0].ReviewCount)
+	}
+
+	// 沒有評分的參與者不論排序方向都在最後
+	queue, _ = list("sort=review_average")
+	assert.Equal(t, []string{"bob", "alice", "carol"}, names(queue))
+	assert.Nil(t, queue[2].ReviewAverage)
+
+	_, c := list("sort=name")
+	assert.NotEmpty(t, c.Errors)
+	_, c = list("status=unknown")
+	assert.NotEmpty(t, c.Errors)
+}
@@ -0,0 +1,394 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"free2free/database"
+	"free2free/models"
+	"free2free/utils"
+
+	apperrors "free2free/errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PrivateDetailRequest 設定私人集合資訊的請求
+type PrivateDetailRequest struct {
+	Notes         string `json:"notes" validate:"omitempty,max=1000"`
+	ContactHandle string `json:"contact_handle" validate:"omitempty,max=100"`
+}
+
+// SharedContactsRequest 參與者選擇審核通過後要公開的聯絡方式
+type SharedContactsRequest struct {
+	Fields []string `json:"fields" validate:"max=4,unique,dive,oneof=email phone line_id instagram"`
+}
+
+// ParticipantContact 參與者選擇公開的聯絡方式，未公開的欄位不會出現
+type ParticipantContact struct {
+	UserID    int64  `json:"user_id"`
+	Name      string `json:"name"`
+	Email     string `json:"email,omitempty"`
+	Phone     string `json:"phone,omitempty"`
+	LineID    string `json:"line_id,omitempty"`
+	Instagram string `json:"instagram,omitempty"`
+}
+
+// MatchPrivateInfo 開局者與已審核通過參與者看到的私人集合資訊
+type MatchPrivateInfo struct {
+	Notes         string               `json:"notes"`
+	ContactHandle string               `json:"contact_handle"`
+	UpdatedAt     *time.Time           `json:"updated_at,omitempty"`
+	Contacts      []ParticipantContact `json:"contacts"`
+}
+
+// privateAccessListSpec 查看紀錄以 ID 排序，預設由新到舊
+var privateAccessListSpec = ListSpec[models.MatchPrivateAccess]{
+	IDColumn: "match_private_accesses.id",
+	ID:       func(a models.MatchPrivateAccess) int64 { return a.ID },
+	Sorts: map[string]SortField[models.MatchPrivateAccess]{
+		"id": {Column: "match_private_accesses.id", Kind: sortInt, Value: func(a models.MatchPrivateAccess) interface{} { return a.ID }},
+	},
+	DefaultSort: "-id",
+	Preloads:    []string{"User"},
+}
+
+// setPrivateDetail 設定私人集合資訊
+// @Summary 設定私人集合資訊
+// @Description 開局者或共同開局者設定只提供給已審核通過參與者的集合備註 (例如「在側門集合」) 與聯絡帳號，不會出現在配對局列表與詳細資訊中
+// @Tags 開局者
+// @Accept json
+// @Produce json
+// @Param id path int true "配對局ID"
+// @Param detail body PrivateDetailRequest true "私人集合資訊"
+// @Success 200 {object} MatchPrivateDetail
+// @Failure 400 {object} map[string]string "無效的請求資料"
+// @Failure 403 {object} map[string]string "需要開局者權限"
+// @Router /organizer/matches/{id}/private [put]
+// @Security ApiKeyAuth
+func setPrivateDetail(c *gin.Context) {
+	matchID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || matchID <= 0 {
+		c.Error(apperrors.NewValidationError("無效的配對局 ID"))
+		return
+	}
+
+	var req PrivateDetailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.NewValidationError("無效的請求資料"))
+		return
+	}
+	v := validator.New()
+	if err := v.Struct(&req); err != nil {
+		c.Error(apperrors.NewValidationError(err.Error()))
+		return
+	}
+
+	user, err := utils.GetAuthenticatedUser(c)
+	if err != nil {
+		c.Error(apperrors.NewUnauthorizedError("未登入"))
+		return
+	}
+
+	detail := models.MatchPrivateDetail{
+		MatchID:       matchID,
+		Notes:         req.Notes,
+		ContactHandle: req.ContactHandle,
+		UpdatedBy:     user.ID,
+		UpdatedAt:     time.Now(),
+	}
+	err = database.GlobalDB.Conn.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "match_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"notes", "contact_handle", "updated_by", "updated_at"}),
+	}).Create(&detail).Error
+	if err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+	c.JSON(http.StatusOK, detail)
+}
+
+// getPrivateDetail 取得私人集合資訊
+// @Summary 取得私人集合資訊
+// @Description 開局者、共同開局者與已審核通過的參與者可以查看集合備註、開局者的聯絡帳號，以及其他參與者選擇公開的聯絡方式。每次查看都會記錄
+// @Tags 使用者
+// @Produce json
+// @Param id path int true "配對局ID"
+// @Success 200 {object} MatchPrivateInfo
+// @Failure 400 {object} map[string]string "無效的配對局 ID"
+// @Failure 403 {object} map[string]string "只有開局者與已審核通過的參與者可以查看"
+// @Failure 404 {object} map[string]string "配對局不存在"
+// @Router /user/matches/{id}/private [get]
+// @Security ApiKeyAuth
+func getPrivateDetail(c *gin.Context) {
+	matchID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || matchID <= 0 {
+		c.Error(apperrors.NewValidationError("無效的配對局 ID"))
+		return
+	}
+
+	user, err := utils.GetAuthenticatedUser(c)
+	if err != nil {
+		c.Error(apperrors.NewUnauthorizedError("未登入"))
+		return
+	}
+
+	db := database.GlobalDB.Conn
+	var match models.Match
+	if err := db.First(&match, matchID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Error(apperrors.NewAppError(http.StatusNotFound, "配對局不存在"))
+			return
+		}
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+
+	allowed, err := isMatchMember(db, &match, user.ID)
+	if err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+	if !allowed {
+		c.Error(apperrors.NewForbiddenError("只有開局者與已審核通過的參與者可以查看集合資訊"))
+		return
+	}
+
+	// 先記錄查看紀錄，記錄失敗時不回傳資訊
+	access := models.MatchPrivateAccess{MatchID: match.ID, UserID: user.ID, CreatedAt: time.Now()}
+	if err := db.Create(&access).Error; err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+
+	info := MatchPrivateInfo{Contacts: []ParticipantContact{}}
+	var detail models.MatchPrivateDetail
+	err = db.Where("match_id = ?", match.ID).First(&detail).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+	if err == nil {
+		info.Notes = detail.Notes
+		info.ContactHandle = detail.ContactHandle
+		info.UpdatedAt = &detail.UpdatedAt
+	}
+
+	var participants []models.MatchParticipant
+	err = db.Preload("User").
+		Where("match_id = ? AND status IN ? AND shared_contacts <> ?", match.ID, models.ApprovedParticipantStatuses, "").
+		Order("joined_at").
+		Find(&participants).Error
+	if err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+	userIDs := make([]int64, len(participants))
+	for i, p := range participants {
+		userIDs[i] = p.UserID
+	}
+	var contacts []models.UserContact
+	if err := db.Where("user_id IN ?", userIDs).Find(&contacts).Error; err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+	byUser := make(map[int64]models.UserContact, len(contacts))
+	for _, contact := range contacts {
+		byUser[contact.UserID] = contact
+	}
+
+	for _, p := range participants {
+		contact := byUser[p.UserID]
+		shared := ParticipantContact{UserID: p.UserID, Name: p.User.Name}
+		for _, field := range strings.Split(p.SharedContacts, ",") {
+			switch field {
+			case "email":
+				shared.Email = p.User.Email
+			case "phone":
+				shared.Phone = contact.Phone
+			case "line_id":
+				shared.LineID = contact.LineID
+			case "instagram":
+				shared.Instagram = contact.Instagram
+			}
+		}
+		info.Contacts = append(info.Contacts, shared)
+	}
+
+	c.JSON(http.StatusOK, info)
+}
+
+// listPrivateAccesses 取得私人集合資訊的查看紀錄
+// @Summary 私人集合資訊查看紀錄
+// @Description 開局者與共同開局者查看誰在什麼時間查看過私人集合資訊，支援游標分頁
+// @Tags 開局者
+// @Produce json
+// @Param id path int true "配對局ID"
+// @Param limit query int false "每頁筆數 (1-100)，預設 20"
+// @Param cursor query string false "上一頁回傳的 X-Next-Cursor"
+// @Success 200 {array} MatchPrivateAccess
+// @Header 200 {integer} X-Total-Count "紀錄總數"
+// @Header 200 {string} X-Next-Cursor "下一頁游標，沒有下一頁時為空"
+// @Failure 403 {object} map[string]string "需要開局者權限"
+// @Router /organizer/matches/{id}/private/accesses [get]
+// @Security ApiKeyAuth
+func listPrivateAccesses(c *gin.Context) {
+	matchID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || matchID <= 0 {
+		c.Error(apperrors.NewValidationError("無效的配對局 ID"))
+		return
+	}
+
+	query := database.GlobalDB.Conn.Model(&models.MatchPrivateAccess{}).Where("match_private_accesses.match_id = ?", matchID)
+	accesses, err := Paginate(c, query, privateAccessListSpec)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, accesses)
+}
+
+// setSharedContacts 選擇要公開的聯絡方式
+// @Summary 選擇公開的聯絡方式
+// @Description 參與者選擇審核通過後要公開給此配對局開局者與其他已審核通過參與者的聯絡方式欄位 (email, phone, line_id, instagram)，空陣列表示不公開
+// @Tags 使用者
+// @Accept json
+// @Produce json
+// @Param id path int true "配對局ID"
+// @Param contacts body SharedContactsRequest true "要公開的欄位"
+// @Success 200 {object} MatchParticipant
+// @Failure 400 {object} map[string]string "無效的請求資料或沒有參與此配對局"
+// @Router /user/matches/{id}/contacts [put]
+// @Security ApiKeyAuth
+func setSharedContacts(c *gin.Context) {
+	matchID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || matchID <= 0 {
+		c.Error(apperrors.NewValidationError("無效的配對局 ID"))
+		return
+	}
+
+	var req SharedContactsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.NewValidationError("無效的請求資料"))
+		return
+	}
+	v := validator.New()
+	if err := v.Struct(&req); err != nil {
+		c.Error(apperrors.NewValidationError(err.Error()))
+		return
+	}
+
+	user, err := utils.GetAuthenticatedUser(c)
+	if err != nil {
+		c.Error(apperrors.NewUnauthorizedError("未登入"))
+		return
+	}
+
+	db := database.GlobalDB.Conn
+	var participant models.MatchParticipant
+	if err := db.Where("match_id = ? AND user_id = ?", matchID, user.ID).First(&participant).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Error(apperrors.NewValidationError("您沒有參與此配對局"))
+			return
+		}
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+
+	shared := strings.Join(req.Fields, ",")
+	if err := db.Model(&participant).Update("shared_contacts", shared).Error; err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+	participant.SharedContacts = shared
+	c.JSON(http.StatusOK, participant)
+}
+
+// getMyContact 取得自己的聯絡方式
+// @Summary 取得聯絡方式
+// @Description 取得自己的聯絡方式，只會在選擇公開的配對局中顯示給其他成員
+// @Tags 使用者
+// @Produce json
+// @Success 200 {object} UserContact
+// @Router /user/contact [get]
+// @Security ApiKeyAuth
+func getMyContact(c *gin.Context) {
+	user, err := utils.GetAuthenticatedUser(c)
+	if err != nil {
+		c.Error(apperrors.NewUnauthorizedError("未登入"))
+		return
+	}
+
+	contact := models.UserContact{UserID: user.ID}
+	if err := database.GlobalDB.Conn.Where("user_id = ?", user.ID).Limit(1).Find(&contact).Error; err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+	c.JSON(http.StatusOK, contact)
+}
+
+// updateMyContact 更新自己的聯絡方式
+// @Summary 更新聯絡方式
+// @Description 更新自己的電話、LINE ID 與 Instagram 帳號
+// @Tags 使用者
+// @Accept json
+// @Produce json
+// @Param contact body UserContact true "聯絡方式"
+// @Success 200 {object} UserContact
+// @Failure 400 {object} map[string]string "無效的請求資料"
+// @Router /user/contact [put]
+// @Security ApiKeyAuth
+func updateMyContact(c *gin.Context) {
+	var contact models.UserContact
+	if err := c.ShouldBindJSON(&contact); err != nil {
+		c.Error(apperrors.NewValidationError("無效的請求資料"))
+		return
+	}
+	v := validator.New()
+	if err := v.Struct(&contact); err != nil {
+		c.Error(apperrors.NewValidationError(err.Error()))
+		return
+	}
+
+	user, err := utils.GetAuthenticatedUser(c)
+	if err != nil {
+		c.Error(apperrors.NewUnauthorizedError("未登入"))
+		return
+	}
+
+	contact.UserID = user.ID
+	contact.UpdatedAt = time.Now()
+	err = database.GlobalDB.Conn.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"phone", "line_id", "instagram", "updated_at"}),
+	}).Create(&contact).Error
+	if err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+	c.JSON(http.StatusOK, contact)
+}
+
+// isMatchMember 判斷使用者是否為配對局的開局者、共同開局者或已審核通過的參與者
+func isMatchMember(db *gorm.DB, match *models.Match, userID int64) (bool, error) {
+	if match.OrganizerID == userID {
+		return true, nil
+	}
+	var count int64
+	if err := db.Model(&models.MatchCoOrganizer{}).Where("match_id = ? AND user_id = ?", match.ID, userID).Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return true, nil
+	}
+	err := db.Model(&models.MatchParticipant{}).
+		Where("match_id = ? AND user_id = ? AND status IN ?", match.ID, userID, models.ApprovedParticipantStatuses).
+		Count(&count).Error
+	return count > 0, err
+}
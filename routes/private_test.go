@@ -0,0 +1,74 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"free2free/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrivateDetailReveal(t *testing.T) {
+	db := setupUserTestDatabase(t)
+
+	organizer := seedUser(t, db, "organizer")
+	alice := seedUser(t, db, "alice")
+	bob := seedUser(t, db, "bob")
+	pending := seedUser(t, db, "pending")
+	match := seedMatch(t, db, organizer.ID)
+	for userID, status := range map[int64]string{alice.ID: "approved", bob.ID: "approved", pending.ID: "pending"} {
+		assert.NoError(t, db.Create(&models.MatchParticipant{MatchID: match.ID, UserID: userID, Status: status, JoinedAt: time.Now()}).Error)
+	}
+
+	call := func(handler gin.HandlerFunc, userID int64, body string) (*gin.Context, []byte) {
+		c, w := newUserContext("PUT", "/", []byte(body), userID)
+		c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(match.ID)}}
+		handler(c)
+		return c, w.Body.Bytes()
+	}
+
+	c, _ := call(setPrivateDetail, organizer.ID, `{"notes": "在側門集合", "contact_handle": "@organizer"}`)
+	assert.Empty(t, c.Errors)
+
+	// alice 公開 email 與 LINE，bob 沒有選擇公開
+	c, _ = call(updateMyContact, alice.ID, `{"phone": "0912345678", "line_id": "alice_line"}`)
+	assert.Empty(t, c.Errors)
+	c, _ = call(setSharedContacts, alice.ID, `{"fields": ["email", "line_id"]}`)
+	assert.Empty(t, c.Errors)
+	c, _ = call(setSharedContacts, alice.ID, `{"fields": ["address"]}`)
+	assert.NotEmpty(t, c.Errors)
+
+	// 配對局詳細資訊不包含私人集合資訊
+	c, body := call(getMatch, pending.ID, "")
+	assert.Empty(t, c.Errors)
+	assert.NotContains(t, string(body), "在側門集合")
+
+	c, _ = call(getPrivateDetail, pending.ID, "")
+	assert.NotEmpty(t, c.Errors)
+
+	c, body = call(getPrivateDetail, bob.ID, "")
+	assert.Empty(t, c.Errors)
+	var info MatchPrivateInfo
+	assert.NoError(t, json.Unmarshal(body, &info))
+	assert.Equal(t, "在側門集合", info.Notes)
+	assert.Equal(t, "@organizer", info.ContactHandle)
+	if assert.Len(t, info.Contacts, 1) {
+		assert.Equal(t, alice.ID, info.Contacts[0].UserID)
+		assert.Equal(t, alice.Email, info.Contacts[0].Email)
+		assert.Equal(t, "alice_line", info.Contacts[0].LineID)
+		assert.Empty(t, info.Contacts[0].Phone)
+	}
+
+	// 只記錄成功的查看
+	c, body = call(listPrivateAccesses, organizer.ID, "")
+	assert.Empty(t, c.Errors)
+	var accesses []models.MatchPrivateAccess
+	assert.NoError(t, json.Unmarshal(body, &accesses))
+	if assert.Len(t, accesses, 1) {
+		assert.Equal(t, bob.ID, accesses[0].UserID)
+	}
+}
@@ -0,0 +1,88 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"free2free/database"
+	"free2free/models"
+	"free2free/reliability"
+
+	apperrors "free2free/errors"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ErrCodeReliabilityTooLow 出席可信度低於配對局要求時回傳的錯誤代碼
+const ErrCodeReliabilityTooLow = "reliability_too_low"
+
+// UserProfile 使用者的公開資料
+type UserProfile struct {
+	ID          int64                   `json:"id"`
+	Name        string                  `json:"name"`
+	AvatarURL   string                  `json:"avatar_url"`
+	CreatedAt   int64                   `json:"created_at"`
+	Reliability reliability.Reliability `json:"reliability"`
+}
+
+// getUserProfile 取得使用者公開資料
+// @Summary 取得使用者公開資料
+// @Description 取得指定使用者的名稱、頭像與出席可信度
+// @Tags 使用者
+// @Produce json
+// @Param id path int true "使用者ID"
+// @Success 200 {object} UserProfile
+// @Failure 400 {object} map[string]string "無效的使用者 ID"
+// @Failure 404 {object} map[string]string "使用者不存在"
+// @Router /user/users/{id} [get]
+// @Security ApiKeyAuth
+func getUserProfile(c *gin.Context) {
+	userID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || userID <= 0 {
+		c.Error(apperrors.NewValidationError("無效的使用者 ID"))
+		return
+	}
+
+	db := database.GlobalDB.Conn
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Error(apperrors.NewAppError(http.StatusNotFound, "使用者不存在"))
+			return
+		}
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+
+	records, err := reliability.Load(db, []int64{user.ID})
+	if err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, UserProfile{
+		ID:          user.ID,
+		Name:        user.Name,
+		AvatarURL:   user.AvatarURL,
+		CreatedAt:   user.CreatedAt,
+		Reliability: records[user.ID],
+	})
+}
+
+// checkReliability 確認使用者的出席可信度達到配對局的要求
+func checkReliability(tx *gorm.DB, match *models.Match, userID int64) error {
+	if match.MinReliability <= 0 {
+		return nil
+	}
+
+	records, err := reliability.Load(tx, []int64{userID})
+	if err != nil {
+		return apperrors.MapGORMError(err)
+	}
+	if score := records[userID].Score; score != nil && *score < match.MinReliability {
+		return apperrors.NewCodedError(http.StatusForbidden, ErrCodeReliabilityTooLow, "您的出席可信度未達此配對局的要求")
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"free2free/models"
+
+	apperrors "free2free/errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReliabilityFromAttendance(t *testing.T) {
+	db := setupUserTestDatabase(t)
+
+	organizer := seedUser(t, db, "organizer")
+	flaky := seedUser(t, db, "flaky")
+	newcomer := seedUser(t, db, "newcomer")
+
+	// 已完成的配對局：開局者在期限內回報出席狀況
+	past := seedMatch(t, db, organizer.ID)
+	ended := time.Now().Add(-time.Hour)
+	assert.NoError(t, db.Model(&past).Updates(map[string]interface{}{"match_time": ended.Add(-2 * time.Hour), "end_time": ended, "status": "completed"}).Error)
+	participant := models.MatchParticipant{MatchID: past.ID, UserID: flaky.ID, Status: "approved", JoinedAt: ended}
+	assert.NoError(t, db.Create(&participant).Error)
+
+	report := func(status string) *apperrors.AppError {
+		c, _ := newUserContext("PUT", "/", []byte(fmt.Sprintf(`{"status": "%s"}`, status)), organizer.ID)
+		c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(past.ID)}, {Key: "participant_id", Value: fmt.Sprint(participant.ID)}}
+		reportAttendance(c)
+		if len(c.Errors) > 0 {
+			appErr, _ := c.Errors.Last().Err.(*apperrors.AppError)
+			return appErr
+		}
+		return nil
+	}
+	assert.NotNil(t, report("approved"))
+	assert.Nil(t, report("attended"))
+	// 期限內可以修正
+	assert.Nil(t, report("no_show"))
+
+	// 另外兩筆紀錄：一次出席、一次臨時取消
+	for _, status := range []string{"attended", "late_cancel"} {
+		other := seedMatch(t, db, organizer.ID)
+		assert.NoError(t, db.Create(&models.MatchParticipant{MatchID: other.ID, UserID: flaky.ID, Status: status, JoinedAt: ended}).Error)
+	}
+
+	profile := func(userID int64) UserProfile {
+		c, w := newUserContext("GET", "/", nil, organizer.ID)
+		c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(userID)}}
+		getUserProfile(c)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var p UserProfile
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+		return p
+	}
+	flakyProfile := profile(flaky.ID)
+	assert.Equal(t, int64(1), flakyProfile.Reliability.Attended)
+	assert.Equal(t, int64(1), flakyProfile.Reliability.NoShow)
+	assert.Equal(t, int64(1), flakyProfile.Reliability.LateCancel)
+	if assert.NotNil(t, flakyProfile.Reliability.Score) {
+		assert.Equal(t, 50, *flakyProfile.Reliability.Score)
+	}
+	assert.Nil(t, profile(newcomer.ID).Reliability.Score)
+
+	// 配對局要求最低可信度：紀錄不足的使用者被擋下，沒有紀錄的使用者可以參與
+	strict := seedMatch(t, db, organizer.ID)
+	assert.NoError(t, db.Model(&strict).Update("min_reliability", 80).Error)
+	join := func(userID int64) *apperrors.AppError {
+		c, _ := newUserContext("POST", "/", nil, userID)
+		c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(strict.ID)}}
+		joinMatch(c)
+		if len(c.Errors) > 0 {
+			appErr, _ := c.Errors.Last().Err.(*apperrors.AppError)
+			return appErr
+		}
+		return nil
+	}
+	appErr := join(flaky.ID)
+	if assert.NotNil(t, appErr) {
+		assert.Equal(t, ErrCodeReliabilityTooLow, appErr.ErrorCode)
+	}
+	assert.Nil(t, join(newcomer.ID))
+
+	// 開局者的配對局詳細資訊附上待審核參與者的可信度
+	c, w := newUserContext("GET", "/", nil, organizer.ID)
+	c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(strict.ID)}}
+	getMatch(c)
+	var detail MatchDetail
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &detail))
+	if assert.Len(t, detail.Participants, 1) {
+		assert.Equal(t, "pending", detail.Participants[0].Status)
+		if assert.NotNil(t, detail.Participants[0].Reliability) {
+			assert.Nil(t, detail.Participants[0].Reliability.Score)
+		}
+	}
+}
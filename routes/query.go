@@ -0,0 +1,249 @@
+package routes
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	apperrors "free2free/errors"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 列表查詢參數錯誤時回傳的錯誤代碼
+const (
+	ErrCodeInvalidLimit  = "invalid_limit"
+	ErrCodeInvalidCursor = "invalid_cursor"
+	ErrCodeInvalidSort   = "invalid_sort"
+	ErrCodeInvalidFilter = "invalid_filter"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// sortKind 排序欄位的型別，決定游標值如何編碼與還原
+type sortKind int
+
+const (
+	sortInt sortKind = iota
+	sortString
+	sortTime
+)
+
+// SortField 可供排序的欄位
+// Value 從查詢結果取出排序值，用來產生下一頁的游標
+type SortField[T any] struct {
+	Column string
+	Kind   sortKind
+	Value  func(T) interface{}
+}
+
+// ListSpec 描述一個列表端點可用的排序方式與主鍵
+// DefaultSort 使用與 sort 參數相同的格式，例如 "-id"
+type ListSpec[T any] struct {
+	IDColumn    string
+	ID          func(T) int64
+	Sorts       map[string]SortField[T]
+	DefaultSort string
+	Preloads    []string
+}
+
+// pageCursor 游標內容，序列化後以 base64 編碼回傳給客戶端
+type pageCursor struct {
+	Sort  string          `json:"s"`
+	Value json.RawMessage `json:"v"`
+	ID    int64           `json:"id"`
+}
+
+// Paginate 依 limit、cursor、sort 參數查詢一頁資料，並設定 X-Total-Count 與 X-Next-Cursor headers
+// query 應只包含篩選條件，排序與預加載由 spec 決定
+func Paginate[T any](c *gin.Context, query *gorm.DB, spec ListSpec[T]) ([]T, error) {
+	limit, err := parseLimit(c)
+	if err != nil {
+		return nil, err
+	}
+
+	sortParam := c.DefaultQuery("sort", spec.DefaultSort)
+	sortKey := strings.TrimPrefix(sortParam, "-")
+	desc := strings.HasPrefix(sortParam, "-")
+	field, ok := spec.Sorts[sortKey]
+	if !ok {
+		return nil, apperrors.NewCodedError(http.StatusBadRequest, ErrCodeInvalidSort, "無效的 sort 參數，可用欄位: "+strings.Join(sortKeys(spec.Sorts), ", "))
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Model(new(T)).Count(&total).Error; err != nil {
+		return nil, apperrors.MapGORMError(err)
+	}
+
+	page := query.Session(&gorm.Session{})
+	if raw := c.Query("cursor"); raw != "" {
+		value, id, err := decodeCursor(raw, sortParam, field.Kind)
+		if err != nil {
+			return nil, err
+		}
+		op := ">"
+		if desc {
+			op = "<"
+		}
+		page = page.Where(
+			fmt.Sprintf("(%s %s ? OR (%s = ? AND %s %s ?))", field.Column, op, field.Column, spec.IDColumn, op),
+			value, value, id,
+		)
+	}
+
+	direction := "ASC"
+	if desc {
+		direction = "DESC"
+	}
+	page = page.Order(fmt.Sprintf("%s %s, %s %s", field.Column, direction, spec.IDColumn, direction))
+	for _, preload := range spec.Preloads {
+		page = page.Preload(preload)
+	}
+
+	// 多取一筆以判斷是否還有下一頁
+	var items []T
+	if err := page.Limit(limit + 1).Find(&items).Error; err != nil {
+		return nil, apperrors.MapGORMError(err)
+	}
+
+	nextCursor := ""
+	if len(items) > limit {
+		items = items[:limit]
+		last := items[len(items)-1]
+		nextCursor, err = encodeCursor(sortParam, field.Kind, field.Value(last), spec.ID(last))
+		if err != nil {
+			return nil, apperrors.NewAppError(http.StatusInternalServerError, "無法產生分頁游標")
+		}
+	}
+
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+	c.Header("X-Next-Cursor", nextCursor)
+	return items, nil
+}
+
+func parseLimit(c *gin.Context) (int, error) {
+	raw := c.Query("limit")
+	if raw == "" {
+		return defaultPageLimit, nil
+	}
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit <= 0 || limit > maxPageLimit {
+		return 0, apperrors.NewCodedError(http.StatusBadRequest, ErrCodeInvalidLimit, fmt.Sprintf("limit 必須介於 1 到 %d 之間", maxPageLimit))
+	}
+	return limit, nil
+}
+
+func encodeCursor(sortParam string, kind sortKind, value interface{}, id int64) (string, error) {
+	if kind == sortTime {
+		value = value.(time.Time).Format(time.RFC3339Nano)
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(pageCursor{Sort: sortParam, Value: raw, ID: id})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(payload), nil
+}
+
+func decodeCursor(raw, sortParam string, kind sortKind) (interface{}, int64, error) {
+	invalid := apperrors.NewCodedError(http.StatusBadRequest, ErrCodeInvalidCursor, "無效的 cursor 參數")
+
+	payload, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, 0, invalid
+	}
+	var cursor pageCursor
+	if err := json.Unmarshal(payload, &cursor); err != nil {
+		return nil, 0, invalid
+	}
+	// 游標只能搭配產生它時的排序方式使用
+	if cursor.Sort != sortParam {
+		return nil, 0, invalid
+	}
+
+	switch kind {
+	case sortInt:
+		var v int64
+		if err := json.Unmarshal(cursor.Value, &v); err != nil {
+			return nil, 0, invalid
+		}
+		return v, cursor.ID, nil
+	case sortTime:
+		var s string
+		if err := json.Unmarshal(cursor.Value, &s); err != nil {
+			return nil, 0, invalid
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, 0, invalid
+		}
+		return t, cursor.ID, nil
+	default:
+		var s string
+		if err := json.Unmarshal(cursor.Value, &s); err != nil {
+			return nil, 0, invalid
+		}
+		return s, cursor.ID, nil
+	}
+}
+
+func sortKeys[T any](sorts map[string]SortField[T]) []string {
+	keys := make([]string, 0, len(sorts))
+	for key := range sorts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// queryInt64 解析選填的整數篩選參數
+func queryInt64(c *gin.Context, name string) (int64, bool, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return 0, false, nil
+	}
+	v, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || v <= 0 {
+		return 0, false, apperrors.NewCodedError(http.StatusBadRequest, ErrCodeInvalidFilter, "無效的 "+name+" 參數")
+	}
+	return v, true, nil
+}
+
+// queryTime 解析選填的 RFC3339 時間篩選參數
+func queryTime(c *gin.Context, name string) (time.Time, bool, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return time.Time{}, false, nil
+	}
+	v, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return time.Time{}, false, apperrors.NewCodedError(http.StatusBadRequest, ErrCodeInvalidFilter, "無效的 "+name+" 參數，需為 RFC3339 格式")
+	}
+	return v, true, nil
+}
+
+// queryEnum 解析選填且必須為指定值之一的篩選參數
+func queryEnum(c *gin.Context, name string, allowed ...string) (string, bool, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return "", false, nil
+	}
+	for _, v := range allowed {
+		if raw == v {
+			return raw, true, nil
+		}
+	}
+	return "", false, apperrors.NewCodedError(http.StatusBadRequest, ErrCodeInvalidFilter, fmt.Sprintf("無效的 %s 參數，可用值: %s", name, strings.Join(allowed, ", ")))
+}
@@ -0,0 +1,101 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"free2free/models"
+
+	apperrors "free2free/errors"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPaginateFollowsCursor(t *testing.T) {
+	db := setupUserTestDatabase(t)
+
+	for i := 1; i <= 5; i++ {
+		location := models.Location{Name: fmt.Sprintf("門市 %d", i), Address: "台北市", Latitude: 25, Longitude: 121}
+		assert.NoError(t, db.Create(&location).Error)
+	}
+
+	var names []string
+	cursor := ""
+	for page := 0; page < 3; page++ {
+		c, w := newUserContext("GET", "/?sort=name&limit=2&cursor="+cursor, nil, 1)
+		listLocations(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "5", w.Header().Get("X-Total-Count"))
+
+		var locations []models.Location
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &locations))
+		for _, l := range locations {
+			names = append(names, l.Name)
+		}
+		cursor = w.Header().Get("X-Next-Cursor")
+	}
+
+	assert.Equal(t, []string{"門市 1", "門市 2", "門市 3", "門市 4", "門市 5"}, names)
+	assert.Empty(t, cursor)
+}
+
+func TestPaginateTimeSortDescending(t *testing.T) {
+	db := setupUserTestDatabase(t)
+	organizer := seedUser(t, db, "organizer")
+	base := seedMatch(t, db, organizer.ID)
+
+	// 相同 match_time 的配對局需依 id 決定順序，避免跨頁重複或遺漏
+	start := base.MatchTime
+	for i := 0; i < 4; i++ {
+		m := models.Match{ActivityID: base.ActivityID, OrganizerID: organizer.ID, MatchTime: start.Add(time.Duration(i/2) * time.Hour), EndTime: start.Add(5 * time.Hour), Status: "open"}
+		assert.NoError(t, db.Create(&m).Error)
+	}
+
+	seen := map[int64]bool{}
+	cursor := ""
+	for page := 0; page < 5; page++ {
+		c, w := newUserContext("GET", "/?sort=-match_time&limit=2&cursor="+cursor, nil, organizer.ID)
+		listMatches(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var matches []models.Match
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &matches))
+		for _, m := range matches {
+			assert.False(t, seen[m.ID], "match %d returned twice", m.ID)
+			seen[m.ID] = true
+		}
+		cursor = w.Header().Get("X-Next-Cursor")
+		if cursor == "" {
+			break
+		}
+	}
+	assert.Len(t, seen, 5)
+}
+
+func TestPaginateRejectsBadParameters(t *testing.T) {
+	setupUserTestDatabase(t)
+
+	cases := map[string]string{
+		"/?limit=0":             ErrCodeInvalidLimit,
+		"/?limit=abc":           ErrCodeInvalidLimit,
+		"/?sort=address":        ErrCodeInvalidSort,
+		"/?cursor=not-a-cursor": ErrCodeInvalidCursor,
+	}
+
+	for path, code := range cases {
+		c, _ := newUserContext("GET", path, nil, 1)
+		listLocations(c)
+
+		if assert.Len(t, c.Errors, 1, path) {
+			appErr, ok := c.Errors.Last().Err.(*apperrors.AppError)
+			if assert.True(t, ok, path) {
+				assert.Equal(t, http.StatusBadRequest, appErr.Code, path)
+				assert.Equal(t, code, appErr.ErrorCode, path)
+			}
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"free2free/database"
+	"free2free/models"
+	"free2free/quota"
+	"free2free/utils"
+
+	apperrors "free2free/errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrCodeQuotaExceeded 超過使用者配額時回傳的錯誤代碼
+const ErrCodeQuotaExceeded = "quota_exceeded"
+
+// QuotaStatus 使用者目前適用的配額與使用量
+type QuotaStatus = quota.Status
+
+// QuotaOverrideRequest 管理員調整使用者配額的請求，未填寫的欄位沿用預設配額，0 表示不限制
+type QuotaOverrideRequest struct {
+	OpenMatches     *int       `json:"open_matches" validate:"omitempty,min=0,max=1000"`
+	PendingRequests *int       `json:"pending_requests" validate:"omitempty,min=0,max=1000"`
+	DailyMatches    *int       `json:"daily_matches" validate:"omitempty,min=0,max=1000"`
+	DailyJoins      *int       `json:"daily_joins" validate:"omitempty,min=0,max=1000"`
+	Note            string     `json:"note" validate:"required,max=500"`
+	ExpiresAt       *time.Time `json:"expires_at"`
+}
+
+// getMyQuota 取得自己的配額
+// @Summary 取得自己的配額
+// @Description 取得目前適用的配額與使用量，limits 中的 0 表示不限制
+// @Tags 使用者
+// @Produce json
+// @Success 200 {object} QuotaStatus
+// @Failure 401 {object} map[string]string "未登入"
+// @Router /user/quota [get]
+// @Security ApiKeyAuth
+func getMyQuota(c *gin.Context) {
+	user, err := utils.GetAuthenticatedUser(c)
+	if err != nil {
+		c.Error(apperrors.NewUnauthorizedError("未登入"))
+		return
+	}
+
+	status, err := quota.Load(database.GlobalDB.Conn, user.ID, time.Now())
+	if err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// getUserQuota 取得使用者的配額
+// @Summary 取得使用者的配額
+// @Description 管理員查看使用者目前適用的配額、使用量與調整紀錄 (包含已過期的調整)
+// @Tags 管理員
+// @Produce json
+// @Param id path int true "使用者ID"
+// @Success 200 {object} QuotaStatus
+// @Failure 400 {object} map[string]string "無效的使用者 ID"
+// @Failure 404 {object} map[string]string "使用者不存在"
+// @Router /admin/users/{id}/quota [get]
+// @Security ApiKeyAuth
+func getUserQuota(c *gin.Context) {
+	userID, ok := quotaUserID(c)
+	if !ok {
+		return
+	}
+
+	status, err := quota.Load(database.GlobalDB.Conn, userID, time.Now())
+	if err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// setUserQuota 調整使用者的配額
+// @Summary 調整使用者的配額
+// @Description 管理員為個別使用者設定配額，取代先前的調整；未填寫的欄位沿用預設配額，0 表示不限制，expires_at 過後恢復預設配額
+// @Tags 管理員
+// @Accept json
+// @Produce json
+// @Param id path int true "使用者ID"
+// @Param quota body QuotaOverrideRequest true "配額調整"
+// @Success 200 {object} QuotaStatus
+// @Failure 400 {object} map[string]string "無效的請求資料"
+// @Failure 404 {object} map[string]string "使用者不存在"
+// @Router /admin/users/{id}/quota [put]
+// @Security ApiKeyAuth
+func setUserQuota(c *gin.Context) {
+	userID, ok := quotaUserID(c)
+	if !ok {
+		return
+	}
+
+	var req QuotaOverrideRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.NewValidationError("無效的請求資料"))
+		return
+	}
+	v := validator.New()
+	if err := v.Struct(&req); err != nil {
+		c.Error(apperrors.NewValidationError(err.Error()))
+		return
+	}
+	now := time.Now()
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(now) {
+		c.Error(apperrors.NewValidationError("到期時間必須晚於現在"))
+		return
+	}
+
+	admin, err := utils.GetAuthenticatedUser(c)
+	if err != nil {
+		c.Error(apperrors.NewUnauthorizedError("未登入"))
+		return
+	}
+
+	override := models.UserQuotaOverride{
+		UserID:          userID,
+		OpenMatches:     req.OpenMatches,
+		PendingRequests: req.PendingRequests,
+		DailyMatches:    req.DailyMatches,
+		DailyJoins:      req.DailyJoins,
+		Note:            req.Note,
+		ExpiresAt:       req.ExpiresAt,
+		GrantedBy:       admin.ID,
+		UpdatedAt:       now,
+	}
+	db := database.GlobalDB.Conn
+	err = db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"open_matches", "pending_requests", "daily_matches", "daily_joins", "note", "expires_at", "granted_by", "updated_at"}),
+	}).Create(&override).Error
+	if err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+
+	status, err := quota.Load(db, userID, now)
+	if err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// deleteUserQuota 移除使用者的配額調整
+// @Summary 移除使用者的配額調整
+// @Description 移除管理員的調整，使用者恢復預設配額
+// @Tags 管理員
+// @Produce json
+// @Param id path int true "使用者ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string "無效的使用者 ID"
+// @Failure 404 {object} map[string]string "使用者不存在或沒有配額調整"
+// @Router /admin/users/{id}/quota [delete]
+// @Security ApiKeyAuth
+func deleteUserQuota(c *gin.Context) {
+	userID, ok := quotaUserID(c)
+	if !ok {
+		return
+	}
+
+	result := database.GlobalDB.Conn.Where("user_id = ?", userID).Delete(&models.UserQuotaOverride{})
+	if result.Error != nil {
+		c.Error(apperrors.MapGORMError(result.Error))
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.Error(apperrors.NewAppError(http.StatusNotFound, "使用者沒有配額調整"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "已恢復預設配額"})
+}
+
+// quotaUserID 解析路徑中的使用者 ID 並確認使用者存在
+func quotaUserID(c *gin.Context) (int64, bool) {
+	userID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || userID <= 0 {
+		c.Error(apperrors.NewValidationError("無效的使用者 ID"))
+		return 0, false
+	}
+
+	var user models.User
+	if err := database.GlobalDB.Conn.Select("id").First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Error(apperrors.NewAppError(http.StatusNotFound, "使用者不存在"))
+			return 0, false
+		}
+		c.Error(apperrors.MapGORMError(err))
+		return 0, false
+	}
+	return userID, true
+}
+
+// checkCreateMatchQuota 在建立配對局的交易中確認沒有超過開局配額
+func checkCreateMatchQuota(tx *gorm.DB, userID int64) error {
+	return quotaError(quota.CheckCreateMatch(tx, userID))
+}
+
+// checkJoinQuota 在參與配對局的交易中確認沒有超過參與配額
+func checkJoinQuota(tx *gorm.DB, userID int64) error {
+	return quotaError(quota.CheckJoin(tx, userID))
+}
+
+// quotaError 將超過配額轉換為 429 與 quota_exceeded 錯誤代碼
+func quotaError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var exceeded *quota.ExceededError
+	if errors.As(err, &exceeded) {
+		return apperrors.NewCodedError(http.StatusTooManyRequests, ErrCodeQuotaExceeded, exceeded.Message)
+	}
+	return apperrors.MapGORMError(err)
+}
@@ -0,0 +1,129 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"free2free/models"
+
+	apperrors "free2free/errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserQuotaLimitsAndOverrides(t *testing.T) {
+	db := setupUserTestDatabase(t)
+
+	admin := seedUser(t, db, "admin")
+	assert.NoError(t, db.Model(&admin).Update("is_admin", true).Error)
+	organizer := seedUser(t, db, "organizer")
+	alice := seedUser(t, db, "alice")
+	seeded := seedMatch(t, db, admin.ID)
+
+	start := time.Now().Add(48 * time.Hour).UTC().Format(time.RFC3339)
+	create := func() *gin.Context {
+		body := fmt.Sprintf(`{"activity_id": %d, "organizer_id": 1, "status": "open", "match_time": "%s"}`, seeded.ActivityID, start)
+		c, _ := newUserContext("POST", "/", []byte(body), organizer.ID)
+		createMatch(c)
+		return c
+	}
+	quotaError := func(c *gin.Context) *apperrors.AppError {
+		if !assert.NotEmpty(t, c.Errors) {
+			return nil
+		}
+		appErr, _ := c.Errors.Last().Err.(*apperrors.AppError)
+		return appErr
+	}
+	setOverride := func(userID int64, body string) *gin.Context {
+		c, _ := newUserContext("PUT", "/", []byte(body), admin.ID)
+		c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(userID)}}
+		setUserQuota(c)
+		return c
+	}
+
+	// 新帳號 24 小時內只能建立 NewAccountQuota.DailyMatches 場配對局
+	for i := 0; i < models.NewAccountQuota.DailyMatches; i++ {
+		assert.Empty(t, create().Errors)
+	}
+	if appErr := quotaError(create()); appErr != nil {
+		assert.Equal(t, http.StatusTooManyRequests, appErr.Code)
+		assert.Equal(t, ErrCodeQuotaExceeded, appErr.ErrorCode)
+	}
+
+	c, w := newUserContext("GET", "/", nil, organizer.ID)
+	getMyQuota(c)
+	var status QuotaStatus
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.True(t, status.NewAccount)
+	assert.Equal(t, models.NewAccountQuota, status.Limits)
+	assert.Equal(t, models.NewAccountQuota.DailyMatches, status.Usage.DailyMatches)
+	assert.Equal(t, models.NewAccountQuota.DailyMatches, status.Usage.OpenMatches)
+
+	// 管理員調整後不再限制，0 表示不限制
+	assert.NotEmpty(t, setOverride(organizer.ID, `{"daily_matches": 0}`).Errors, "理由為必填")
+	assert.Empty(t, setOverride(organizer.ID, `{"daily_matches": 0, "note": "合作店家"}`).Errors)
+	assert.Empty(t, create().Errors)
+
+	// 移除調整後恢復預設配額
+	c, _ = newUserContext("DELETE", "/", nil, admin.ID)
+	c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(organizer.ID)}}
+	deleteUserQuota(c)
+	assert.Empty(t, c.Errors)
+	assert.NotNil(t, quotaError(create()))
+
+	// 建立週期系列同樣計入開局配額
+	body := fmt.Sprintf(`{"activity_id": %d, "rule": "FREQ=DAILY", "start_time": "%s", "until": "%s"}`,
+		seeded.ActivityID, start, time.Now().AddDate(0, 1, 0).UTC().Format(time.RFC3339))
+	c, _ = newUserContext("POST", "/", []byte(body), organizer.ID)
+	createSeries(c)
+	if appErr := quotaError(c); appErr != nil {
+		assert.Equal(t, ErrCodeQuotaExceeded, appErr.ErrorCode)
+	}
+
+	// 等待審核的申請數也受限制
+	assert.Empty(t, setOverride(alice.ID, `{"pending_requests": 1, "note": "測試"}`).Errors)
+	join := func(matchID int64) *gin.Context {
+		c, _ := newUserContext("POST", "/", nil, alice.ID)
+		c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(matchID)}}
+		joinMatch(c)
+		return c
+	}
+	other := seedMatch(t, db, admin.ID)
+	assert.Empty(t, join(seeded.ID).Errors)
+	if appErr := quotaError(join(other.ID)); appErr != nil {
+		assert.Equal(t, ErrCodeQuotaExceeded, appErr.ErrorCode)
+	}
+
+	// 透過邀請連結加入同樣檢查參與配額
+	t.Setenv("JWT_SECRET", "test-secret-key-for-invite-signatures")
+	assert.Empty(t, setOverride(alice.ID, `{"daily_joins": 1, "note": "測試"}`).Errors)
+	c, w = newUserContext("POST", "/", []byte(`{}`), admin.ID)
+	c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(other.ID)}}
+	createInvite(c)
+	var link InviteLink
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &link))
+	c, _ = newUserContext("POST", "/", nil, alice.ID)
+	c.Params = gin.Params{{Key: "token", Value: link.Token}}
+	acceptInvite(c)
+	if appErr := quotaError(c); appErr != nil {
+		assert.Equal(t, ErrCodeQuotaExceeded, appErr.ErrorCode)
+	}
+
+	// 完成足夠的配對局後改用一般配額
+	for i := 0; i < models.TrustedAfterCompletedMatches; i++ {
+		past := time.Now().Add(-48 * time.Hour)
+		assert.NoError(t, db.Create(&models.Match{ActivityID: seeded.ActivityID, OrganizerID: organizer.ID, MatchTime: past, EndTime: past.Add(time.Hour), Status: "completed"}).Error)
+	}
+	c, w = newUserContext("GET", "/", nil, admin.ID)
+	c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(organizer.ID)}}
+	getUserQuota(c)
+	status = QuotaStatus{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.False(t, status.NewAccount)
+	assert.Equal(t, models.DefaultUserQuota, status.Limits)
+	assert.Nil(t, status.Override)
+}
@@ -0,0 +1,216 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"free2free/database"
+	"free2free/models"
+	"free2free/utils"
+
+	apperrors "free2free/errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 被移除或封鎖的使用者參與配對局時回傳的錯誤代碼
+const (
+	ErrCodeRemovedFromMatch   = "removed_from_match"
+	ErrCodeBlockedByOrganizer = "blocked_by_organizer"
+)
+
+// RemoveParticipantRequest 移除參與者的請求
+type RemoveParticipantRequest struct {
+	Reason string `json:"reason" validate:"required,max=500"`
+	// Block 同時封鎖此使用者，不能再參與這位開局者的配對局
+	Block bool `json:"block"`
+}
+
+// removeParticipant 移除參與者
+// @Summary 移除參與者
+// @Description 開局者將已審核通過的參與者移出尚未結束的配對局並說明理由，被移除的使用者不能再參與此配對局；block 為 true 時同時封鎖，之後也不能參與這位開局者的其他配對局
+// @Tags 開局者
+// @Accept json
+// @Produce json
+// @Param id path int true "配對局ID"
+// @Param participant_id path int true "參與者ID"
+// @Param removal body RemoveParticipantRequest true "移除理由"
+// @Success 200 {object} MatchParticipant
+// @Failure 400 {object} map[string]string "無效的請求資料、參與者未審核通過或配對局已結束"
+// @Failure 403 {object} map[string]string "需要開局者權限"
+// @Router /organizer/matches/{id}/participants/{participant_id}/remove [put]
+// @Security ApiKeyAuth
+func removeParticipant(c *gin.Context) {
+	matchID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || matchID <= 0 {
+		c.Error(apperrors.NewValidationError("無效的配對局 ID"))
+		return
+	}
+
+	participantID, err := strconv.ParseInt(c.Param("participant_id"), 10, 64)
+	if err != nil || participantID <= 0 {
+		c.Error(apperrors.NewValidationError("無效的參與者 ID"))
+		return
+	}
+
+	var req RemoveParticipantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.NewValidationError("無效的請求資料"))
+		return
+	}
+	v := validator.New()
+	if err := v.Struct(&req); err != nil {
+		c.Error(apperrors.NewValidationError(err.Error()))
+		return
+	}
+
+	user, err := utils.GetAuthenticatedUser(c)
+	if err != nil {
+		c.Error(apperrors.NewUnauthorizedError("未登入"))
+		return
+	}
+
+	var participant models.MatchParticipant
+	err = database.GlobalDB.Conn.Transaction(func(tx *gorm.DB) error {
+		var match models.Match
+		if err := tx.Preload("Activity").First(&match, matchID).Error; err != nil {
+			return apperrors.MapGORMError(err)
+		}
+		if match.Status != "open" {
+			return apperrors.NewValidationError("只能從進行中的配對局移除參與者")
+		}
+
+		if err := tx.Where("id = ? AND match_id = ?", participantID, matchID).First(&participant).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return apperrors.NewValidationError("指定的參與者不存在或不屬於此配對局")
+			}
+			return apperrors.MapGORMError(err)
+		}
+
+		// 只更新仍為已審核通過 (或等待重新確認) 的紀錄，避免與參與者的回覆衝突
+		result := tx.Model(&models.MatchParticipant{}).
+			Where("id = ? AND status IN ?", participant.ID, []string{"approved", "reconfirm"}).
+			Updates(map[string]interface{}{"status": "removed", "reconfirm_deadline": nil, "rejection_code": "", "rejection_reason": req.Reason})
+		if result.Error != nil {
+			return apperrors.MapGORMError(result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return apperrors.NewValidationError("只能移除已審核通過的參與者")
+		}
+		if err := recordEvent(tx, models.NewParticipantEvent(&participant, participant.Status, "removed", user.ID, req.Reason)); err != nil {
+			return err
+		}
+		participant.Status = "removed"
+		participant.ReconfirmDeadline = nil
+		participant.RejectionCode = ""
+		participant.RejectionReason = req.Reason
+
+		// 被移除的參與者同時失去共同開局者身份
+		if err := tx.Where("match_id = ? AND user_id = ?", match.ID, participant.UserID).Delete(&models.MatchCoOrganizer{}).Error; err != nil {
+			return apperrors.MapGORMError(err)
+		}
+
+		if req.Block {
+			block := models.OrganizerBlock{OrganizerID: match.OrganizerID, UserID: participant.UserID, MatchID: &match.ID, Reason: req.Reason, CreatedAt: time.Now()}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&block).Error; err != nil {
+				return apperrors.MapGORMError(err)
+			}
+		}
+
+		notification := models.Notification{
+			UserID:    participant.UserID,
+			Type:      models.NotificationRemoved,
+			MatchID:   &match.ID,
+			Message:   fmt.Sprintf("您已被開局者移出配對局「%s」：%s", match.Activity.Title, req.Reason),
+			CreatedAt: time.Now(),
+		}
+		if err := tx.Create(&notification).Error; err != nil {
+			return apperrors.MapGORMError(err)
+		}
+		return nil
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, participant)
+}
+
+// listBlocks 取得自己的封鎖名單
+// @Summary 封鎖名單
+// @Description 列出當前使用者以開局者身份封鎖的使用者
+// @Tags 開局者
+// @Produce json
+// @Success 200 {array} OrganizerBlock
+// @Failure 401 {object} map[string]string "未登入"
+// @Router /organizer/blocks [get]
+// @Security ApiKeyAuth
+func listBlocks(c *gin.Context) {
+	user, err := utils.GetAuthenticatedUser(c)
+	if err != nil {
+		c.Error(apperrors.NewUnauthorizedError("未登入"))
+		return
+	}
+
+	blocks := []models.OrganizerBlock{}
+	if err := database.GlobalDB.Conn.Preload("User").Where("organizer_id = ?", user.ID).Order("id DESC").Find(&blocks).Error; err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+	c.JSON(http.StatusOK, blocks)
+}
+
+// unblockUser 解除封鎖
+// @Summary 解除封鎖
+// @Description 將使用者從自己的封鎖名單移除；之前被移出的配對局仍然不能再參與
+// @Tags 開局者
+// @Produce json
+// @Param user_id path int true "使用者ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string "無效的使用者 ID"
+// @Failure 404 {object} map[string]string "使用者不在封鎖名單中"
+// @Router /organizer/blocks/{user_id} [delete]
+// @Security ApiKeyAuth
+func unblockUser(c *gin.Context) {
+	blockedID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil || blockedID <= 0 {
+		c.Error(apperrors.NewValidationError("無效的使用者 ID"))
+		return
+	}
+
+	user, err := utils.GetAuthenticatedUser(c)
+	if err != nil {
+		c.Error(apperrors.NewUnauthorizedError("未登入"))
+		return
+	}
+
+	result := database.GlobalDB.Conn.Where("organizer_id = ? AND user_id = ?", user.ID, blockedID).Delete(&models.OrganizerBlock{})
+	if result.Error != nil {
+		c.Error(apperrors.MapGORMError(result.Error))
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.Error(apperrors.NewAppError(http.StatusNotFound, "使用者不在封鎖名單中"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "已解除封鎖"})
+}
+
+// checkOrganizerBlock 確認使用者沒有被開局者封鎖
+func checkOrganizerBlock(tx *gorm.DB, organizerID, userID int64) error {
+	var blocked int64
+	if err := tx.Model(&models.OrganizerBlock{}).Where("organizer_id = ? AND user_id = ?", organizerID, userID).Count(&blocked).Error; err != nil {
+		return apperrors.MapGORMError(err)
+	}
+	if blocked > 0 {
+		return apperrors.NewCodedError(http.StatusForbidden, ErrCodeBlockedByOrganizer, "您無法參與此開局者的配對局")
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"free2free/models"
+
+	apperrors "free2free/errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemoveParticipantAndBlock(t *testing.T) {
+	db := setupUserTestDatabase(t)
+
+	organizer := seedUser(t, db, "organizer")
+	alice := seedUser(t, db, "alice")
+	match := seedMatch(t, db, organizer.ID)
+	other := models.Match{ActivityID: match.ActivityID, OrganizerID: organizer.ID, MatchTime: match.MatchTime.Add(48 * time.Hour), EndTime: match.EndTime.Add(48 * time.Hour), Status: "open"}
+	assert.NoError(t, db.Create(&other).Error)
+
+	participant := models.MatchParticipant{MatchID: match.ID, UserID: alice.ID, Status: "approved", JoinedAt: time.Now()}
+	assert.NoError(t, db.Create(&participant).Error)
+
+	join := func(matchID int64) *gin.Context {
+		c, _ := newUserContext("POST", "/", nil, alice.ID)
+		c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(matchID)}}
+		joinMatch(c)
+		return c
+	}
+	errorCode := func(c *gin.Context) string {
+		if assert.NotEmpty(t, c.Errors) {
+			if appErr, ok := c.Errors.Last().Err.(*apperrors.AppError); ok {
+				return appErr.ErrorCode
+			}
+		}
+		return ""
+	}
+
+	// 理由為必填
+	c, _ := newUserContext("PUT", "/", []byte(`{"block": true}`), organizer.ID)
+	c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(match.ID)}, {Key: "participant_id", Value: fmt.Sprint(participant.ID)}}
+	removeParticipant(c)
+	assert.NotEmpty(t, c.Errors)
+
+	c, w := newUserContext("PUT", "/", []byte(`{"reason": "在對話串騷擾其他參與者", "block": true}`), organizer.ID)
+	c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(match.ID)}, {Key: "participant_id", Value: fmt.Sprint(participant.ID)}}
+	removeParticipant(c)
+	assert.Empty(t, c.Errors)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var removed models.MatchParticipant
+	assert.NoError(t, db.First(&removed, participant.ID).Error)
+	assert.Equal(t, "removed", removed.Status)
+	assert.Equal(t, "在對話串騷擾其他參與者", removed.RejectionReason)
+
+	var notifications int64
+	assert.NoError(t, db.Model(&models.Notification{}).Where("user_id = ? AND type = ?", alice.ID, models.NotificationRemoved).Count(&notifications).Error)
+	assert.Equal(t, int64(1), notifications)
+
+	// 被移除後不能再參與同一個配對局，被封鎖後也不能參與開局者的其他配對局
+	assert.Equal(t, ErrCodeRemovedFromMatch, errorCode(join(match.ID)))
+	assert.Equal(t, ErrCodeBlockedByOrganizer, errorCode(join(other.ID)))
+
+	c, w = newUserContext("GET", "/", nil, organizer.ID)
+	listBlocks(c)
+	var blocks []models.OrganizerBlock
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &blocks))
+	if assert.Len(t, blocks, 1) {
+		assert.Equal(t, alice.ID, blocks[0].UserID)
+	}
+
+	// 解除封鎖後可以參與其他配對局，但仍不能回到被移除的配對局
+	c, _ = newUserContext("DELETE", "/", nil, organizer.ID)
+	c.Params = gin.Params{{Key: "user_id", Value: fmt.Sprint(alice.ID)}}
+	unblockUser(c)
+	assert.Empty(t, c.Errors)
+
+	assert.Empty(t, join(other.ID).Errors)
+	assert.Equal(t, ErrCodeRemovedFromMatch, errorCode(join(match.ID)))
+}
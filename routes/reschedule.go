@@ -0,0 +1,404 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"free2free/database"
+	"free2free/models"
+	"free2free/utils"
+
+	apperrors "free2free/errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// materialTimeShift 開始或結束時間移動超過此值視為重大變更，已審核通過的參與者需要重新確認
+	materialTimeShift = time.Hour
+	// reconfirmWindow 重新確認的期限，最晚不超過新的配對時間
+	reconfirmWindow = 24 * time.Hour
+)
+
+// UpdateMatchRequest 修改配對局的請求，只更新有提供的欄位；只改 match_time 時 end_time 隨之平移
+type UpdateMatchRequest struct {
+	MatchTime *time.Time `json:"match_time"`
+	EndTime   *time.Time `json:"end_time"`
+	Notes     *string    `json:"notes" validate:"omitempty,max=1000"`
+	Capacity  *int       `json:"capacity" validate:"omitempty,min=1,max=100"`
+}
+
+// UpdateMatchResult 修改配對局的結果
+type UpdateMatchResult struct {
+	Match             models.Match         `json:"match"`
+	Changes           []models.MatchChange `json:"changes"`
+	ReconfirmRequired int64                `json:"reconfirm_required"`
+}
+
+// ReconfirmRequest 參與者回覆是否仍參加變更後的配對局
+type ReconfirmRequest struct {
+	Accept *bool `json:"accept" validate:"required"`
+}
+
+// updateMatch 修改配對局
+// @Summary 修改配對局
+// @Description 開局者修改尚未開始的配對局時間、備註或名額。開始或結束時間移動超過 1 小時時，已審核通過的參與者需在期限 (24 小時內且不晚於新的配對時間) 前重新確認，未確認者會被釋出。所有變更都會記錄並通知參與者
+// @Tags 開局者
+// @Accept json
+// @Produce json
+// @Param id path int true "配對局ID"
+// @Param match body UpdateMatchRequest true "要修改的欄位"
+// @Success 200 {object} UpdateMatchResult
+// @Failure 400 {object} map[string]string "無效的請求資料、配對局已開始或名額少於已審核通過的人數，或新時間不在活動的優惠期間與可用時段內 (error_code: outside_activity_schedule)"
+// @Failure 403 {object} map[string]string "需要開局者權限"
+// @Router /organizer/matches/{id} [patch]
+// @Security ApiKeyAuth
+func updateMatch(c *gin.Context) {
+	matchID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || matchID <= 0 {
+		c.Error(apperrors.NewValidationError("無效的配對局 ID"))
+		return
+	}
+
+	var req UpdateMatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.NewValidationError("無效的請求資料"))
+		return
+	}
+	v := validator.New()
+	if err := v.Struct(&req); err != nil {
+		c.Error(apperrors.NewValidationError(err.Error()))
+		return
+	}
+
+	user, err := utils.GetAuthenticatedUser(c)
+	if err != nil {
+		c.Error(apperrors.NewUnauthorizedError("未登入"))
+		return
+	}
+
+	var result UpdateMatchResult
+	err = database.GlobalDB.Conn.Transaction(func(tx *gorm.DB) error {
+		result, err = rescheduleMatch(tx, matchID, &req, user.ID)
+		return err
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if result.Changes == nil {
+		result.Changes = []models.MatchChange{}
+	}
+	database.GlobalDB.Conn.Preload("Activity").Preload("Organizer").First(&result.Match, result.Match.ID)
+	c.JSON(http.StatusOK, result)
+}
+
+// rescheduleMatch 鎖定並修改尚未開始的配對局，記錄變更並通知參與者；重大變更時參與者需重新確認
+func rescheduleMatch(tx *gorm.DB, matchID int64, req *UpdateMatchRequest, actorID int64) (UpdateMatchResult, error) {
+	var result UpdateMatchResult
+	var match models.Match
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&match, matchID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return result, apperrors.NewAppError(http.StatusNotFound, "配對局不存在")
+		}
+		return result, apperrors.MapGORMError(err)
+	}
+	now := time.Now()
+	if match.Status != "open" || !match.MatchTime.After(now) {
+		return result, apperrors.NewValidationError("只能修改尚未開始的配對局")
+	}
+
+	updates, changes, material, err := matchChanges(tx, &match, req, now)
+	if err != nil {
+		return result, err
+	}
+	if len(changes) == 0 {
+		result.Match = match
+		return result, nil
+	}
+
+	if err := tx.Model(&match).Updates(updates).Error; err != nil {
+		return result, apperrors.MapGORMError(err)
+	}
+	for i := range changes {
+		changes[i].MatchID = match.ID
+		changes[i].ChangedBy = actorID
+		changes[i].Material = material
+		changes[i].CreatedAt = now
+	}
+	if err := tx.Create(&changes).Error; err != nil {
+		return result, apperrors.MapGORMError(err)
+	}
+	result.Changes = changes
+
+	reconfirm, err := notifyMatchChange(tx, &match, material, actorID, now)
+	if err != nil {
+		return result, apperrors.MapGORMError(err)
+	}
+	result.ReconfirmRequired = reconfirm
+
+	result.Match = match
+	return result, nil
+}
+
+// matchChanges 比較請求與目前的配對局，回傳要更新的欄位、變更紀錄與是否為重大變更
+func matchChanges(tx *gorm.DB, match *models.Match, req *UpdateMatchRequest, now time.Time) (map[string]interface{}, []models.MatchChange, bool, error) {
+	updates := map[string]interface{}{}
+	var changes []models.MatchChange
+	material := false
+
+	newStart, newEnd := match.MatchTime, match.EndTime
+	if req.MatchTime != nil {
+		newStart = *req.MatchTime
+		newEnd = match.EndTime.Add(newStart.Sub(match.MatchTime))
+	}
+	if req.EndTime != nil {
+		newEnd = *req.EndTime
+	}
+	if !newStart.Equal(match.MatchTime) || !newEnd.Equal(match.EndTime) {
+		if !newStart.After(now) {
+			return nil, nil, false, apperrors.NewValidationError("配對時間必須在未來")
+		}
+		if !newEnd.After(newStart) {
+			return nil, nil, false, apperrors.NewValidationError("結束時間必須晚於配對時間")
+		}
+		var activity models.Activity
+		if err := tx.Preload("Availability").First(&activity, match.ActivityID).Error; err != nil {
+			return nil, nil, false, apperrors.MapGORMError(err)
+		}
+		if activity.ArchivedAt != nil {
+			return nil, nil, false, apperrors.NewCodedError(http.StatusBadRequest, ErrCodeOutsideActivitySchedule, "活動優惠已結束")
+		}
+		if err := activity.CheckSchedule(newStart, newEnd); err != nil {
+			return nil, nil, false, apperrors.NewCodedError(http.StatusBadRequest, ErrCodeOutsideActivitySchedule, err.Error())
+		}
+		if !newStart.Equal(match.MatchTime) {
+			updates["match_time"] = newStart
+			changes = append(changes, models.MatchChange{Field: "match_time", OldValue: match.MatchTime.Format(time.RFC3339), NewValue: newStart.Format(time.RFC3339)})
+		}
+		if !newEnd.Equal(match.EndTime) {
+			updates["end_time"] = newEnd
+			changes = append(changes, models.MatchChange{Field: "end_time", OldValue: match.EndTime.Format(time.RFC3339), NewValue: newEnd.Format(time.RFC3339)})
+		}
+		material = newStart.Sub(match.MatchTime).Abs() > materialTimeShift || newEnd.Sub(match.EndTime).Abs() > materialTimeShift
+		// 系列產生的場次被單獨修改後，不再隨系列重新排程
+		if match.SeriesID != nil {
+			updates["series_override"] = true
+		}
+	}
+
+	if req.Notes != nil && *req.Notes != match.Notes {
+		updates["notes"] = *req.Notes
+		changes = append(changes, models.MatchChange{Field: "notes", OldValue: match.Notes, NewValue: *req.Notes})
+	}
+
+	if req.Capacity != nil {
+		current, err := matchCapacity(tx, match)
+		if err != nil {
+			return nil, nil, false, apperrors.MapGORMError(err)
+		}
+		if *req.Capacity != current {
+			seated, err := seatedCount(tx, match.ID)
+			if err != nil {
+				return nil, nil, false, apperrors.MapGORMError(err)
+			}
+			if int64(*req.Capacity) < seated {
+				return nil, nil, false, apperrors.NewValidationError(fmt.Sprintf("名額不能少於已審核通過的人數 (%d)", seated))
+			}
+			updates["capacity"] = *req.Capacity
+			changes = append(changes, models.MatchChange{Field: "capacity", OldValue: strconv.Itoa(current), NewValue: strconv.Itoa(*req.Capacity)})
+		}
+	}
+
+	match.MatchTime, match.EndTime = newStart, newEnd
+	return updates, changes, material, nil
+}
+
+// notifyMatchChange 通知已審核通過的參與者配對局已變更；重大變更時將他們改為 reconfirm 並設定期限，回傳需要重新確認的人數
+func notifyMatchChange(tx *gorm.DB, match *models.Match, material bool, actorID int64, now time.Time) (int64, error) {
+	var participants []models.MatchParticipant
+	if err := tx.Where("match_id = ? AND status IN ?", match.ID, []string{"approved", "reconfirm"}).Find(&participants).Error; err != nil {
+		return 0, err
+	}
+	if len(participants) == 0 {
+		return 0, nil
+	}
+
+	var activity models.Activity
+	if err := tx.Select("id", "title").First(&activity, match.ActivityID).Error; err != nil {
+		return 0, err
+	}
+
+	notificationType := models.NotificationMatchChanged
+	message := fmt.Sprintf("配對局「%s」的資訊已更新", activity.Title)
+	if material {
+		deadline := now.Add(reconfirmWindow)
+		if deadline.After(match.MatchTime) {
+			deadline = match.MatchTime
+		}
+		err := tx.Model(&models.MatchParticipant{}).
+			Where("match_id = ? AND status IN ?", match.ID, []string{"approved", "reconfirm"}).
+			Updates(map[string]interface{}{"status": "reconfirm", "reconfirm_deadline": deadline}).Error
+		if err != nil {
+			return 0, err
+		}
+		var events []models.MatchEvent
+		for i := range participants {
+			if participants[i].Status != "reconfirm" {
+				events = append(events, models.NewParticipantEvent(&participants[i], participants[i].Status, "reconfirm", actorID, "配對局時間變更"))
+			}
+		}
+		if len(events) > 0 {
+			if err := tx.Create(&events).Error; err != nil {
+				return 0, err
+			}
+		}
+		notificationType = models.NotificationReconfirm
+		message = fmt.Sprintf("配對局「%s」的時間已變更，請在期限前確認是否仍要參加，未確認將被釋出名額", activity.Title)
+	}
+
+	notifications := make([]models.Notification, len(participants))
+	for i, p := range participants {
+		notifications[i] = models.Notification{UserID: p.UserID, Type: notificationType, MatchID: &match.ID, Message: message, CreatedAt: now}
+	}
+	if err := tx.Create(&notifications).Error; err != nil {
+		return 0, err
+	}
+
+	if !material {
+		return 0, nil
+	}
+	return int64(len(participants)), nil
+}
+
+// listMatchChanges 取得配對局的變更紀錄
+// @Summary 配對局變更紀錄
+// @Description 開局者與曾申請參與的使用者可以查看配對局的所有變更
+// @Tags 使用者
+// @Produce json
+// @Param id path int true "配對局ID"
+// @Success 200 {array} MatchChange
+// @Failure 400 {object} map[string]string "無效的配對局 ID"
+// @Failure 403 {object} map[string]string "沒有參與此配對局"
+// @Router /user/matches/{id}/changes [get]
+// @Security ApiKeyAuth
+func listMatchChanges(c *gin.Context) {
+	matchID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || matchID <= 0 {
+		c.Error(apperrors.NewValidationError("無效的配對局 ID"))
+		return
+	}
+
+	user, err := utils.GetAuthenticatedUser(c)
+	if err != nil {
+		c.Error(apperrors.NewUnauthorizedError("未登入"))
+		return
+	}
+
+	db := database.GlobalDB.Conn
+	var related int64
+	err = db.Model(&models.Match{}).
+		Where("id = ? AND (organizer_id = ? OR id IN (?))", matchID, user.ID,
+			db.Model(&models.MatchParticipant{}).Select("match_id").Where("user_id = ?", user.ID)).
+		Count(&related).Error
+	if err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+	if related == 0 {
+		c.Error(apperrors.NewForbiddenError("沒有參與此配對局"))
+		return
+	}
+
+	changes := []models.MatchChange{}
+	if err := db.Where("match_id = ?", matchID).Order("id").Find(&changes).Error; err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+	c.JSON(http.StatusOK, changes)
+}
+
+// reconfirmParticipation 重新確認是否參加
+// @Summary 重新確認參加
+// @Description 配對局有重大變更後，參與者在期限前回覆是否仍要參加；accept 為 false 時立即釋出名額
+// @Tags 使用者
+// @Accept json
+// @Produce json
+// @Param id path int true "配對局ID"
+// @Param reconfirm body ReconfirmRequest true "是否仍要參加"
+// @Success 200 {object} MatchParticipant
+// @Failure 400 {object} map[string]string "無效的請求資料、不需要重新確認或已超過期限"
+// @Router /user/matches/{id}/reconfirm [post]
+// @Security ApiKeyAuth
+func reconfirmParticipation(c *gin.Context) {
+	matchID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || matchID <= 0 {
+		c.Error(apperrors.NewValidationError("無效的配對局 ID"))
+		return
+	}
+
+	var req ReconfirmRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.NewValidationError("無效的請求資料"))
+		return
+	}
+	v := validator.New()
+	if err := v.Struct(&req); err != nil {
+		c.Error(apperrors.NewValidationError(err.Error()))
+		return
+	}
+
+	user, err := utils.GetAuthenticatedUser(c)
+	if err != nil {
+		c.Error(apperrors.NewUnauthorizedError("未登入"))
+		return
+	}
+
+	db := database.GlobalDB.Conn
+	var participant models.MatchParticipant
+	if err := db.Where("match_id = ? AND user_id = ? AND status = ?", matchID, user.ID, "reconfirm").First(&participant).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Error(apperrors.NewValidationError("此配對局不需要重新確認"))
+			return
+		}
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+	if participant.ReconfirmDeadline != nil && time.Now().After(*participant.ReconfirmDeadline) {
+		c.Error(apperrors.NewValidationError("已超過重新確認的期限"))
+		return
+	}
+
+	status := "released"
+	if *req.Accept {
+		status = "approved"
+	}
+	// 只更新仍在等待確認的紀錄，避免與釋出名額的背景工作衝突
+	err = db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.MatchParticipant{}).
+			Where("id = ? AND status = ?", participant.ID, "reconfirm").
+			Updates(map[string]interface{}{"status": status, "reconfirm_deadline": nil})
+		if result.Error != nil {
+			return apperrors.MapGORMError(result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return apperrors.NewValidationError("已超過重新確認的期限")
+		}
+		return recordEvent(tx, models.NewParticipantEvent(&participant, "reconfirm", status, user.ID, ""))
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	participant.Status = status
+	participant.ReconfirmDeadline = nil
+	c.JSON(http.StatusOK, participant)
+}
@@ -0,0 +1,101 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"free2free/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateMatchReconfirm(t *testing.T) {
+	db := setupUserTestDatabase(t)
+
+	organizer := seedUser(t, db, "organizer")
+	alice := seedUser(t, db, "alice")
+	bob := seedUser(t, db, "bob")
+	outsider := seedUser(t, db, "outsider")
+	match := seedMatch(t, db, organizer.ID)
+	for _, userID := range []int64{alice.ID, bob.ID} {
+		assert.NoError(t, db.Create(&models.MatchParticipant{MatchID: match.ID, UserID: userID, Status: "approved", JoinedAt: time.Now()}).Error)
+	}
+
+	update := func(body string) (UpdateMatchResult, *gin.Context) {
+		c, w := newUserContext("PATCH", "/", []byte(body), organizer.ID)
+		c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(match.ID)}}
+		updateMatch(c)
+		var result UpdateMatchResult
+		if len(c.Errors) == 0 {
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+		}
+		return result, c
+	}
+
+	// 小幅修改只通知參與者，不需要重新確認
+	result, c := update(`{"notes": "在門口集合", "match_time": "` + match.MatchTime.Add(30*time.Minute).Format(time.RFC3339Nano) + `"}`)
+	assert.Empty(t, c.Errors)
+	assert.Len(t, result.Changes, 3)
+	assert.Equal(t, int64(0), result.ReconfirmRequired)
+	assert.Equal(t, "在門口集合", result.Match.Notes)
+	assert.Equal(t, 2*time.Hour, result.Match.EndTime.Sub(result.Match.MatchTime))
+
+	// 名額不能少於已審核通過的人數
+	_, c = update(`{"capacity": 1}`)
+	assert.NotEmpty(t, c.Errors)
+
+	// 時間移動超過門檻時，參與者需要重新確認
+	result, c = update(`{"match_time": "` + match.MatchTime.Add(5*time.Hour).Format(time.RFC3339Nano) + `"}`)
+	assert.Empty(t, c.Errors)
+	assert.Equal(t, int64(2), result.ReconfirmRequired)
+	for _, change := range result.Changes {
+		assert.True(t, change.Material)
+	}
+
+	var participants []models.MatchParticipant
+	assert.NoError(t, db.Where("match_id = ?", match.ID).Order("user_id").Find(&participants).Error)
+	for _, p := range participants {
+		assert.Equal(t, "reconfirm", p.Status)
+		if assert.NotNil(t, p.ReconfirmDeadline) {
+			assert.False(t, p.ReconfirmDeadline.After(result.Match.MatchTime))
+		}
+	}
+
+	var notifications int64
+	assert.NoError(t, db.Model(&models.Notification{}).Where("user_id = ? AND type = ?", alice.ID, models.NotificationReconfirm).Count(&notifications).Error)
+	assert.Equal(t, int64(1), notifications)
+
+	reconfirm := func(userID int64, accept bool) *gin.Context {
+		c, _ := newUserContext("POST", "/", []byte(fmt.Sprintf(`{"accept": %t}`, accept)), userID)
+		c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(match.ID)}}
+		reconfirmParticipation(c)
+		return c
+	}
+	assert.Empty(t, reconfirm(alice.ID, true).Errors)
+	assert.Empty(t, reconfirm(bob.ID, false).Errors)
+	// 已回覆過的參與者不需要再確認
+	assert.NotEmpty(t, reconfirm(alice.ID, true).Errors)
+
+	assert.NoError(t, db.Where("match_id = ?", match.ID).Order("user_id").Find(&participants).Error)
+	assert.Equal(t, "approved", participants[0].Status)
+	assert.Nil(t, participants[0].ReconfirmDeadline)
+	assert.Equal(t, "released", participants[1].Status)
+
+	// 參與者可以查看變更紀錄，其他使用者不行
+	c, w := newUserContext("GET", "/", nil, bob.ID)
+	c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(match.ID)}}
+	listMatchChanges(c)
+	var changes []models.MatchChange
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &changes))
+	assert.Len(t, changes, 5)
+
+	c, _ = newUserContext("GET", "/", nil, outsider.ID)
+	c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(match.ID)}}
+	listMatchChanges(c)
+	assert.NotEmpty(t, c.Errors)
+}
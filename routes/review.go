@@ -22,7 +22,7 @@ func ReviewAuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 這裡應該實作評分認證邏輯
 		// 例如檢查 session 或 JWT token 中的使用者是否參與了指定的配對局
-		// 且配對局已結束但在評分時間範圍內 (結束後4小時)
+		// 且配對局已結束但在活動設定的評分時間範圍內
 		// 為了簡化，這裡假設有一個 canReviewMatch 函數
 		matchID, err := strconv.ParseInt(c.Param("id"), 10, 64)
 		if err != nil {
@@ -52,7 +52,7 @@ func canReviewMatch(c *gin.Context, matchID int64) bool {
 
 	// 檢查使用者是否參與了指定的配對局
 	var participant models.MatchParticipant
-	err = database.GlobalDB.Conn.Where("match_id = ? AND user_id = ? AND status = ?", matchID, user.ID, "approved").First(&participant).Error
+	err = database.GlobalDB.Conn.Where("match_id = ? AND user_id = ? AND status IN ?", matchID, user.ID, models.ApprovedParticipantStatuses).First(&participant).Error
 	if errors.Is(err, gorm.ErrRecordNotFound) {
 		return false
 	}
@@ -60,9 +60,14 @@ func canReviewMatch(c *gin.Context, matchID int64) bool {
 		return false
 	}
 
+	// 只有完成現場報到或經開局者確認出席的參與者才能評分
+	if participant.CheckedInAt == nil && participant.Status != "attended" {
+		return false
+	}
+
 	// 檢查配對局是否已完成
 	var match models.Match
-	err = database.GlobalDB.Conn.Where("id = ? AND status = ?", matchID, "completed").First(&match).Error
+	err = database.GlobalDB.Conn.Preload("Activity").Where("id = ? AND status = ?", matchID, "completed").First(&match).Error
 	if errors.Is(err, gorm.ErrRecordNotFound) {
 		return false
 	}
@@ -70,13 +75,12 @@ func canReviewMatch(c *gin.Context, matchID int64) bool {
 		return false
 	}
 
-	// 檢查是否在評分時間範圍內（結束後4小時內）
-	// 假設配對局結束時間存儲在 match.MatchTime 中
-	// 實際應用中可能需要一個額外的字段來記錄配對局的結束時間
-	endTime := match.MatchTime
-	reviewDeadline := endTime.Add(4 * time.Hour)
+	return time.Now().Before(reviewDeadline(&match))
+}
 
-	return time.Now().Before(reviewDeadline)
+// reviewDeadline 回傳配對局的評分期限（結束後依活動設定的時數內），match 需預加載 Activity
+func reviewDeadline(match *models.Match) time.Time {
+	return match.EndTime.Add(match.Activity.ReviewWindow())
 }
 
 // SetupReviewRoutes 設定評分路由
@@ -150,8 +154,12 @@ func createReview(c *gin.Context) {
 		return
 	}
 
-	// 建立新的評分記錄
+	// 建立新的評分記錄，同時送出的重複評分由 unique_reviewer_reviewee_match 索引擋下
 	if err := database.GlobalDB.Conn.Create(&review).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			c.Error(apperrors.NewAppError(http.StatusConflict, "您已經對此人評分過"))
+			return
+		}
 		c.Error(apperrors.MapGORMError(err))
 		return
 	}
@@ -1,6 +1,7 @@
 package routes
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -100,6 +101,10 @@ func likeReview(c *gin.Context) {
 	}
 
 	if err := database.GlobalDB.Conn.Create(&reviewLike).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			c.Error(apperrors.NewAppError(http.StatusConflict, "您已經對此評論表態過"))
+			return
+		}
 		c.Error(apperrors.MapGORMError(err))
 		return
 	}
@@ -182,6 +187,10 @@ func dislikeReview(c *gin.Context) {
 	}
 
 	if err := database.GlobalDB.Conn.Create(&reviewLike).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			c.Error(apperrors.NewAppError(http.StatusConflict, "您已經對此評論表態過"))
+			return
+		}
 		c.Error(apperrors.MapGORMError(err))
 		return
 	}
@@ -0,0 +1,189 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"free2free/database"
+	"free2free/models"
+	"free2free/search"
+
+	apperrors "free2free/errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultSearchLimit = 10
+	maxSearchLimit     = 50
+	maxSearchQueryLen  = 100
+	// maxSearchFetch 略過已封存的活動時最多向索引取得的筆數
+	maxSearchFetch = 4 * maxSearchLimit
+)
+
+// ActivitySearchResult 搜尋到的活動與其尚未結束的配對局
+type ActivitySearchResult struct {
+	models.Activity
+	OpenMatches []models.Match `json:"open_matches"`
+}
+
+// SearchResponse 依種類分組的搜尋結果
+type SearchResponse struct {
+	Query      string                 `json:"query"`
+	Activities []ActivitySearchResult `json:"activities"`
+	Locations  []models.Location      `json:"locations"`
+}
+
+// SetupSearchRoutes 設定搜尋路由
+func SetupSearchRoutes(r *gin.Engine) {
+	r.GET("/search", UserAuthMiddleware(), searchAll)
+}
+
+// searchAll 搜尋活動與地點
+// @Summary 搜尋活動與地點
+// @Description 以關鍵字搜尋活動標題、描述與地點名稱、地址，結果依種類分組，每個活動附上尚未結束且狀態為open的配對局；已封存的活動不列入
+// @Tags 使用者
+// @Accept json
+// @Produce json
+// @Param q query string true "關鍵字"
+// @Param limit query int false "每種類最多回傳筆數 (1-50)，預設 10"
+// @Success 200 {object} SearchResponse
+// @Failure 400 {object} map[string]string "無效的查詢參數"
+// @Failure 500 {object} map[string]string "搜尋失敗"
+// @Router /search [get]
+// @Security ApiKeyAuth
+func searchAll(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" || utf8.RuneCountInString(q) > maxSearchQueryLen {
+		c.Error(apperrors.NewCodedError(http.StatusBadRequest, ErrCodeInvalidFilter, fmt.Sprintf("q 必須為 1 到 %d 個字", maxSearchQueryLen)))
+		return
+	}
+
+	limit := defaultSearchLimit
+	if raw := c.Query("limit"); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v <= 0 || v > maxSearchLimit {
+			c.Error(apperrors.NewCodedError(http.StatusBadRequest, ErrCodeInvalidLimit, fmt.Sprintf("limit 必須介於 1 到 %d 之間", maxSearchLimit)))
+			return
+		}
+		limit = v
+	}
+
+	ctx := c.Request.Context()
+	idx := search.Current()
+
+	activities, err := searchActivities(ctx, idx, q, limit)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	locationHits, err := idx.Search(ctx, search.KindLocation, q, limit)
+	if err != nil {
+		c.Error(apperrors.NewAppError(http.StatusInternalServerError, "搜尋失敗"))
+		return
+	}
+
+	resp := SearchResponse{
+		Query:      q,
+		Activities: []ActivitySearchResult{},
+		Locations:  []models.Location{},
+	}
+
+	if len(activities) > 0 {
+		ids := make([]int64, len(activities))
+		for i, a := range activities {
+			ids[i] = a.ID
+		}
+
+		var matches []models.Match
+		if err := database.GlobalDB.Conn.Preload("Organizer").
+			Where("activity_id IN ? AND status = ? AND end_time > ?", ids, "open", time.Now()).
+			Order("match_time ASC").
+			Find(&matches).Error; err != nil {
+			c.Error(apperrors.MapGORMError(err))
+			return
+		}
+
+		matchesByActivity := map[int64][]models.Match{}
+		for _, m := range matches {
+			matchesByActivity[m.ActivityID] = append(matchesByActivity[m.ActivityID], m)
+		}
+		for _, activity := range activities {
+			open := matchesByActivity[activity.ID]
+			if open == nil {
+				open = []models.Match{}
+			}
+			resp.Activities = append(resp.Activities, ActivitySearchResult{Activity: activity, OpenMatches: open})
+		}
+	}
+
+	if len(locationHits) > 0 {
+		ids := hitIDs(locationHits)
+
+		var locations []models.Location
+		if err := database.GlobalDB.Conn.Where("id IN ?", ids).Find(&locations).Error; err != nil {
+			c.Error(apperrors.MapGORMError(err))
+			return
+		}
+
+		byID := map[int64]models.Location{}
+		for _, l := range locations {
+			byID[l.ID] = l
+		}
+		for _, id := range ids {
+			if location, ok := byID[id]; ok {
+				resp.Locations = append(resp.Locations, location)
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// searchActivities 依搜尋分數的順序回傳最多 limit 個未封存的活動，略過索引中已不存在的資料；
+// 封存的活動仍可能排在前面，不足 limit 個時加倍向索引多取，直到索引沒有更多結果或達到 maxSearchFetch
+func searchActivities(ctx context.Context, idx search.Index, q string, limit int) ([]models.Activity, error) {
+	fetch := limit
+	for {
+		hits, err := idx.Search(ctx, search.KindActivity, q, fetch)
+		if err != nil {
+			return nil, apperrors.NewAppError(http.StatusInternalServerError, "搜尋失敗")
+		}
+
+		var activities []models.Activity
+		if len(hits) > 0 {
+			if err := database.GlobalDB.Conn.Preload("Location").Where("id IN ? AND archived_at IS NULL", hitIDs(hits)).Find(&activities).Error; err != nil {
+				return nil, apperrors.MapGORMError(err)
+			}
+		}
+		if len(activities) < limit && len(hits) == fetch && fetch < maxSearchFetch {
+			fetch = min(fetch*2, maxSearchFetch)
+			continue
+		}
+
+		byID := map[int64]models.Activity{}
+		for _, a := range activities {
+			byID[a.ID] = a
+		}
+		result := make([]models.Activity, 0, limit)
+		for _, hit := range hits {
+			if activity, ok := byID[hit.ID]; ok && len(result) < limit {
+				result = append(result, activity)
+			}
+		}
+		return result, nil
+	}
+}
+
+func hitIDs(hits []search.Hit) []int64 {
+	ids := make([]int64, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.ID
+	}
+	return ids
+}
@@ -0,0 +1,107 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"free2free/models"
+	"free2free/search"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchAllGroupsResults(t *testing.T) {
+	db := setupUserTestDatabase(t)
+	organizer := seedUser(t, db, "organizer")
+	match := seedMatch(t, db, organizer.ID)
+
+	idx := search.NewMemoryIndex()
+	assert.NoError(t, idx.Load(db))
+	search.SetIndex(idx)
+	defer search.SetIndex(search.NewMemoryIndex())
+
+	c, w := newUserContext("GET", "/?q=咖啡", nil, organizer.ID)
+	searchAll(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp SearchResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	if assert.Len(t, resp.Activities, 1) {
+		assert.Equal(t, match.ActivityID, resp.Activities[0].ID)
+		if assert.Len(t, resp.Activities[0].OpenMatches, 1) {
+			assert.Equal(t, match.ID, resp.Activities[0].OpenMatches[0].ID)
+		}
+	}
+	assert.Len(t, resp.Locations, 0)
+
+	c, w = newUserContext("GET", "/?q=全家", nil, organizer.ID)
+	searchAll(c)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Len(t, resp.Activities, 0)
+	if assert.Len(t, resp.Locations, 1) {
+		assert.Equal(t, "全家便利商店", resp.Locations[0].Name)
+	}
+}
+
+func TestSearchAllSkipsArchivedActivities(t *testing.T) {
+	db := setupUserTestDatabase(t)
+	organizer := seedUser(t, db, "organizer")
+	match := seedMatch(t, db, organizer.ID)
+	assert.NoError(t, db.Model(&models.Activity{}).Where("id = ?", match.ActivityID).Update("archived_at", time.Now()).Error)
+
+	idx := search.NewMemoryIndex()
+	assert.NoError(t, idx.Load(db))
+	search.SetIndex(idx)
+	defer search.SetIndex(search.NewMemoryIndex())
+
+	c, w := newUserContext("GET", "/?q=咖啡", nil, organizer.ID)
+	searchAll(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp SearchResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Len(t, resp.Activities, 0)
+}
+
+func TestSearchAllRefillsPastArchivedActivities(t *testing.T) {
+	db := setupUserTestDatabase(t)
+	organizer := seedUser(t, db, "organizer")
+	match := seedMatch(t, db, organizer.ID)
+
+	// 已封存的活動分數較高，排在仍有效的活動前面
+	archivedAt := time.Now()
+	for i := 0; i < 3; i++ {
+		archived := models.Activity{Title: "拿鐵拿鐵拿鐵特價", TargetCount: 2, LocationID: 1, CreatedBy: organizer.ID, ArchivedAt: &archivedAt}
+		assert.NoError(t, db.Create(&archived).Error)
+	}
+	live := models.Activity{Title: "拿鐵第二杯半價", TargetCount: 2, LocationID: 1, CreatedBy: organizer.ID}
+	assert.NoError(t, db.Create(&live).Error)
+	assert.NoError(t, db.Model(&models.Activity{}).Where("id = ?", match.ActivityID).Update("title", "拿鐵買一送一").Error)
+
+	idx := search.NewMemoryIndex()
+	assert.NoError(t, idx.Load(db))
+	search.SetIndex(idx)
+	defer search.SetIndex(search.NewMemoryIndex())
+
+	c, w := newUserContext("GET", "/?q=拿鐵&limit=2", nil, organizer.ID)
+	searchAll(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp SearchResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	ids := []int64{}
+	for _, a := range resp.Activities {
+		ids = append(ids, a.ID)
+	}
+	assert.ElementsMatch(t, []int64{match.ActivityID, live.ID}, ids)
+}
+
+func TestSearchAllRequiresQuery(t *testing.T) {
+	setupUserTestDatabase(t)
+
+	c, _ := newUserContext("GET", "/?q=%20", nil, 1)
+	searchAll(c)
+	assert.Len(t, c.Errors, 1)
+}
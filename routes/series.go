@@ -0,0 +1,593 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"free2free/database"
+	"free2free/jobs"
+	"free2free/models"
+	"free2free/recurrence"
+	"free2free/utils"
+
+	apperrors "free2free/errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SeriesScheduleRequest 週期系列的排程設定
+type SeriesScheduleRequest struct {
+	Rule               string    `json:"rule" validate:"required,max=255"`
+	StartTime          time.Time `json:"start_time" validate:"required"`
+	Until              time.Time `json:"until" validate:"required,gtfield=StartTime"`
+	Timezone           string    `json:"timezone" validate:"omitempty,max=64"`
+	DurationMinutes    int       `json:"duration_minutes" validate:"omitempty,min=15,max=1440"`
+	DaysAhead          int       `json:"days_ahead" validate:"omitempty,min=1,max=60"`
+	AutoApproveUserIDs []int64   `json:"auto_approve_user_ids" validate:"omitempty,max=20,dive,min=1"`
+}
+
+// CreateSeriesRequest 建立週期系列的請求
+type CreateSeriesRequest struct {
+	ActivityID int64 `json:"activity_id" validate:"required,min=1"`
+	SeriesScheduleRequest
+}
+
+// OccurrenceRequest 修改單一場次的請求
+type OccurrenceRequest struct {
+	MatchTime time.Time `json:"match_time" validate:"required"`
+	EndTime   time.Time `json:"end_time" validate:"omitempty,gtfield=MatchTime"`
+}
+
+// SeriesDetail 週期系列與其尚未開始的場次
+type SeriesDetail struct {
+	models.MatchSeries
+	UpcomingMatches []models.Match `json:"upcoming_matches"`
+}
+
+// SetupSeriesRoutes 設定週期系列路由
+func SetupSeriesRoutes(r *gin.Engine) {
+	series := r.Group("/user/series")
+	series.Use(UserAuthMiddleware())
+	{
+		series.GET("", listSeries)
+		series.POST("", createSeries)
+		series.GET("/:id", getSeries)
+
+		// 修改或取消整個系列
+		series.PUT("/:id", updateSeries)
+		series.POST("/:id/cancel", cancelSeries)
+
+		// 修改或取消單一場次
+		series.PUT("/:id/occurrences/:match_id", updateOccurrence)
+		series.POST("/:id/occurrences/:match_id/cancel", cancelOccurrence)
+	}
+}
+
+// listSeries 列出自己建立的週期系列
+// @Summary 週期系列列表
+// @Description 列出當前使用者建立的週期系列
+// @Tags 使用者
+// @Produce json
+// @Success 200 {array} MatchSeries
+// @Failure 401 {object} map[string]string "未登入"
+// @Failure 500 {object} map[string]string "無法取得週期系列"
+// @Router /user/series [get]
+// @Security ApiKeyAuth
+func listSeries(c *gin.Context) {
+	user, err := utils.GetAuthenticatedUser(c)
+	if err != nil {
+		c.Error(apperrors.NewUnauthorizedError("未登入"))
+		return
+	}
+
+	var series []models.MatchSeries
+	err = database.GlobalDB.Conn.
+		Preload("Activity").
+		Preload("Partners").
+		Where("organizer_id = ?", user.ID).
+		Order("id DESC").
+		Find(&series).Error
+	if err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+	c.JSON(http.StatusOK, series)
+}
+
+// createSeries 建立週期系列
+// @Summary 建立週期系列
+// @Description 依重複規則 (FREQ=DAILY|WEEKLY;INTERVAL;BYDAY) 建立週期配對局，並立即產生 days_ahead 天內的場次
+// @Tags 使用者
+// @Accept json
+// @Produce json
+// @Param series body CreateSeriesRequest true "週期系列設定"
+// @Success 201 {object} SeriesDetail
+// @Failure 400 {object} map[string]string "無效的請求資料、活動優惠已結束或第一個場次不在活動可用時段內"
+// @Failure 401 {object} map[string]string "未登入"
+// @Failure 429 {object} map[string]string "超過開局配額 (error_code: quota_exceeded)"
+// @Failure 500 {object} map[string]string "無法建立週期系列"
+// @Router /user/series [post]
+// @Security ApiKeyAuth
+func createSeries(c *gin.Context) {
+	var req CreateSeriesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.NewValidationError("無效的請求資料"))
+		return
+	}
+
+	v := validator.New()
+	if err := v.Struct(&req); err != nil {
+		c.Error(apperrors.NewValidationError(err.Error()))
+		return
+	}
+
+	user, err := utils.GetAuthenticatedUser(c)
+	if err != nil {
+		c.Error(apperrors.NewUnauthorizedError("未登入"))
+		return
+	}
+
+	// 檢查活動是否存在
+	var activity models.Activity
+	if err := database.GlobalDB.Conn.Preload("Availability").First(&activity, req.ActivityID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Error(apperrors.NewValidationError("指定的活動不存在"))
+			return
+		}
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+	if activity.ArchivedAt != nil {
+		c.Error(apperrors.NewCodedError(http.StatusBadRequest, ErrCodeOutsideActivitySchedule, "活動優惠已結束"))
+		return
+	}
+
+	series := models.MatchSeries{
+		ActivityID:  activity.ID,
+		OrganizerID: user.ID,
+		Status:      "active",
+	}
+	partnerIDs, appErr := applySchedule(&series, &req.SeriesScheduleRequest)
+	if appErr != nil {
+		c.Error(appErr)
+		return
+	}
+
+	// 第一個場次必須在活動的優惠期間與每週可用時段內
+	first := series
+	first.Activity = activity
+	if err := activity.CheckSchedule(first.StartTime, first.StartTime.Add(first.MatchDuration())); err != nil {
+		c.Error(apperrors.NewCodedError(http.StatusBadRequest, ErrCodeOutsideActivitySchedule, err.Error()))
+		return
+	}
+
+	err = database.GlobalDB.Conn.Transaction(func(tx *gorm.DB) error {
+		// 系列產生的場次同樣計入開局配額，之後超過配額的場次會暫停產生
+		if err := checkCreateMatchQuota(tx, user.ID); err != nil {
+			return err
+		}
+		if err := tx.Create(&series).Error; err != nil {
+			return apperrors.MapGORMError(err)
+		}
+		if err := replaceSeriesPartners(tx, series.ID, partnerIDs); err != nil {
+			return apperrors.MapGORMError(err)
+		}
+		return nil
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	respondSeriesAfterChange(c, http.StatusCreated, series.ID, false)
+}
+
+// getSeries 取得週期系列
+// @Summary 週期系列詳細資訊
+// @Description 取得自己建立的週期系列與尚未開始的場次
+// @Tags 使用者
+// @Produce json
+// @Param id path int true "系列ID"
+// @Success 200 {object} SeriesDetail
+// @Failure 400 {object} map[string]string "無效的系列 ID"
+// @Failure 403 {object} map[string]string "只有開局者可以管理系列"
+// @Failure 404 {object} map[string]string "系列不存在"
+// @Router /user/series/{id} [get]
+// @Security ApiKeyAuth
+func getSeries(c *gin.Context) {
+	series, ok := loadOwnedSeries(c)
+	if !ok {
+		return
+	}
+
+	detail, err := seriesDetail(series)
+	if err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+	c.JSON(http.StatusOK, detail)
+}
+
+// updateSeries 修改整個週期系列
+// @Summary 修改週期系列
+// @Description 修改整個系列的重複規則與設定；不再符合新規則的未開始場次會被取消，單獨修改過的場次保留原時間
+// @Tags 使用者
+// @Accept json
+// @Produce json
+// @Param id path int true "系列ID"
+// @Param series body SeriesScheduleRequest true "週期系列設定"
+// @Success 200 {object} SeriesDetail
+// @Failure 400 {object} map[string]string "無效的請求資料或系列已取消"
+// @Failure 403 {object} map[string]string "只有開局者可以管理系列"
+// @Failure 404 {object} map[string]string "系列不存在"
+// @Router /user/series/{id} [put]
+// @Security ApiKeyAuth
+func updateSeries(c *gin.Context) {
+	series, ok := loadOwnedSeries(c)
+	if !ok {
+		return
+	}
+	if series.Status != "active" {
+		c.Error(apperrors.NewValidationError("系列已取消"))
+		return
+	}
+
+	var req SeriesScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.NewValidationError("無效的請求資料"))
+		return
+	}
+
+	v := validator.New()
+	if err := v.Struct(&req); err != nil {
+		c.Error(apperrors.NewValidationError(err.Error()))
+		return
+	}
+
+	partnerIDs, appErr := applySchedule(series, &req)
+	if appErr != nil {
+		c.Error(appErr)
+		return
+	}
+
+	err := database.GlobalDB.Conn.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.MatchSeries{}).Where("id = ?", series.ID).Updates(map[string]interface{}{
+			"rule":             series.Rule,
+			"start_time":       series.StartTime,
+			"until":            series.Until,
+			"timezone":         series.Timezone,
+			"duration_minutes": series.DurationMinutes,
+			"days_ahead":       series.DaysAhead,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return replaceSeriesPartners(tx, series.ID, partnerIDs)
+	})
+	if err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+
+	respondSeriesAfterChange(c, http.StatusOK, series.ID, true)
+}
+
+// cancelSeries 取消整個週期系列
+// @Summary 取消週期系列
+// @Description 取消整個系列，所有尚未開始的場次一併取消並通知參與者，且不再產生新場次
+// @Tags 使用者
+// @Produce json
+// @Param id path int true "系列ID"
+// @Success 200 {object} SeriesDetail
+// @Failure 400 {object} map[string]string "無效的系列 ID 或系列已取消"
+// @Failure 403 {object} map[string]string "只有開局者可以管理系列"
+// @Failure 404 {object} map[string]string "系列不存在"
+// @Router /user/series/{id}/cancel [post]
+// @Security ApiKeyAuth
+func cancelSeries(c *gin.Context) {
+	series, ok := loadOwnedSeries(c)
+	if !ok {
+		return
+	}
+
+	err := database.GlobalDB.Conn.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.MatchSeries{}).Where("id = ? AND status = ?", series.ID, "active").Update("status", "cancelled")
+		if result.Error != nil {
+			return apperrors.MapGORMError(result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return apperrors.NewValidationError("系列已取消")
+		}
+
+		// 尚未開始的場次逐一取消，記錄原因並通知參與者
+		now := time.Now()
+		var matches []models.Match
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("series_id = ? AND status = ? AND match_time > ?", series.ID, "open", now).
+			Find(&matches).Error
+		if err != nil {
+			return apperrors.MapGORMError(err)
+		}
+		for i := range matches {
+			if err := jobs.CancelMatch(tx, &matches[i], "週期系列已取消", series.OrganizerID, now); err != nil {
+				return apperrors.MapGORMError(err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	series.Status = "cancelled"
+	detail, err := seriesDetail(series)
+	if err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+	c.JSON(http.StatusOK, detail)
+}
+
+// updateOccurrence 修改系列中的單一場次
+// @Summary 修改單一場次
+// @Description 修改系列中單一場次的時間，之後修改整個系列時此場次會保留自訂時間；與修改配對局相同，重大時間變更時已通過的參與者需重新確認
+// @Tags 使用者
+// @Accept json
+// @Produce json
+// @Param id path int true "系列ID"
+// @Param match_id path int true "配對局ID"
+// @Param occurrence body OccurrenceRequest true "場次時間"
+// @Success 200 {object} UpdateMatchResult
+// @Failure 400 {object} map[string]string "無效的請求資料、場次已無法修改或不在活動可用時段內"
+// @Failure 403 {object} map[string]string "只有開局者可以管理系列"
+// @Failure 404 {object} map[string]string "系列或場次不存在"
+// @Router /user/series/{id}/occurrences/{match_id} [put]
+// @Security ApiKeyAuth
+func updateOccurrence(c *gin.Context) {
+	series, ok := loadOwnedSeries(c)
+	if !ok {
+		return
+	}
+	match, ok := loadUpcomingOccurrence(c, series)
+	if !ok {
+		return
+	}
+
+	var req OccurrenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.NewValidationError("無效的請求資料"))
+		return
+	}
+
+	v := validator.New()
+	if err := v.Struct(&req); err != nil {
+		c.Error(apperrors.NewValidationError(err.Error()))
+		return
+	}
+	if !req.MatchTime.After(time.Now()) {
+		c.Error(apperrors.NewValidationError("場次時間必須在未來"))
+		return
+	}
+	if req.EndTime.IsZero() {
+		req.EndTime = req.MatchTime.Add(series.MatchDuration())
+	}
+
+	// 與修改單一配對局相同，重大時間變更需要參與者重新確認
+	update := UpdateMatchRequest{MatchTime: &req.MatchTime, EndTime: &req.EndTime}
+	var result UpdateMatchResult
+	err := database.GlobalDB.Conn.Transaction(func(tx *gorm.DB) error {
+		var err error
+		result, err = rescheduleMatch(tx, match.ID, &update, series.OrganizerID)
+		return err
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if result.Changes == nil {
+		result.Changes = []models.MatchChange{}
+	}
+	database.GlobalDB.Conn.Preload("Activity").Preload("Organizer").First(&result.Match, result.Match.ID)
+	c.JSON(http.StatusOK, result)
+}
+
+// cancelOccurrence 取消系列中的單一場次
+// @Summary 取消單一場次
+// @Description 取消系列中的單一場次並通知參與者，此場次不會被重新產生
+// @Tags 使用者
+// @Produce json
+// @Param id path int true "系列ID"
+// @Param match_id path int true "配對局ID"
+// @Success 200 {object} Match
+// @Failure 400 {object} map[string]string "無效的 ID 或場次已無法取消"
+// @Failure 403 {object} map[string]string "只有開局者可以管理系列"
+// @Failure 404 {object} map[string]string "系列或場次不存在"
+// @Router /user/series/{id}/occurrences/{match_id}/cancel [post]
+// @Security ApiKeyAuth
+func cancelOccurrence(c *gin.Context) {
+	series, ok := loadOwnedSeries(c)
+	if !ok {
+		return
+	}
+	match, ok := loadUpcomingOccurrence(c, series)
+	if !ok {
+		return
+	}
+
+	err := database.GlobalDB.Conn.Transaction(func(tx *gorm.DB) error {
+		return jobs.CancelMatch(tx, match, "開局者取消系列中的這一場", series.OrganizerID, time.Now())
+	})
+	if err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+
+	database.GlobalDB.Conn.Preload("Activity").Preload("Organizer").First(match, match.ID)
+	c.JSON(http.StatusOK, match)
+}
+
+// applySchedule 驗證排程設定並套用到系列上，回傳去除重複與開局者後的自動核准名單
+func applySchedule(series *models.MatchSeries, req *SeriesScheduleRequest) ([]int64, *apperrors.AppError) {
+	if _, err := recurrence.Parse(req.Rule); err != nil {
+		return nil, apperrors.NewValidationError("無效的重複規則: " + err.Error())
+	}
+
+	timezone := req.Timezone
+	if timezone == "" {
+		timezone = jobs.DefaultSeriesTimezone
+	}
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return nil, apperrors.NewValidationError("無效的時區")
+	}
+
+	if !req.Until.After(time.Now()) {
+		return nil, apperrors.NewValidationError("結束日期必須在未來")
+	}
+
+	daysAhead := req.DaysAhead
+	if daysAhead == 0 {
+		daysAhead = models.DefaultSeriesDaysAhead
+	}
+
+	seen := map[int64]bool{series.OrganizerID: true}
+	var partnerIDs []int64
+	for _, id := range req.AutoApproveUserIDs {
+		if !seen[id] {
+			seen[id] = true
+			partnerIDs = append(partnerIDs, id)
+		}
+	}
+	if len(partnerIDs) > 0 {
+		var count int64
+		if err := database.GlobalDB.Conn.Model(&models.User{}).Where("id IN ?", partnerIDs).Count(&count).Error; err != nil {
+			return nil, apperrors.MapGORMError(err)
+		}
+		if count != int64(len(partnerIDs)) {
+			return nil, apperrors.NewValidationError("自動核准名單中有不存在的使用者")
+		}
+	}
+
+	series.Rule = req.Rule
+	series.StartTime = req.StartTime
+	series.Until = req.Until
+	series.Timezone = timezone
+	series.DurationMinutes = req.DurationMinutes
+	series.DaysAhead = daysAhead
+	return partnerIDs, nil
+}
+
+// replaceSeriesPartners 以新的名單取代系列的自動核准名單
+func replaceSeriesPartners(tx *gorm.DB, seriesID int64, userIDs []int64) error {
+	if err := tx.Where("series_id = ?", seriesID).Delete(&models.MatchSeriesPartner{}).Error; err != nil {
+		return err
+	}
+	for _, userID := range userIDs {
+		if err := tx.Create(&models.MatchSeriesPartner{SeriesID: seriesID, UserID: userID}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// respondSeriesAfterChange 重新載入系列，調整並產生場次後回應
+func respondSeriesAfterChange(c *gin.Context, status int, seriesID int64, reschedule bool) {
+	var series models.MatchSeries
+	db := database.GlobalDB.Conn
+	if err := db.Preload("Activity.Availability").Preload("Partners").First(&series, seriesID).Error; err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+
+	now := time.Now()
+	if reschedule {
+		if _, err := jobs.RescheduleSeries(db, &series, now); err != nil {
+			c.Error(apperrors.MapGORMError(err))
+			return
+		}
+	}
+	if _, err := jobs.GenerateSeries(db, &series, now); err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+
+	detail, err := seriesDetail(&series)
+	if err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+	c.JSON(status, detail)
+}
+
+// seriesDetail 組合系列與其尚未開始的場次
+func seriesDetail(series *models.MatchSeries) (*SeriesDetail, error) {
+	var upcoming []models.Match
+	err := database.GlobalDB.Conn.
+		Where("series_id = ? AND match_time > ?", series.ID, time.Now()).
+		Order("match_time ASC").
+		Find(&upcoming).Error
+	if err != nil {
+		return nil, err
+	}
+	return &SeriesDetail{MatchSeries: *series, UpcomingMatches: upcoming}, nil
+}
+
+// loadOwnedSeries 讀取路徑中的系列，並確認當前使用者為開局者
+func loadOwnedSeries(c *gin.Context) (*models.MatchSeries, bool) {
+	seriesID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || seriesID <= 0 {
+		c.Error(apperrors.NewValidationError("無效的系列 ID"))
+		return nil, false
+	}
+
+	user, err := utils.GetAuthenticatedUser(c)
+	if err != nil {
+		c.Error(apperrors.NewUnauthorizedError("未登入"))
+		return nil, false
+	}
+
+	var series models.MatchSeries
+	if err := database.GlobalDB.Conn.Preload("Activity").Preload("Partners").First(&series, seriesID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Error(apperrors.NewAppError(http.StatusNotFound, "系列不存在"))
+			return nil, false
+		}
+		c.Error(apperrors.MapGORMError(err))
+		return nil, false
+	}
+	if series.OrganizerID != user.ID {
+		c.Error(apperrors.NewForbiddenError("只有開局者可以管理系列"))
+		return nil, false
+	}
+	return &series, true
+}
+
+// loadUpcomingOccurrence 讀取路徑中屬於此系列、尚未開始且仍開放的場次
+func loadUpcomingOccurrence(c *gin.Context, series *models.MatchSeries) (*models.Match, bool) {
+	matchID, err := strconv.ParseInt(c.Param("match_id"), 10, 64)
+	if err != nil || matchID <= 0 {
+		c.Error(apperrors.NewValidationError("無效的配對局 ID"))
+		return nil, false
+	}
+
+	var match models.Match
+	if err := database.GlobalDB.Conn.Where("id = ? AND series_id = ?", matchID, series.ID).First(&match).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Error(apperrors.NewAppError(http.StatusNotFound, "場次不存在或不屬於此系列"))
+			return nil, false
+		}
+		c.Error(apperrors.MapGORMError(err))
+		return nil, false
+	}
+	if match.Status != "open" || !match.MatchTime.After(time.Now()) {
+		c.Error(apperrors.NewValidationError("場次已開始或已關閉，無法修改"))
+		return nil, false
+	}
+	return &match, true
+}
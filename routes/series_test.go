@@ -0,0 +1,172 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"free2free/models"
+
+	apperrors "free2free/errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeriesLifecycle(t *testing.T) {
+	db := setupUserTestDatabase(t)
+
+	organizer := seedUser(t, db, "organizer")
+	partner := seedUser(t, db, "partner")
+	outsider := seedUser(t, db, "outsider")
+	activityID := seedMatch(t, db, organizer.ID).ActivityID
+
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	body, _ := json.Marshal(map[string]interface{}{
+		"activity_id":           activityID,
+		"rule":                  "FREQ=DAILY",
+		"start_time":            start,
+		"until":                 start.AddDate(0, 1, 0),
+		"days_ahead":            3,
+		"auto_approve_user_ids": []int64{partner.ID, organizer.ID},
+	})
+	c, w := newUserContext("POST", "/user/series", body, organizer.ID)
+	createSeries(c)
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	var created SeriesDetail
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, "Asia/Taipei", created.Timezone)
+	assert.Len(t, created.Partners, 1, "開局者不會被加入自動核准名單")
+	if !assert.Len(t, created.UpcomingMatches, 3) {
+		return
+	}
+
+	// 固定夥伴直接成為已核准的參與者
+	var approved int64
+	db.Model(&models.MatchParticipant{}).Where("user_id = ? AND status = ?", partner.ID, "approved").Count(&approved)
+	assert.Equal(t, int64(3), approved)
+
+	seriesParam := gin.Param{Key: "id", Value: fmt.Sprint(created.ID)}
+	occurrence := created.UpcomingMatches[1]
+
+	// 非開局者無法管理系列
+	c, _ = newUserContext("POST", "/", nil, outsider.ID)
+	c.Params = gin.Params{seriesParam, {Key: "match_id", Value: fmt.Sprint(occurrence.ID)}}
+	cancelOccurrence(c)
+	if appErr, ok := c.Errors.Last().Err.(*apperrors.AppError); assert.True(t, ok) {
+		assert.Equal(t, http.StatusForbidden, appErr.Code)
+	}
+
+	// 取消單一場次
+	c, w = newUserContext("POST", "/", nil, organizer.ID)
+	c.Params = gin.Params{seriesParam, {Key: "match_id", Value: fmt.Sprint(occurrence.ID)}}
+	cancelOccurrence(c)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// 單獨修改一個場次的時間，重大變更時固定夥伴需重新確認
+	moved := created.UpcomingMatches[2].MatchTime.Add(2 * time.Hour)
+	body, _ = json.Marshal(map[string]interface{}{"match_time": moved})
+	c, w = newUserContext("PUT", "/", body, organizer.ID)
+	c.Params = gin.Params{seriesParam, {Key: "match_id", Value: fmt.Sprint(created.UpcomingMatches[2].ID)}}
+	updateOccurrence(c)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var rescheduled UpdateMatchResult
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &rescheduled))
+	assert.Equal(t, int64(1), rescheduled.ReconfirmRequired)
+	assert.True(t, rescheduled.Match.SeriesOverride)
+	var partnerRow models.MatchParticipant
+	db.Where("match_id = ? AND user_id = ?", created.UpcomingMatches[2].ID, partner.ID).First(&partnerRow)
+	assert.Equal(t, "reconfirm", partnerRow.Status)
+	var changeCount int64
+	db.Model(&models.MatchChange{}).Where("match_id = ?", created.UpcomingMatches[2].ID).Count(&changeCount)
+	assert.Equal(t, int64(2), changeCount)
+
+	// 取消整個系列後，所有未開始的場次都被取消
+	c, w = newUserContext("POST", "/", nil, organizer.ID)
+	c.Params = gin.Params{seriesParam}
+	cancelSeries(c)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var open int64
+	db.Model(&models.Match{}).Where("series_id = ? AND status = ?", created.ID, "open").Count(&open)
+	assert.Equal(t, int64(0), open)
+
+	var series models.MatchSeries
+	db.First(&series, created.ID)
+	assert.Equal(t, "cancelled", series.Status)
+}
+
+func TestSeriesCancellationNotifiesParticipants(t *testing.T) {
+	db := setupUserTestDatabase(t)
+
+	organizer := seedUser(t, db, "organizer")
+	partner := seedUser(t, db, "partner")
+	activityID := seedMatch(t, db, organizer.ID).ActivityID
+
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	body, _ := json.Marshal(map[string]interface{}{
+		"activity_id":           activityID,
+		"rule":                  "FREQ=DAILY",
+		"start_time":            start,
+		"until":                 start.AddDate(0, 1, 0),
+		"days_ahead":            2,
+		"auto_approve_user_ids": []int64{partner.ID},
+	})
+	c, w := newUserContext("POST", "/user/series", body, organizer.ID)
+	createSeries(c)
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created SeriesDetail
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	if !assert.Len(t, created.UpcomingMatches, 2) {
+		return
+	}
+	seriesParam := gin.Param{Key: "id", Value: fmt.Sprint(created.ID)}
+
+	notified := func(matchID int64) []models.Notification {
+		var notifications []models.Notification
+		db.Where("match_id = ? AND type = ?", matchID, models.NotificationMatchCancelled).Find(&notifications)
+		return notifications
+	}
+
+	// 取消單一場次時記錄原因並通知固定夥伴，不通知執行取消的開局者
+	first := created.UpcomingMatches[0]
+	c, w = newUserContext("POST", "/", nil, organizer.ID)
+	c.Params = gin.Params{seriesParam, {Key: "match_id", Value: fmt.Sprint(first.ID)}}
+	cancelOccurrence(c)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var match models.Match
+	db.First(&match, first.ID)
+	assert.Equal(t, "cancelled", match.Status)
+	assert.NotEmpty(t, match.CancelReason)
+	if notifications := notified(first.ID); assert.Len(t, notifications, 1) {
+		assert.Equal(t, partner.ID, notifications[0].UserID)
+	}
+	var event models.MatchEvent
+	db.Where("match_id = ? AND to_status = ?", first.ID, "cancelled").First(&event)
+	assert.Equal(t, "open", event.FromStatus)
+
+	// 取消整個系列時同樣通知，且已取消的系列不能再取消
+	c, w = newUserContext("POST", "/", nil, organizer.ID)
+	c.Params = gin.Params{seriesParam}
+	cancelSeries(c)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	second := created.UpcomingMatches[1]
+	var cancelled models.Match
+	db.First(&cancelled, second.ID)
+	assert.Equal(t, "週期系列已取消", cancelled.CancelReason)
+	assert.Len(t, notified(second.ID), 1)
+
+	c, _ = newUserContext("POST", "/", nil, organizer.ID)
+	c.Params = gin.Params{seriesParam}
+	cancelSeries(c)
+	if assert.Len(t, c.Errors, 1) {
+		assert.Equal(t, http.StatusBadRequest, c.Errors.Last().Err.(*apperrors.AppError).Code)
+	}
+	assert.Len(t, notified(second.ID), 1)
+}
@@ -8,6 +8,7 @@ import (
 
 	"free2free/models"
 	"free2free/database"
+	"free2free/reliability"
 	"free2free/utils"
 
 	apperrors "free2free/errors"
@@ -15,6 +16,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // UserAuthMiddleware 使用者認證中介層
@@ -55,44 +57,335 @@ func SetupUserRoutes(r *gin.Engine) {
 		// 開局功能
 		user.POST("/matches", createMatch)
 
+		// 附近的配對
+		user.GET("/matches/nearby", listNearbyMatches)
+
+		// 配對局詳細資訊
+		user.GET("/matches/:id", getMatch)
+
 		// 參與配對
 		user.POST("/matches/:id/join", joinMatch)
 
+		// 現場報到
+		user.POST("/matches/:id/checkin", checkIn)
+
+		// 配對局變更紀錄與重新確認
+		user.GET("/matches/:id/changes", listMatchChanges)
+		user.POST("/matches/:id/reconfirm", reconfirmParticipation)
+
+		// 配對局狀態變更時間軸
+		user.GET("/matches/:id/timeline", getMatchTimeline)
+
+		// 配額
+		user.GET("/quota", getMyQuota)
+
+		// 回覆開局者轉移
+		user.POST("/matches/:id/transfer", respondTransfer)
+
+		// 私人集合資訊與聯絡方式
+		user.GET("/matches/:id/private", getPrivateDetail)
+		user.PUT("/matches/:id/contacts", setSharedContacts)
+		user.GET("/contact", getMyContact)
+		user.PUT("/contact", updateMyContact)
+
+		// 使用者公開資料
+		user.GET("/users/:id", getUserProfile)
+
 		// 過去參與列表
 		user.GET("/past-matches", listPastMatches)
+
+		// 我的配對局總覽
+		user.GET("/dashboard", getDashboard)
+
+		// 透過邀請連結參與
+		user.GET("/invites/:token", getInvite)
+		user.POST("/invites/:token/accept", acceptInvite)
+	}
+}
+
+// matchListSpec 配對局列表可用的排序欄位
+var matchListSpec = ListSpec[models.Match]{
+	IDColumn: "matches.id",
+	ID:       func(m models.Match) int64 { return m.ID },
+	Sorts: map[string]SortField[models.Match]{
+		"id":         {Column: "matches.id", Kind: sortInt, Value: func(m models.Match) interface{} { return m.ID }},
+		"match_time": {Column: "matches.match_time", Kind: sortTime, Value: func(m models.Match) interface{} { return m.MatchTime }},
+		"end_time":   {Column: "matches.end_time", Kind: sortTime, Value: func(m models.Match) interface{} { return m.EndTime }},
+	},
+	DefaultSort: "match_time",
+	Preloads:    []string{"Activity", "Organizer"},
+}
+
+// applyMatchFilters 套用配對局列表共用的 activity_id、location_id、from、to 篩選
+func applyMatchFilters(c *gin.Context, query *gorm.DB) (*gorm.DB, error) {
+	if activityID, ok, err := queryInt64(c, "activity_id"); err != nil {
+		return nil, err
+	} else if ok {
+		query = query.Where("matches.activity_id = ?", activityID)
+	}
+
+	if locationID, ok, err := queryInt64(c, "location_id"); err != nil {
+		return nil, err
+	} else if ok {
+		query = query.Where("matches.activity_id IN (?)", database.GlobalDB.Conn.Model(&models.Activity{}).Select("id").Where("location_id = ?", locationID))
+	}
+
+	if from, ok, err := queryTime(c, "from"); err != nil {
+		return nil, err
+	} else if ok {
+		query = query.Where("matches.match_time >= ?", from)
+	}
+
+	if to, ok, err := queryTime(c, "to"); err != nil {
+		return nil, err
+	} else if ok {
+		query = query.Where("matches.match_time < ?", to)
 	}
+
+	return query, nil
 }
 
 // listMatches 取得時間未到的配對列表
 // @Summary 取得時間未到的配對列表
-// @Description 取得所有時間未到且狀態為open的配對列表
+// @Description 取得配對列表，預設只顯示尚未結束且狀態為open的配對，支援游標分頁、篩選與排序
 // @Tags 使用者
 // @Accept json
 // @Produce json
+// @Param activity_id query int false "活動ID"
+// @Param location_id query int false "地點ID"
+// @Param from query string false "配對時間起 (RFC3339)"
+// @Param to query string false "配對時間迄 (RFC3339)"
+// @Param status query string false "狀態 (open、completed、cancelled、expired)，預設 open"
+// @Param sort query string false "排序欄位 (id、match_time、end_time)，前綴 - 表示遞減，預設 match_time"
+// @Param limit query int false "每頁筆數 (1-100)，預設 20"
+// @Param cursor query string false "上一頁回傳的 X-Next-Cursor"
 // @Success 200 {array} Match
+// @Header 200 {integer} X-Total-Count "符合條件的總筆數"
+// @Header 200 {string} X-Next-Cursor "下一頁游標，沒有下一頁時為空"
+// @Failure 400 {object} map[string]string "無效的查詢參數"
 // @Failure 500 {object} map[string]string "無法取得配對列表"
 // @Router /user/matches [get]
 // @Security ApiKeyAuth
 func listMatches(c *gin.Context) {
-	var matches []models.Match
-	// 只顯示狀態為 open 且時間未到的配對
-	if err := database.GlobalDB.Conn.Preload("Activity").Preload("Organizer").Where("status = ? AND match_time > ?", "open", time.Now()).Order("match_time ASC").Find(&matches).Error; err != nil {
-		c.Error(apperrors.MapGORMError(err))
+	query, err := applyMatchFilters(c, database.GlobalDB.Conn.Model(&models.Match{}))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	status, ok, err := queryEnum(c, "status", "open", "completed", "cancelled", "expired")
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if !ok {
+		status = "open"
+	}
+	query = query.Where("matches.status = ?", status)
+	// open 的配對只顯示尚未結束的
+	if status == "open" {
+		query = query.Where("matches.end_time > ?", time.Now())
+	}
+
+	matches, err := Paginate(c, query, matchListSpec)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
 	c.JSON(http.StatusOK, matches)
 }
 
+// ParticipantSummary 配對局參與者名單中的一筆資料，只包含公開資訊
+type ParticipantSummary struct {
+	ID          int64      `json:"id"`
+	UserID      int64      `json:"user_id"`
+	Name        string     `json:"name"`
+	AvatarURL   string     `json:"avatar_url"`
+	Status      string     `json:"status"`
+	JoinedAt    time.Time  `json:"joined_at"`
+	CheckedInAt *time.Time `json:"checked_in_at,omitempty"`
+	// Reliability 只提供給開局者審核參與者時參考
+	Reliability *reliability.Reliability `json:"reliability,omitempty"`
+}
+
+// ParticipantCounts 配對局各狀態的參與人數
+type ParticipantCounts struct {
+	Pending    int `json:"pending"`
+	Approved   int `json:"approved"`
+	Rejected   int `json:"rejected"`
+	Attended   int `json:"attended"`
+	NoShow     int `json:"no_show"`
+	LateCancel int `json:"late_cancel"`
+	Reconfirm  int `json:"reconfirm"`
+	Released   int `json:"released"`
+	Removed    int `json:"removed"`
+	// Other 不屬於以上狀態的參與者，讓各狀態人數的總和等於參與紀錄數
+	Other int `json:"other"`
+}
+
+// MatchDetail 配對局詳細資訊，名單內容依查看者身份而不同
+type MatchDetail struct {
+	models.Match
+	IsOrganizer     bool                     `json:"is_organizer"`
+	CoOrganizerIDs  []int64                  `json:"co_organizer_ids"`
+	MyParticipation *models.MatchParticipant `json:"my_participation"`
+	Counts          ParticipantCounts        `json:"participant_counts"`
+	Participants    []ParticipantSummary     `json:"participants"`
+}
+
+// getMatch 取得配對局詳細資訊
+// @Summary 取得配對局詳細資訊
+// @Description 取得指定ID配對局的活動、地點、開局者與參與者名單。開局者可看到所有參與者，已審核通過的參與者可看到彼此，其他人只能看到人數
+// @Tags 使用者
+// @Accept json
+// @Produce json
+// @Param id path int true "配對局ID"
+// @Success 200 {object} MatchDetail
+// @Failure 400 {object} map[string]string "無效的配對局 ID"
+// @Failure 404 {object} map[string]string "指定的配對局不存在"
+// @Failure 500 {object} map[string]string "無法取得配對局"
+// @Router /user/matches/{id} [get]
+// @Security ApiKeyAuth
+func getMatch(c *gin.Context) {
+	idStr := c.Param("id")
+	matchID, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil || matchID <= 0 {
+		c.Error(apperrors.NewValidationError("無效的配對局 ID"))
+		return
+	}
+
+	// 從認證資訊取得使用者 ID
+	user, err := utils.GetAuthenticatedUser(c)
+	if err != nil {
+		c.Error(apperrors.NewUnauthorizedError("未登入"))
+		return
+	}
+
+	var match models.Match
+	if err := database.GlobalDB.Conn.Preload("Activity.Location").Preload("Organizer").First(&match, matchID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Error(apperrors.NewAppError(http.StatusNotFound, "指定的配對局不存在"))
+			return
+		}
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+
+	var participants []models.MatchParticipant
+	if err := database.GlobalDB.Conn.Preload("User").Where("match_id = ?", matchID).Order("joined_at ASC").Find(&participants).Error; err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+
+	// 共同開局者與開局者一樣可以看到所有參與者
+	coOrganizerIDs := []int64{}
+	if err := database.GlobalDB.Conn.Model(&models.MatchCoOrganizer{}).Where("match_id = ?", matchID).Order("id").Pluck("user_id", &coOrganizerIDs).Error; err != nil {
+		c.Error(apperrors.MapGORMError(err))
+		return
+	}
+
+	detail := MatchDetail{
+		Match:          match,
+		IsOrganizer:    match.OrganizerID == user.ID,
+		CoOrganizerIDs: coOrganizerIDs,
+		Participants:   []ParticipantSummary{},
+	}
+	for _, id := range coOrganizerIDs {
+		if id == user.ID {
+			detail.IsOrganizer = true
+		}
+	}
+
+	for i := range participants {
+		p := participants[i]
+		switch p.Status {
+		case "pending":
+			detail.Counts.Pending++
+		case "approved":
+			detail.Counts.Approved++
+		case "rejected":
+			detail.Counts.Rejected++
+		case "attended":
+			detail.Counts.Attended++
+		case "no_show":
+			detail.Counts.NoShow++
+		case "late_cancel":
+			detail.Counts.LateCancel++
+		case "reconfirm":
+			detail.Counts.Reconfirm++
+		case "released":
+			detail.Counts.Released++
+		case "removed":
+			detail.Counts.Removed++
+		default:
+			detail.Counts.Other++
+		}
+		if p.UserID == user.ID {
+			mine := p
+			mine.User = models.User{}
+			detail.MyParticipation = &mine
+		}
+	}
+
+	// 開局者審核時參考參與者的出席可信度
+	var records map[int64]reliability.Reliability
+	if detail.IsOrganizer {
+		userIDs := make([]int64, len(participants))
+		for i, p := range participants {
+			userIDs[i] = p.UserID
+		}
+		records, err = reliability.Load(database.GlobalDB.Conn, userIDs)
+		if err != nil {
+			c.Error(apperrors.MapGORMError(err))
+			return
+		}
+	}
+
+	// 開局者看到所有參與者；已審核通過的參與者只看到其他已審核通過的參與者
+	viewerApproved := detail.MyParticipation != nil && containsStatus(models.ApprovedParticipantStatuses, detail.MyParticipation.Status)
+	for _, p := range participants {
+		if detail.IsOrganizer || (viewerApproved && containsStatus(models.ApprovedParticipantStatuses, p.Status)) {
+			summary := ParticipantSummary{
+				ID:          p.ID,
+				UserID:      p.UserID,
+				Name:        p.User.Name,
+				AvatarURL:   p.User.AvatarURL,
+				Status:      p.Status,
+				JoinedAt:    p.JoinedAt,
+				CheckedInAt: p.CheckedInAt,
+			}
+			if detail.IsOrganizer {
+				r := records[p.UserID]
+				summary.Reliability = &r
+			}
+			detail.Participants = append(detail.Participants, summary)
+		}
+	}
+
+	c.JSON(http.StatusOK, detail)
+}
+
+// containsStatus 判斷狀態是否在指定的狀態清單中
+func containsStatus(statuses []string, status string) bool {
+	for _, s := range statuses {
+		if status == s {
+			return true
+		}
+	}
+	return false
+}
+
 // createMatch 建立新的配對局 (開局)
 // @Summary 建立新的配對局
-// @Description 建立新的配對局 (開局)
+// @Description 建立新的配對局 (開局)，未指定 end_time 時依活動的 duration_minutes 計算；min_reliability 可限制參與者的最低出席可信度；approval_mode 可選擇 manual (預設)、auto 或 gated
 // @Tags 使用者
 // @Accept json
 // @Produce json
 // @Param match body Match true "配對局資訊"
 // @Success 201 {object} Match
-// @Failure 400 {object} map[string]string "無效的請求資料"
+// @Failure 400 {object} map[string]string "無效的請求資料，或配對時間不在活動的優惠期間與可用時段內 (error_code: outside_activity_schedule)"
+// @Failure 429 {object} map[string]string "超過開局配額 (error_code: quota_exceeded)"
 // @Failure 500 {object} map[string]string "無法建立配對局"
 // @Router /user/matches [post]
 // @Security ApiKeyAuth
@@ -112,7 +405,7 @@ func createMatch(c *gin.Context) {
 
 	// 檢查活動是否存在
 	var activity models.Activity
-	if err := database.GlobalDB.Conn.First(&activity, match.ActivityID).Error; err != nil {
+	if err := database.GlobalDB.Conn.Preload("Availability").First(&activity, match.ActivityID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			c.Error(apperrors.NewValidationError("指定的活動不存在"))
 			return
@@ -120,6 +413,21 @@ func createMatch(c *gin.Context) {
 		c.Error(apperrors.MapGORMError(err))
 		return
 	}
+	if activity.ArchivedAt != nil {
+		c.Error(apperrors.NewCodedError(http.StatusBadRequest, ErrCodeOutsideActivitySchedule, "活動優惠已結束"))
+		return
+	}
+
+	// 未指定結束時間時，依活動的預設長度計算
+	if match.EndTime.IsZero() {
+		match.EndTime = match.MatchTime.Add(activity.MatchDuration())
+	}
+
+	// 配對時間必須在活動的優惠期間與每週可用時段內
+	if err := activity.CheckSchedule(match.MatchTime, match.EndTime); err != nil {
+		c.Error(apperrors.NewCodedError(http.StatusBadRequest, ErrCodeOutsideActivitySchedule, err.Error()))
+		return
+	}
 
 	// 從認證資訊取得使用者 ID
 	user, err := utils.GetAuthenticatedUser(c)
@@ -128,12 +436,28 @@ func createMatch(c *gin.Context) {
 		return
 	}
 
-	// 設定開局者為當前使用者
+	// 設定開局者為當前使用者；系列欄位只能由週期系列產生
 	match.OrganizerID = user.ID
 	match.Status = "open"
+	match.SeriesID = nil
+	match.SeriesSlot = nil
+	match.SeriesOverride = false
+	if err := validateApprovalMode(&match); err != nil {
+		c.Error(err)
+		return
+	}
 
-	if err := database.GlobalDB.Conn.Create(&match).Error; err != nil {
-		c.Error(apperrors.MapGORMError(err))
+	err = database.GlobalDB.Conn.Transaction(func(tx *gorm.DB) error {
+		if err := checkCreateMatchQuota(tx, user.ID); err != nil {
+			return err
+		}
+		if err := tx.Create(&match).Error; err != nil {
+			return apperrors.MapGORMError(err)
+		}
+		return recordEvent(tx, models.NewMatchEvent(match.ID, "", match.Status, user.ID, ""))
+	})
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
@@ -144,13 +468,16 @@ func createMatch(c *gin.Context) {
 
 // joinMatch 參與配對
 // @Summary 參與配對
-// @Description 參與指定ID的配對局
+// @Description 參與指定ID的配對局，配對局設定 min_reliability 時出席可信度需達到要求 (沒有出席紀錄的使用者不受限制)。依配對局的 approval_mode，auto 在名額內直接通過，gated 只讓達到門檻的使用者直接通過，其他人等待開局者審核
 // @Tags 使用者
 // @Accept json
 // @Produce json
 // @Param id path int true "配對局ID"
 // @Success 201 {object} MatchParticipant
-// @Failure 400 {object} map[string]string "無效的配對局 ID 或已參與"
+// @Failure 400 {object} map[string]string "無效的配對局 ID 或配對局已關閉"
+// @Failure 403 {object} map[string]string "出席可信度未達配對局的要求 (error_code: reliability_too_low)"
+// @Failure 409 {object} map[string]string "已參與此配對局"
+// @Failure 429 {object} map[string]string "超過參與配額 (error_code: quota_exceeded)"
 // @Failure 500 {object} map[string]string "無法參與配對局"
 // @Router /user/matches/{id}/join [post]
 // @Security ApiKeyAuth
@@ -162,17 +489,6 @@ func joinMatch(c *gin.Context) {
 		return
 	}
 
-	// 檢查配對局是否存在且可參與
-	var match models.Match
-	if err := database.GlobalDB.Conn.Where("id = ? AND status = ? AND match_time > ?", matchID, "open", time.Now()).First(&match).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.Error(apperrors.NewValidationError("指定的配對局不存在或已關閉"))
-			return
-		}
-		c.Error(apperrors.MapGORMError(err))
-		return
-	}
-
 	// 從認證資訊取得使用者 ID
 	user, err := utils.GetAuthenticatedUser(c)
 	if err != nil {
@@ -180,32 +496,49 @@ func joinMatch(c *gin.Context) {
 		return
 	}
 
-	userID := user.ID
-
-	// 檢查使用者是否已經參與此配對局
-	var existingParticipant models.MatchParticipant
-	err = database.GlobalDB.Conn.Where("match_id = ? AND user_id = ?", matchID, userID).First(&existingParticipant).Error
-	if err != nil && err != gorm.ErrRecordNotFound {
-		c.Error(apperrors.MapGORMError(err))
-		return
-	}
-
-	// 如果已經參與，返回錯誤
-	if err == nil {
-		c.Error(apperrors.NewValidationError("您已經參與此配對局"))
-		return
-	}
-
 	// 建立新的參與記錄
 	participant := models.MatchParticipant{
 		MatchID:  matchID,
-		UserID:   userID,
+		UserID:   user.ID,
 		Status:   "pending",
 		JoinedAt: time.Now(),
 	}
 
-	if err := database.GlobalDB.Conn.Create(&participant).Error; err != nil {
-		c.Error(apperrors.MapGORMError(err))
+	// 在交易中檢查並建立參與記錄，同時送出的重複請求由 unique_match_user 索引擋下；
+	// 先鎖定配對局，讓自動審核計算名額時不會與其他參與請求重疊
+	err = database.GlobalDB.Conn.Transaction(func(tx *gorm.DB) error {
+		var match models.Match
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&match, matchID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return apperrors.NewValidationError("指定的配對局不存在或已關閉")
+			}
+			return apperrors.MapGORMError(err)
+		}
+		if err := checkReliability(tx, &match, user.ID); err != nil {
+			return err
+		}
+		if err := checkJoinQuota(tx, user.ID); err != nil {
+			return err
+		}
+		if err := createParticipant(tx, &participant); err != nil {
+			return err
+		}
+
+		approve, err := shouldAutoApprove(tx, &match, user.ID)
+		if err != nil {
+			return apperrors.MapGORMError(err)
+		}
+		if !approve {
+			return recordEvent(tx, models.NewParticipantEvent(&participant, "", participant.Status, user.ID, ""))
+		}
+		if err := tx.Model(&participant).Update("status", "approved").Error; err != nil {
+			return apperrors.MapGORMError(err)
+		}
+		participant.Status = "approved"
+		return recordEvent(tx, models.NewParticipantEvent(&participant, "", participant.Status, user.ID, "依配對局審核方式自動通過"))
+	})
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
@@ -214,13 +547,68 @@ func joinMatch(c *gin.Context) {
 	c.JSON(http.StatusCreated, participant)
 }
 
+// ErrCodeAlreadyJoined 重複參與同一個配對局時回傳的錯誤代碼
+const ErrCodeAlreadyJoined = "already_joined"
+
+// ErrCodeOutsideActivitySchedule 配對時間不在活動的優惠期間或每週可用時段內時回傳的錯誤代碼
+const ErrCodeOutsideActivitySchedule = "outside_activity_schedule"
+
+// createParticipant 在交易中確認配對局可參與並建立參與記錄
+func createParticipant(tx *gorm.DB, participant *models.MatchParticipant) error {
+	alreadyJoined := apperrors.NewCodedError(http.StatusConflict, ErrCodeAlreadyJoined, "您已經參與此配對局")
+
+	// 檢查配對局是否存在且可參與
+	var match models.Match
+	if err := tx.Where("id = ? AND status = ? AND match_time > ?", participant.MatchID, "open", time.Now()).First(&match).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperrors.NewValidationError("指定的配對局不存在或已關閉")
+		}
+		return apperrors.MapGORMError(err)
+	}
+
+	// 檢查使用者是否已經參與此配對局；被開局者移除的使用者不能再參與
+	var existing models.MatchParticipant
+	err := tx.Where("match_id = ? AND user_id = ?", participant.MatchID, participant.UserID).First(&existing).Error
+	if err == nil {
+		if existing.Status == "removed" {
+			return apperrors.NewCodedError(http.StatusForbidden, ErrCodeRemovedFromMatch, "您已被開局者移出此配對局，無法再參與")
+		}
+		return alreadyJoined
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return apperrors.MapGORMError(err)
+	}
+
+	if err := checkOrganizerBlock(tx, match.OrganizerID, participant.UserID); err != nil {
+		return err
+	}
+
+	if err := tx.Create(participant).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return alreadyJoined
+		}
+		return apperrors.MapGORMError(err)
+	}
+	return nil
+}
+
 // listPastMatches 取得過去參與的配對列表
 // @Summary 取得過去參與的配對列表
-// @Description 取得該使用者參與過的已完成的配對局列表
+// @Description 取得該使用者參與過的已完成的配對局列表，支援游標分頁、篩選與排序
 // @Tags 使用者
 // @Accept json
 // @Produce json
+// @Param activity_id query int false "活動ID"
+// @Param location_id query int false "地點ID"
+// @Param from query string false "配對時間起 (RFC3339)"
+// @Param to query string false "配對時間迄 (RFC3339)"
+// @Param sort query string false "排序欄位 (id、match_time、end_time)，前綴 - 表示遞減，預設 -match_time"
+// @Param limit query int false "每頁筆數 (1-100)，預設 20"
+// @Param cursor query string false "上一頁回傳的 X-Next-Cursor"
 // @Success 200 {array} Match
+// @Header 200 {integer} X-Total-Count "符合條件的總筆數"
+// @Header 200 {string} X-Next-Cursor "下一頁游標，沒有下一頁時為空"
+// @Failure 400 {object} map[string]string "無效的查詢參數"
 // @Failure 500 {object} map[string]string "無法取得過去參與的配對列表"
 // @Router /user/past-matches [get]
 // @Security ApiKeyAuth
@@ -234,15 +622,21 @@ func listPastMatches(c *gin.Context) {
 
 	userID := user.ID
 
-	var matches []models.Match
 	// 取得該使用者參與過的已完成的配對局
-	if err := database.GlobalDB.Conn.Joins("JOIN match_participants mp ON matches.id = mp.match_id").
-		Where("mp.user_id = ? AND matches.status = ?", userID, "completed").
-		Order("matches.match_time DESC").
-		Preload("Activity").
-		Preload("Organizer").
-		Find(&matches).Error; err != nil {
-		c.Error(apperrors.MapGORMError(err))
+	query := database.GlobalDB.Conn.Model(&models.Match{}).
+		Joins("JOIN match_participants mp ON matches.id = mp.match_id").
+		Where("mp.user_id = ? AND matches.status = ?", userID, "completed")
+	query, err = applyMatchFilters(c, query)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	spec := matchListSpec
+	spec.DefaultSort = "-match_time"
+	matches, err := Paginate(c, query, spec)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
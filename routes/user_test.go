@@ -0,0 +1,237 @@
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"free2free/database"
+	"free2free/geo"
+	"free2free/models"
+
+	apperrors "free2free/errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/sessions"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// setupUserTestDatabase 建立包含所有資料表的測試資料庫
+// 使用暫存檔案讓連線池中的每個連線都看到同一個資料庫，並以 BEGIN IMMEDIATE 與 busy timeout 處理並行寫入
+func setupUserTestDatabase(t *testing.T) *gorm.DB {
+	dsn := fmt.Sprintf("file:%s/test.db?_busy_timeout=5000&_txlock=immediate", t.TempDir())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{TranslateError: true})
+	if err != nil {
+		t.Fatalf("failed to connect to test database: %v", err)
+	}
+
+	database.SetGlobalDB(&database.ActualGormDB{Conn: db})
+
+	err = db.AutoMigrate(
+		&models.User{},
+		&models.Location{},
+		&models.Activity{},
+		&models.Match{},
+		&models.MatchParticipant{},
+		&models.MatchInvite{},
+		&models.MatchMessage{},
+		&models.MatchMessageRead{},
+		&models.MessageReport{},
+		&models.Review{},
+		&models.ReviewLike{},
+		&models.MatchSeries{},
+		&models.MatchSeriesPartner{},
+		&models.CalendarToken{},
+		&models.PairingIntent{},
+		&models.Notification{},
+		&models.MatchChange{},
+		&models.OrganizerBlock{},
+		&models.MatchCoOrganizer{},
+		&models.MatchTransfer{},
+		&models.MatchPrivateDetail{},
+		&models.MatchPrivateAccess{},
+		&models.UserContact{},
+		&models.MatchEvent{},
+		&models.UserQuotaOverride{},
+		&models.ActivityAvailability{},
+	)
+	assert.NoError(t, err)
+	return db
+}
+
+// newUserContext 建立以指定使用者身份登入的測試 context
+func newUserContext(method, path string, body []byte, userID int64) (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	c.Request = httptest.NewRequest(method, path, bytes.NewBuffer(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	session := sessions.NewSession(nil, "free2free-session")
+	session.Values["user_id"] = userID
+	c.Set("session", session)
+	return c, w
+}
+
+// seedMatch 建立一個開局者與活動並回傳新的配對局
+func seedMatch(t *testing.T, db *gorm.DB, organizerID int64) models.Match {
+	location := models.Location{Name: "全家便利商店", Address: "台北市信義區", Latitude: 25.03, Longitude: 121.56}
+	location.Geohash = geo.Encode(location.Latitude, location.Longitude, geo.MaxPrecision)
+	assert.NoError(t, db.Create(&location).Error)
+	activity := models.Activity{Title: "咖啡買一送一", TargetCount: 2, LocationID: location.ID, CreatedBy: organizerID}
+	assert.NoError(t, db.Create(&activity).Error)
+
+	start := time.Now().Add(24 * time.Hour)
+	match := models.Match{ActivityID: activity.ID, OrganizerID: organizerID, MatchTime: start, EndTime: start.Add(2 * time.Hour), Status: "open"}
+	assert.NoError(t, db.Create(&match).Error)
+	return match
+}
+
+func seedUser(t *testing.T, db *gorm.DB, name string) models.User {
+	user := models.User{SocialID: name, SocialProvider: "facebook", Name: name, Email: name + "@example.com"}
+	assert.NoError(t, db.Create(&user).Error)
+	return user
+}
+
+func TestGetMatchRosterDependsOnViewer(t *testing.T) {
+	db := setupUserTestDatabase(t)
+
+	organizer := seedUser(t, db, "organizer")
+	approved := seedUser(t, db, "approved")
+	pending := seedUser(t, db, "pending")
+	outsider := seedUser(t, db, "outsider")
+	match := seedMatch(t, db, organizer.ID)
+
+	assert.NoError(t, db.Create(&models.MatchParticipant{MatchID: match.ID, UserID: approved.ID, Status: "approved", JoinedAt: time.Now()}).Error)
+	assert.NoError(t, db.Create(&models.MatchParticipant{MatchID: match.ID, UserID: pending.ID, Status: "pending", JoinedAt: time.Now()}).Error)
+
+	cases := []struct {
+		name        string
+		viewer      int64
+		rosterSize  int
+		hasMine     bool
+		isOrganizer bool
+	}{
+		{"organizer sees everyone", organizer.ID, 2, false, true},
+		{"approved sees approved only", approved.ID, 1, true, false},
+		{"pending sees counts only", pending.ID, 0, true, false},
+		{"outsider sees counts only", outsider.ID, 0, false, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, w := newUserContext("GET", "/", nil, tc.viewer)
+			c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(match.ID)}}
+
+			getMatch(c)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			var detail MatchDetail
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &detail))
+			assert.Equal(t, "咖啡買一送一", detail.Activity.Title)
+			assert.Equal(t, "全家便利商店", detail.Activity.Location.Name)
+			assert.Equal(t, tc.isOrganizer, detail.IsOrganizer)
+			assert.Len(t, detail.Participants, tc.rosterSize)
+			assert.Equal(t, tc.hasMine, detail.MyParticipation != nil)
+			assert.Equal(t, 1, detail.Counts.Approved)
+			assert.Equal(t, 1, detail.Counts.Pending)
+		})
+	}
+}
+
+func TestGetMatchCountsEveryParticipantStatus(t *testing.T) {
+	db := setupUserTestDatabase(t)
+
+	organizer := seedUser(t, db, "organizer")
+	match := seedMatch(t, db, organizer.ID)
+	for _, status := range []string{"pending", "approved", "reconfirm", "released", "removed", "rejected"} {
+		user := seedUser(t, db, status)
+		assert.NoError(t, db.Create(&models.MatchParticipant{MatchID: match.ID, UserID: user.ID, Status: status, JoinedAt: time.Now()}).Error)
+	}
+
+	c, w := newUserContext("GET", "/", nil, organizer.ID)
+	c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(match.ID)}}
+	getMatch(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var detail MatchDetail
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &detail))
+	assert.Equal(t, ParticipantCounts{Pending: 1, Approved: 1, Rejected: 1, Reconfirm: 1, Released: 1, Removed: 1}, detail.Counts)
+}
+
+func TestJoinMatchParallelRequestsCreateSingleParticipant(t *testing.T) {
+	db := setupUserTestDatabase(t)
+	organizer := seedUser(t, db, "organizer")
+	joiner := seedUser(t, db, "joiner")
+	match := seedMatch(t, db, organizer.ID)
+
+	const attempts = 10
+	codes := make(chan int, attempts)
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c, w := newUserContext("POST", "/", nil, joiner.ID)
+			c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(match.ID)}}
+			joinMatch(c)
+
+			if len(c.Errors) > 0 {
+				if appErr, ok := c.Errors.Last().Err.(*apperrors.AppError); ok {
+					codes <- appErr.Code
+					return
+				}
+				codes <- http.StatusInternalServerError
+				return
+			}
+			codes <- w.Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	created, conflicts := 0, 0
+	for code := range codes {
+		switch code {
+		case http.StatusCreated:
+			created++
+		case http.StatusConflict:
+			conflicts++
+		default:
+			t.Errorf("unexpected status %d", code)
+		}
+	}
+	assert.Equal(t, 1, created)
+	assert.Equal(t, attempts-1, conflicts)
+
+	var count int64
+	db.Model(&models.MatchParticipant{}).Where("match_id = ? AND user_id = ?", match.ID, joiner.ID).Count(&count)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestUniqueIndexesRejectDuplicates(t *testing.T) {
+	db := setupUserTestDatabase(t)
+
+	participant := models.MatchParticipant{MatchID: 1, UserID: 2, Status: "pending", JoinedAt: time.Now()}
+	assert.NoError(t, db.Create(&participant).Error)
+	duplicate := models.MatchParticipant{MatchID: 1, UserID: 2, Status: "pending", JoinedAt: time.Now()}
+	assert.ErrorIs(t, db.Create(&duplicate).Error, gorm.ErrDuplicatedKey)
+
+	review := models.Review{MatchID: 1, ReviewerID: 2, RevieweeID: 3, Score: 5}
+	assert.NoError(t, db.Create(&review).Error)
+	duplicateReview := models.Review{MatchID: 1, ReviewerID: 2, RevieweeID: 3, Score: 4}
+	assert.ErrorIs(t, db.Create(&duplicateReview).Error, gorm.ErrDuplicatedKey)
+
+	like := models.ReviewLike{ReviewID: review.ID, UserID: 3, IsLike: true}
+	assert.NoError(t, db.Create(&like).Error)
+	duplicateLike := models.ReviewLike{ReviewID: review.ID, UserID: 3, IsLike: false}
+	assert.ErrorIs(t, db.Create(&duplicateLike).Error, gorm.ErrDuplicatedKey)
+}
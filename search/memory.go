@@ -0,0 +1,136 @@
+package search
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"free2free/models"
+
+	"gorm.io/gorm"
+)
+
+type docKey struct {
+	kind Kind
+	id   int64
+}
+
+// MemoryIndex 程序內的倒排索引，供 SQLite 與測試使用
+// 只有在單一副本時結果才會完整，多副本部署請使用 MySQLIndex
+type MemoryIndex struct {
+	mu       sync.RWMutex
+	postings map[Kind]map[string]map[int64]int
+	docs     map[docKey][]string
+}
+
+// NewMemoryIndex 建立空的程序內索引
+func NewMemoryIndex() *MemoryIndex {
+	return &MemoryIndex{
+		postings: map[Kind]map[string]map[int64]int{},
+		docs:     map[docKey][]string{},
+	}
+}
+
+// Load 從資料庫載入所有活動與地點建立索引
+func (m *MemoryIndex) Load(db *gorm.DB) error {
+	var activities []models.Activity
+	if err := db.Find(&activities).Error; err != nil {
+		return err
+	}
+	for _, a := range activities {
+		m.Upsert(KindActivity, a.ID, a.Title, a.Description)
+	}
+
+	var locations []models.Location
+	if err := db.Find(&locations).Error; err != nil {
+		return err
+	}
+	for _, l := range locations {
+		m.Upsert(KindLocation, l.ID, l.Name, l.Address)
+	}
+	return nil
+}
+
+func (m *MemoryIndex) Upsert(kind Kind, id int64, fields ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.remove(kind, id)
+
+	tokens := indexTokens(strings.Join(fields, " "))
+	m.docs[docKey{kind, id}] = tokens
+
+	if m.postings[kind] == nil {
+		m.postings[kind] = map[string]map[int64]int{}
+	}
+	for _, token := range tokens {
+		if m.postings[kind][token] == nil {
+			m.postings[kind][token] = map[int64]int{}
+		}
+		m.postings[kind][token][id]++
+	}
+}
+
+func (m *MemoryIndex) Delete(kind Kind, id int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.remove(kind, id)
+}
+
+func (m *MemoryIndex) remove(kind Kind, id int64) {
+	key := docKey{kind, id}
+	for _, token := range m.docs[key] {
+		delete(m.postings[kind][token], id)
+		if len(m.postings[kind][token]) == 0 {
+			delete(m.postings[kind], token)
+		}
+	}
+	delete(m.docs, key)
+}
+
+// Search 回傳包含所有查詢 token 的文件，依 token 出現次數排序
+func (m *MemoryIndex) Search(ctx context.Context, kind Kind, query string, limit int) ([]Hit, error) {
+	tokens := Tokenize(query)
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	scores := map[int64]float64{}
+	for i, token := range tokens {
+		postings := m.postings[kind][token]
+		if i == 0 {
+			for id, tf := range postings {
+				scores[id] = float64(tf)
+			}
+			continue
+		}
+		for id := range scores {
+			tf, ok := postings[id]
+			if !ok {
+				delete(scores, id)
+				continue
+			}
+			scores[id] += float64(tf)
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for id, score := range scores {
+		hits = append(hits, Hit{ID: id, Score: score})
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits, nil
+}
@@ -0,0 +1,114 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+// fullTextIndexes 各資料種類對應的資料表與 FULLTEXT 索引欄位
+var fullTextIndexes = map[Kind]struct {
+	table   string
+	name    string
+	columns string
+}{
+	KindActivity: {table: "activities", name: "ft_activities_title_description", columns: "title, description"},
+	KindLocation: {table: "locations", name: "ft_locations_name_address", columns: "name, address"},
+}
+
+// MySQLIndex 使用 MySQL FULLTEXT (ngram parser) 的搜尋索引
+// 索引由 MySQL 自行維護，因此 Upsert/Delete 不需要做任何事
+type MySQLIndex struct {
+	db *gorm.DB
+}
+
+// NewMySQLIndex 建立使用 MySQL FULLTEXT 的搜尋索引
+func NewMySQLIndex(db *gorm.DB) *MySQLIndex {
+	return &MySQLIndex{db: db}
+}
+
+// EnsureIndexes 建立搜尋所需的 FULLTEXT 索引 (已存在時略過)
+// 使用 ngram parser 讓中文等不以空白分詞的文字也能被搜尋
+func (m *MySQLIndex) EnsureIndexes(ctx context.Context) error {
+	for _, idx := range fullTextIndexes {
+		var count int64
+		err := m.db.WithContext(ctx).Raw(
+			"SELECT COUNT(*) FROM information_schema.statistics WHERE table_schema = DATABASE() AND table_name = ? AND index_name = ?",
+			idx.table, idx.name,
+		).Scan(&count).Error
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+		stmt := fmt.Sprintf("ALTER TABLE %s ADD FULLTEXT INDEX %s (%s) WITH PARSER ngram", idx.table, idx.name, idx.columns)
+		if err := m.db.WithContext(ctx).Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MySQLIndex) Search(ctx context.Context, kind Kind, query string, limit int) ([]Hit, error) {
+	idx, ok := fullTextIndexes[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown search kind: %s", kind)
+	}
+
+	boolean := booleanQuery(query)
+	if boolean == "" {
+		return nil, nil
+	}
+
+	var rows []struct {
+		ID    int64
+		Score float64
+	}
+	match := fmt.Sprintf("MATCH(%s) AGAINST (? IN BOOLEAN MODE)", idx.columns)
+	err := m.db.WithContext(ctx).
+		Table(idx.table).
+		Select("id, "+match+" AS score", boolean).
+		Where(match, boolean).
+		Order("score DESC, id ASC").
+		Limit(limit).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	hits := make([]Hit, len(rows))
+	for i, row := range rows {
+		hits[i] = Hit{ID: row.ID, Score: row.Score}
+	}
+	return hits, nil
+}
+
+func (m *MySQLIndex) Upsert(kind Kind, id int64, fields ...string) {}
+
+func (m *MySQLIndex) Delete(kind Kind, id int64) {}
+
+// booleanQuery 將使用者輸入轉為 BOOLEAN MODE 查詢，每個詞都必須出現
+// 移除 BOOLEAN MODE 的運算子以免使用者輸入改變查詢語意；
+// 單一字元的詞短於 ngram token 長度，改用前綴比對
+func booleanQuery(query string) string {
+	cleaned := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`+-<>()~*"@`, r) {
+			return ' '
+		}
+		return r
+	}, query)
+
+	var terms []string
+	for _, word := range strings.Fields(cleaned) {
+		if utf8.RuneCountInString(word) == 1 {
+			terms = append(terms, "+"+word+"*")
+			continue
+		}
+		terms = append(terms, `+"`+word+`"`)
+	}
+	return strings.Join(terms, " ")
+}
@@ -0,0 +1,47 @@
+package search
+
+import (
+	"context"
+	"sync"
+)
+
+// Kind 可搜尋的資料種類
+type Kind string
+
+const (
+	KindActivity Kind = "activity"
+	KindLocation Kind = "location"
+)
+
+// Hit 一筆搜尋結果，Score 越高越相關
+type Hit struct {
+	ID    int64
+	Score float64
+}
+
+// Index 全文搜尋索引
+// 資料異動時呼叫 Upsert/Delete；由資料庫自行維護索引的實作可以忽略這些呼叫
+type Index interface {
+	Search(ctx context.Context, kind Kind, query string, limit int) ([]Hit, error)
+	Upsert(kind Kind, id int64, fields ...string)
+	Delete(kind Kind, id int64)
+}
+
+var (
+	mu      sync.RWMutex
+	current Index = NewMemoryIndex()
+)
+
+// SetIndex 設定全域使用的搜尋索引
+func SetIndex(idx Index) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = idx
+}
+
+// Current 回傳全域使用的搜尋索引
+func Current() Index {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
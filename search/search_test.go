@@ -0,0 +1,58 @@
+package search
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenize(t *testing.T) {
+	assert.Equal(t, []string{"咖啡"}, Tokenize("咖啡"))
+	assert.Equal(t, []string{"星巴", "巴克", "coffee", "買一", "一送", "送一"}, Tokenize("星巴克 Coffee 買一送一"))
+	assert.Equal(t, []string{"茶"}, Tokenize("茶"))
+	assert.Equal(t, []string{"7", "eleven"}, Tokenize("7-ELEVEN"))
+}
+
+func TestMemoryIndexSearch(t *testing.T) {
+	idx := NewMemoryIndex()
+	idx.Upsert(KindActivity, 1, "全家咖啡買一送一", "大杯拿鐵")
+	idx.Upsert(KindActivity, 2, "星巴克 Coffee", "咖啡豆 咖啡")
+	idx.Upsert(KindActivity, 3, "珍珠奶茶", "")
+	idx.Upsert(KindLocation, 1, "咖啡廳", "台北市")
+
+	ctx := context.Background()
+
+	hits, err := idx.Search(ctx, KindActivity, "咖啡", 10)
+	assert.NoError(t, err)
+	if assert.Len(t, hits, 2) {
+		// 出現次數較多的排在前面
+		assert.Equal(t, int64(2), hits[0].ID)
+		assert.Equal(t, int64(1), hits[1].ID)
+	}
+
+	hits, _ = idx.Search(ctx, KindActivity, "茶", 10)
+	assert.Len(t, hits, 1)
+
+	hits, _ = idx.Search(ctx, KindActivity, "coffee 星巴克", 10)
+	assert.Len(t, hits, 1)
+
+	// 種類之間互不影響
+	hits, _ = idx.Search(ctx, KindLocation, "咖啡", 10)
+	assert.Len(t, hits, 1)
+
+	// 更新與刪除會反映在索引上
+	idx.Upsert(KindActivity, 2, "星巴克", "拿鐵")
+	hits, _ = idx.Search(ctx, KindActivity, "咖啡", 10)
+	assert.Len(t, hits, 1)
+	idx.Delete(KindActivity, 1)
+	hits, _ = idx.Search(ctx, KindActivity, "咖啡", 10)
+	assert.Len(t, hits, 0)
+}
+
+func TestBooleanQuery(t *testing.T) {
+	assert.Equal(t, `+"咖啡"`, booleanQuery("咖啡"))
+	assert.Equal(t, `+茶*`, booleanQuery("茶"))
+	assert.Equal(t, `+"星巴克" +"coffee"`, booleanQuery(`星巴克 -"coffee"`))
+	assert.Equal(t, "", booleanQuery("+-*"))
+}